@@ -17,6 +17,7 @@ import (
 	"github.com/liuwangchen/toy/pkg/host"
 	"github.com/liuwangchen/toy/pkg/ipx"
 	"github.com/liuwangchen/toy/registry"
+	"github.com/liuwangchen/toy/transport/middleware/metrics"
 	"github.com/liuwangchen/toy/transport/rpc"
 )
 
@@ -65,6 +66,7 @@ type App struct {
 	registrar        registry.Registrar
 	registrarTimeout time.Duration
 	pprofAddr        string
+	metricsAddr      string
 }
 
 // Option option
@@ -133,6 +135,11 @@ func WithPProf(addr string) Option {
 	return func(o *App) { o.pprofAddr = addr }
 }
 
+// WithMetrics 在addr上提供/metrics
+func WithMetrics(addr string) Option {
+	return func(o *App) { o.metricsAddr = addr }
+}
+
 // New 构造
 func New(opts ...Option) *App {
 	a := &App{
@@ -171,6 +178,10 @@ func (a *App) Endpoint() []string {
 
 func (a *App) buildInstance() (*registry.ServiceInstance, error) {
 	endpoints := make([]string, 0)
+	metadata := make(map[string]string, len(a.metadata))
+	for k, v := range a.metadata {
+		metadata[k] = v
+	}
 	for _, srv := range a.runners {
 		if r, ok := srv.(rpc.Endpointer); ok {
 			e, err := r.Endpoint()
@@ -180,12 +191,20 @@ func (a *App) buildInstance() (*registry.ServiceInstance, error) {
 			endPointStr, _ := url.QueryUnescape(e.String())
 			endpoints = append(endpoints, endPointStr)
 		}
+		// runner的metadata，不覆盖app配置的
+		if r, ok := srv.(rpc.Metadataer); ok {
+			for k, v := range r.Metadata() {
+				if _, ok := metadata[k]; !ok {
+					metadata[k] = v
+				}
+			}
+		}
 	}
 	return &registry.ServiceInstance{
 		ID:         a.ID(),
 		Name:       a.Name(),
 		Version:    a.Version(),
-		Metadata:   a.Metadata(),
+		Metadata:   metadata,
 		Endpoints:  endpoints,
 		LaunchTime: time.Now().Unix(),
 		Ip:         ipx.GetOutboundIP(),
@@ -265,30 +284,43 @@ func (a *App) checkRunnerReady(_ context.Context) error {
 	return nil
 }
 
-type pprofRunner struct {
+// httpRunner 独立端口的http服务，pprof/metrics等使用
+type httpRunner struct {
 	Addr     string
+	handler  http.Handler
 	server   *http.Server
 	endPoint *url.URL
 	lis      net.Listener
 }
 
-func (this *pprofRunner) Endpoint() (*url.URL, error) {
+func (this *httpRunner) Endpoint() (*url.URL, error) {
 	return this.endPoint, nil
 }
 
-func newPprofRunner(addr string) Runner {
+func newHTTPRunner(scheme string, addr string, handler http.Handler) *httpRunner {
 	lis, _ := net.Listen("tcp", addr)
 	addr, _ = host.Extract(addr, lis)
-	return &pprofRunner{Addr: addr, lis: lis, endPoint: endpoint.NewEndpoint("pprof", addr, false)}
+	return &httpRunner{Addr: addr, handler: handler, lis: lis, endPoint: endpoint.NewEndpoint(scheme, addr, false)}
+}
+
+func newPprofRunner(addr string) Runner {
+	return newHTTPRunner("pprof", addr, http.DefaultServeMux)
+}
+
+// newMetricsRunner 在/metrics输出metrics.DefaultRegistry
+func newMetricsRunner(addr string) Runner {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	return newHTTPRunner("metrics", addr, mux)
 }
 
-func (this *pprofRunner) Start(ctx context.Context) error {
+func (this *httpRunner) Start(ctx context.Context) error {
 	if this.lis == nil {
 		return errors.New("lis is nil")
 	}
 	server := &http.Server{
 		Addr:    this.Addr,
-		Handler: http.DefaultServeMux,
+		Handler: this.handler,
 	}
 
 	this.server = server
@@ -299,7 +331,10 @@ func (this *pprofRunner) Start(ctx context.Context) error {
 	return nil
 }
 
-func (this *pprofRunner) Stop(ctx context.Context) error {
+func (this *httpRunner) Stop(ctx context.Context) error {
+	if this.server == nil {
+		return nil
+	}
 	profileCtx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	err := this.server.Shutdown(profileCtx)
@@ -314,6 +349,10 @@ func (a *App) Run(rootCtx context.Context) error {
 	if len(a.pprofAddr) > 0 {
 		a.runners = append(a.runners, newPprofRunner(a.pprofAddr))
 	}
+	// metrics
+	if len(a.metricsAddr) > 0 {
+		a.runners = append(a.runners, newMetricsRunner(a.metricsAddr))
+	}
 	ctx, cancel := context.WithCancel(rootCtx)
 	// 开始
 	runStarts := make([]executor.Executor, 0, len(a.runners))
@@ -334,8 +373,12 @@ func (a *App) Run(rootCtx context.Context) error {
 		// 开始前执行函数
 		executor.WithBefore(executor.Func(a.before)),
 		executor.WithAfter(executor.Append(
-			// 并行关闭
-			executor.Parallel(runStops...),
+			// 并行关闭，stopTimeout内等待runner处理完
+			executor.Func(func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, a.stopTimeout)
+				defer cancel()
+				return executor.Parallel(runStops...).Execute(ctx)
+			}),
 			// app的最后执行函数
 			executor.Func(a.onStop),
 		)),
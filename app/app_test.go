@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/middleware/metrics"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+func TestMetricsRunner(t *testing.T) {
+	// 计数器是全局的，每次运行用不同的operation，-count>1时也只计一次
+	op := fmt.Sprintf("/test.A/Call%d", time.Now().UnixNano())
+	metrics.ServerRequests.With("http", op, "200", "").Inc()
+
+	r := newMetricsRunner("127.0.0.1:0")
+	e, err := r.(rpc.Endpointer).Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Scheme != "metrics" {
+		t.Fatalf("expect scheme metrics, got %s", e.Scheme)
+	}
+	go func() {
+		_ = r.Start(context.Background())
+	}()
+	defer r.Stop(context.Background())
+
+	resp, err := http.Get("http://" + e.Host + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != metrics.ContentType {
+		t.Fatalf("expect content type %s, got %s", metrics.ContentType, ct)
+	}
+	want := `server_requests_code_total{kind="http",operation="` + op + `",code="200",reason=""} 1`
+	if !strings.Contains(string(b), want) {
+		t.Fatalf("missing %q in:\n%s", want, b)
+	}
+}
+
+type metadataRunner struct {
+	Runner
+	md map[string]string
+}
+
+func (r metadataRunner) Metadata() map[string]string {
+	return r.md
+}
+
+func TestBuildInstanceMetadata(t *testing.T) {
+	a := New(
+		WithMetadata(map[string]string{"zone": "a"}),
+		WithRunners(metadataRunner{md: map[string]string{"zone": "b", "natsrpc": "{}"}}),
+	)
+	instance, err := a.buildInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Metadata["zone"] != "a" || instance.Metadata["natsrpc"] != "{}" {
+		t.Fatalf("unexpected metadata %v", instance.Metadata)
+	}
+	if len(a.metadata) != 1 {
+		t.Fatalf("app metadata modified %v", a.metadata)
+	}
+}
@@ -0,0 +1,44 @@
+// Package genkey 代码生成插件共用的取key表达式
+package genkey
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const fmtPackage = protogen.GoImportPath("fmt")
+
+// Expr 生成从请求in取key的表达式，key字段用bool类型的字段选项ext标注
+// 没有标注的字段时返回空，标注了多个字段或字段不是标量时返回错误
+func Expr(g *protogen.GeneratedFile, msg *protogen.Message, ext protoreflect.ExtensionType) (string, error) {
+	name := ext.TypeDescriptor().Name()
+	var key *protogen.Field
+	for _, field := range msg.Fields {
+		isKey, ok := proto.GetExtension(field.Desc.Options(), ext).(bool)
+		if !ok || !isKey {
+			continue
+		}
+		if key != nil {
+			return "", fmt.Errorf("message %s has more than one %s field: %s, %s", msg.Desc.FullName(), name, key.Desc.Name(), field.Desc.Name())
+		}
+		if field.Desc.IsList() || field.Desc.IsMap() || field.Message != nil {
+			return "", fmt.Errorf("%s field %s.%s must be a scalar", name, msg.Desc.FullName(), field.Desc.Name())
+		}
+		key = field
+	}
+	if key == nil {
+		return "", nil
+	}
+	getter := "in.Get" + key.GoName + "()"
+	switch key.Desc.Kind() {
+	case protoreflect.StringKind:
+		return getter, nil
+	case protoreflect.BytesKind:
+		return "string(" + getter + ")", nil
+	default:
+		return g.QualifiedGoIdent(fmtPackage.Ident("Sprint")) + "(" + getter + ")", nil
+	}
+}
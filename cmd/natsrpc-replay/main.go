@@ -0,0 +1,104 @@
+// natsrpc-replay 把natsrpc.FileRecorder记录的请求重新发送到运行中的服务
+//
+//	natsrpc-replay -addr nats://127.0.0.1:4222 -file record.jsonl
+//
+// 记录的payload按服务的编码重放，服务不是protobuf编码时用-enc指定
+//
+// 每条重放结果按json行输出到stdout，和记录的回复对比后在stderr输出汇总
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/rpc/natsrpc"
+)
+
+var (
+	addr     = flag.String("addr", "nats://127.0.0.1:4222", "nats server address")
+	enc      = flag.String("enc", natsrpc.PROTOBUF_ENCODER, "encoder of the service, protobuf, json or gob")
+	file     = flag.String("file", "", "record file written by natsrpc.FileRecorder")
+	side     = flag.String("side", natsrpc.RecordSideServer, "replay records of side server or client, empty for all")
+	method   = flag.String("method", "", "only replay records of service/method, e.g. helloworld.Greeter/SayHello")
+	timeout  = flag.Duration("timeout", 3*time.Second, "request timeout")
+	interval = flag.Duration("interval", 0, "interval between records")
+)
+
+func main() {
+	flag.Parse()
+	if len(*file) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := natsrpc.ReadRecords(f)
+	if err != nil {
+		return err
+	}
+
+	conn, err := natsrpc.NewClientConn(natsrpc.WithAddr(*addr), natsrpc.WithEncType(*enc), natsrpc.WithTimeout(*timeout))
+	if err != nil {
+		return err
+	}
+	defer conn.GetConn().Close()
+
+	var total, diff int
+	out := json.NewEncoder(os.Stdout)
+	for _, record := range records {
+		if !match(record) {
+			continue
+		}
+		if total > 0 && *interval > 0 {
+			time.Sleep(*interval)
+		}
+		total++
+		result := conn.Replay(context.Background(), record)
+		if err := out.Encode(result); err != nil {
+			return err
+		}
+		if !sameResult(record, result) {
+			diff++
+			fmt.Fprintf(os.Stderr, "diff %s: code %d -> %d, error %q -> %q\n", record.Subject, record.Code, result.Code, record.Error, result.Error)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "replayed %d, diff %d\n", total, diff)
+	return conn.GetConn().Flush()
+}
+
+func match(record *natsrpc.Record) bool {
+	if len(*side) > 0 && record.Side != *side {
+		return false
+	}
+	if len(*method) > 0 && !strings.EqualFold(record.Service+"/"+record.Method, *method) {
+		return false
+	}
+	return true
+}
+
+// sameResult publish只看是否发送成功，request对比错误码和回复
+func sameResult(record, result *natsrpc.Record) bool {
+	if record.Code != result.Code {
+		return false
+	}
+	if record.IsPublish || record.Code != 0 {
+		return true
+	}
+	return bytes.Equal(record.ReplyPayload, result.ReplyPayload)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	errorsPackage = protogen.GoImportPath("github.com/liuwangchen/toy/transport/errors")
+	fmtPackage    = protogen.GoImportPath("fmt")
+)
+
+// generateFile generates a _errors.pb.go file containing errors definitions.
+func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	if len(file.Enums) == 0 {
+		return nil
+	}
+	filename := file.GeneratedFilenamePrefix + "_errors.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	g.P("// Code generated by protoc-gen-go-errors. DO NOT EDIT.")
+	g.P("// versions:")
+	g.P(fmt.Sprintf("// protoc-gen-go-errors %s", release))
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+	g.QualifiedGoIdent(fmtPackage.Ident(""))
+	generateFileContent(gen, file, g)
+	return g
+}
+
+// generateFileContent generates the errors definitions, excluding the package statement.
+func generateFileContent(gen *protogen.Plugin, file *protogen.File, g *protogen.GeneratedFile) {
+	g.P("// This is a compile-time assertion to ensure that this generated file")
+	g.P("// is compatible with the errors package it is being compiled against.")
+	g.P("const _ = ", errorsPackage.Ident("SupportPackageIsVersion1"))
+	g.P()
+	index := 0
+	for _, enum := range file.Enums {
+		if !genErrorsReason(gen, file, g, enum) {
+			index++
+		}
+	}
+	// If all enums do not contain 'errors.code', the current file is skipped
+	if index == 0 {
+		g.Skip()
+	}
+}
+
+func genErrorsReason(gen *protogen.Plugin, file *protogen.File, g *protogen.GeneratedFile, enum *protogen.Enum) bool {
+	defaultCode, _ := proto.GetExtension(enum.Desc.Options(), errors.E_DefaultCode).(int32)
+	if !isValidCode(defaultCode) {
+		fmt.Fprintf(os.Stderr, "\u001B[31mERROR\u001B[m: Enum '%s' default_code %d is out of range 0-600\n", enum.Desc.FullName(), defaultCode)
+		os.Exit(2)
+	}
+	ew := &errorWrapper{}
+	for _, v := range enum.Values {
+		code := defaultCode
+		if c, _ := proto.GetExtension(v.Desc.Options(), errors.E_Code).(int32); c != 0 {
+			code = c
+		}
+		if code == 0 {
+			continue
+		}
+		if !isValidCode(code) {
+			fmt.Fprintf(os.Stderr, "\u001B[31mERROR\u001B[m: Enum value '%s' code %d is out of range 0-600\n", v.Desc.FullName(), code)
+			os.Exit(2)
+		}
+		ew.Errors = append(ew.Errors, &errorInfo{
+			Name:       string(enum.Desc.Name()),
+			Value:      string(v.Desc.Name()),
+			CamelValue: case2Camel(string(v.Desc.Name())),
+			HTTPCode:   int(code),
+			Comment:    strings.TrimSpace(string(v.Comments.Leading)),
+		})
+	}
+	if len(ew.Errors) == 0 {
+		return true
+	}
+	g.P(ew.execute())
+	return false
+}
+
+func isValidCode(code int32) bool {
+	return code >= 0 && code <= 600
+}
+
+// case2Camel USER_NOT_FOUND -> UserNotFound
+func case2Camel(name string) string {
+	words := strings.Split(strings.ToLower(name), "_")
+	for i, w := range words {
+		if len(w) == 0 {
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, "")
+}
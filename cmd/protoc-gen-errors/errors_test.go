@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCase2Camel(t *testing.T) {
+	tests := map[string]string{
+		"USER_NOT_FOUND": "UserNotFound",
+		"user_not_found": "UserNotFound",
+		"UNKNOWN":        "Unknown",
+		"a__b":           "AB",
+	}
+	for in, want := range tests {
+		if got := case2Camel(in); got != want {
+			t.Errorf("case2Camel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestErrorWrapperExecute(t *testing.T) {
+	ew := &errorWrapper{Errors: []*errorInfo{
+		{Name: "ErrorReason", Value: "USER_NOT_FOUND", CamelValue: "UserNotFound", HTTPCode: 404},
+	}}
+	out := ew.execute()
+	for _, want := range []string{
+		"func IsUserNotFound(err error) bool",
+		"e.Reason == ErrorReason_USER_NOT_FOUND.String() && e.Code == 404",
+		"func ErrorUserNotFound(format string, args ...interface{}) *errors.Error",
+		"errors.New(404, ErrorReason_USER_NOT_FOUND.String(), fmt.Sprintf(format, args...))",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("generated code missing %q:\n%s", want, out)
+		}
+	}
+}
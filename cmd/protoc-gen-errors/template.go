@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"strings"
+	"text/template"
+)
+
+var eTpl *template.Template
+
+//go:embed tmpl.tepl
+var errorsTmpl string
+
+func init() {
+	var err error
+	eTpl, err = template.New("errors").Parse(errorsTmpl)
+	if err != nil {
+		panic(err)
+	}
+}
+
+type errorInfo struct {
+	Name       string // ErrorReason
+	Value      string // USER_NOT_FOUND
+	CamelValue string // UserNotFound
+	HTTPCode   int
+	Comment    string
+}
+
+type errorWrapper struct {
+	Errors []*errorInfo
+}
+
+func (e *errorWrapper) execute() string {
+	buf := new(bytes.Buffer)
+	if err := eTpl.Execute(buf, e); err != nil {
+		panic(err)
+	}
+	return strings.Trim(buf.String(), "\r\n")
+}
@@ -0,0 +1,4 @@
+package main
+
+// release is the current protoc-gen-go-errors version.
+const release = "v2.2.2"
@@ -79,10 +79,6 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 		sd.Async = serviceAsync
 	}
 	for _, method := range service.Methods {
-		if method.Desc.IsStreamingClient() {
-			continue
-		}
-
 		rule, ok := proto.GetExtension(method.Desc.Options(), httprpc.E_Rule).(*httprpc.HttpRule)
 		if rule != nil && ok {
 			for _, bind := range rule.AdditionalBindings {
@@ -99,7 +95,11 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 			sd.Methods = append(sd.Methods, methodDesc)
 		} else {
 			path := fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.Desc.Name())
-			methodDesc := buildMethodDesc(g, method, "POST", path, method.Desc.IsStreamingServer())
+			verb := "POST"
+			if method.Desc.IsStreamingClient() {
+				verb = "GET"
+			}
+			methodDesc := buildMethodDesc(g, method, verb, path, method.Desc.IsStreamingServer())
 			methodDesc.HasBody = true
 			if methodDesc.Async {
 				serviceAsync = true
@@ -115,10 +115,7 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 
 func hasHTTPRule(services []*protogen.Service) bool {
 	for _, service := range services {
-		for _, method := range service.Methods {
-			if method.Desc.IsStreamingClient() {
-				continue
-			}
+		if len(service.Methods) > 0 {
 			return true
 		}
 	}
@@ -219,6 +216,22 @@ func buildMethodDesc(g *protogen.GeneratedFile, m *protogen.Method, method, path
 
 	async, _ := proto.GetExtension(m.Desc.Options(), rpc.E_Async).(bool)
 
+	// 客户端流和双向流走websocket，握手只能是GET
+	isStreamingClient := m.Desc.IsStreamingClient()
+	if isStreamingClient {
+		if method != "GET" {
+			fmt.Fprintf(os.Stderr, "\u001B[31mWARN\u001B[m: %s %s is a client streaming method over websocket, method changed to GET.\n", method, path)
+		}
+		// 生成的客户端不会发送路径参数
+		if len(vars) > 0 {
+			fmt.Fprintf(os.Stderr, "\u001B[31mERROR\u001B[m: %s path variables are not supported for websocket streams.\n", path)
+			os.Exit(2)
+		}
+		method = "GET"
+		isPublish = false
+		async = false
+	}
+
 	return &methodDesc{
 		Name:              m.GoName,
 		Num:               methodSets[m.GoName],
@@ -228,6 +241,7 @@ func buildMethodDesc(g *protogen.GeneratedFile, m *protogen.Method, method, path
 		Method:            method,
 		HasVars:           len(vars) > 0,
 		IsStreamingServer: isStreamingServer,
+		IsStreamingClient: isStreamingClient,
 		IsPublish:         isPublish,
 		Async:             async,
 	}
@@ -13,7 +13,9 @@ var httpTemplate = `
 {{$serviceWrapperName := print .ServiceType "HttpServiceWrapper"}}
 type {{.ServiceType}}HTTPServer interface {
 {{- range .MethodSets}}
-	{{- if .IsStreamingServer}}
+	{{- if .IsStreamingClient}}
+	{{.Name}}Stream(context.Context, *{{.Name}}ServerStream) error
+	{{- else if .IsStreamingServer}}
 	{{.Name}}Stream(context.Context, *{{.Request}}, *{{.Name}}ServerStream) error
 	{{- else }}
 		{{- if .IsPublish}}
@@ -37,7 +39,11 @@ type {{ $serviceWrapperName }} struct {
 }
 {{- range .Methods }}
 // {{ .Name }} DO NOT USE
-	{{- if eq .IsPublish true }}
+	{{- if .IsStreamingClient }}
+func (s *{{ $serviceWrapperName }}){{ .Name }}Stream(ctx context.Context, stream *{{ .Name }}ServerStream) error {
+	return s.s.{{ .Name }}Stream(ctx, stream)
+}
+	{{- else if eq .IsPublish true }}
 func (s *{{ $serviceWrapperName }}){{ .Name }}(ctx context.Context, req *{{ .Request }}) error {
 	_, err := s.doer.Do(ctx, func() (interface{}, error) {
 	err := s.s.{{ .Name }}(ctx , req)
@@ -82,7 +88,11 @@ func RegisterAsync{{.ServiceType}}HTTPServer(conn *httprpc.ServerConn, doer asyn
 		s:    srv,
 	}
 	{{- range .Methods}}
-	r.{{.Method}}("{{.Path}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(ss, opt))
+	{{- if or .IsStreamingClient .IsStreamingServer}}
+	r.HandleStream("{{.Method}}", "{{.Path}}", "/{{$svrName}}/{{.Name}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(ss, opt))
+	{{- else}}
+	r.HandleOperation("{{.Method}}", "{{.Path}}", "/{{$svrName}}/{{.Name}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(ss, opt))
+	{{- end}}
 	{{- end}}
 }
 {{- else }}
@@ -93,12 +103,50 @@ func Register{{.ServiceType}}HTTPServer(conn *httprpc.ServerConn, srv {{.Service
 		o(opt)
 	}
 	{{- range .Methods}}
-	r.{{.Method}}("{{.Path}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv, opt))
+	{{- if or .IsStreamingClient .IsStreamingServer}}
+	r.HandleStream("{{.Method}}", "{{.Path}}", "/{{$svrName}}/{{.Name}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv, opt))
+	{{- else}}
+	r.HandleOperation("{{.Method}}", "{{.Path}}", "/{{$svrName}}/{{.Name}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv, opt))
+	{{- end}}
 	{{- end}}
 }
 {{- end}}
 
 {{range .Methods}}
+	{{- if .IsStreamingClient}}
+type {{.Name}}ServerStream struct {
+	httprpc.IServerStream
+}
+
+func New{{.Name}}ServerStream(stream httprpc.IServerStream) *{{.Name}}ServerStream {
+	return &{{.Name}}ServerStream{IServerStream: stream}
+}
+
+func (st *{{.Name}}ServerStream) Send(v *{{.Reply}}) error {
+	return st.SendMsg(v)
+}
+
+func (st *{{.Name}}ServerStream) Recv() (*{{.Request}}, error) {
+	v := new({{.Request}})
+	if err := st.RecvMsg(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler({{- if $serviceAsync}}srv *{{ $serviceWrapperName }}{{- else}}srv {{$svrType}}HTTPServer{{- end}}, opt *httprpc.ServiceOpt) func(ctx httprpc.Context) error {
+	return func(ctx httprpc.Context) error {
+		httprpc.SetOperation(ctx,"/{{$svrName}}/{{.Name}}")
+		return httprpc.ServeWebSocket(ctx, func(stream *httprpc.WebSocketStream) error {
+			h := ctx.Middleware(middleware.Chain(opt.Mw...)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, srv.{{.Name}}Stream(ctx, New{{.Name}}ServerStream(stream))
+			}))
+			_, err := h(stream.Context(), nil)
+			return err
+		})
+	}
+}
+	{{- else}}
 	{{- if .IsStreamingServer}}
 type {{.Name}}ServerStream struct {
 	httprpc.IServerStream
@@ -111,6 +159,10 @@ func New{{.Name}}ServerStream(stream httprpc.IServerStream) *{{.Name}}ServerStre
 func (st *{{.Name}}ServerStream) Send(v *{{.Reply}}) error {
 	return st.SendMsg(v)
 }
+
+func (st *{{.Name}}ServerStream) SendEvent(id, event string, v *{{.Reply}}) error {
+	return httprpc.SendEvent(st.IServerStream, id, event, v)
+}
 	{{- end}}
 
 func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler({{- if $serviceAsync}}srv *{{ $serviceWrapperName }}{{- else}}srv {{$svrType}}HTTPServer{{- end}}, opt *httprpc.ServiceOpt) func(ctx httprpc.Context) error {
@@ -138,16 +190,16 @@ func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler({{- if $serviceAsync}}srv *{{
 		{{- end}}
 		httprpc.SetOperation(ctx,"/{{$svrName}}/{{.Name}}")
 		{{- if .IsStreamingServer}}
-		stream := New{{.Name}}ServerStream(httprpc.NewHttpServerStream(ctx.Response(), httprpc.CodecForRequest(ctx.Request(), "Accept")))
-		h := ctx.Middleware(middleware.Chain(opt.Mw...)(func(ctx context.Context, req interface{}) (interface{}, error) {
-			err := srv.{{.Name}}Stream(ctx, req.(*{{.Request}}), stream)
-			if err != nil {
-				return nil, err
-			}
-			return nil, nil
-		}))
-		_, err := h(ctx, &in)
-		return err
+		return httprpc.ServeStream(ctx, func(stream httprpc.IServerStream) error {
+			h := ctx.Middleware(middleware.Chain(opt.Mw...)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				if err := httprpc.ResumeStream(ctx, stream); err != nil {
+					return nil, err
+				}
+				return nil, srv.{{.Name}}Stream(ctx, req.(*{{.Request}}), New{{.Name}}ServerStream(stream))
+			}))
+			_, err := h(ctx, &in)
+			return err
+		})
 		{{- else}}
 		h := ctx.Middleware(middleware.Chain(opt.Mw...)(func(ctx context.Context, req interface{}) (interface{}, error) {
 		{{- if .IsPublish}}
@@ -169,11 +221,14 @@ func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler({{- if $serviceAsync}}srv *{{
 		{{- end}}
 	}
 }
+	{{- end}}
 {{end}}
 
 type {{.ServiceType}}HTTPClient interface {
 {{- range .MethodSets}}
-	{{- if .IsStreamingServer}}
+	{{- if .IsStreamingClient}}
+	{{.Name}}Stream(ctx context.Context, opts ...httprpc.CallOption) (st *{{.Name}}ClientStream, err error)
+	{{- else if .IsStreamingServer}}
 	{{.Name}}Stream(ctx context.Context, req *{{.Request}}, opts ...httprpc.CallOption) (st *{{.Name}}ClientStream, err error)
 	{{- else }}
 	{{- if .IsPublish}}
@@ -194,7 +249,43 @@ func New{{.ServiceType}}HTTPClient (conn *httprpc.ClientConn) {{.ServiceType}}HT
 }
 
 {{range .MethodSets}}
-	{{- if .IsStreamingServer}}
+	{{- if .IsStreamingClient}}
+type {{.Name}}ClientStream struct {
+	stream httprpc.IClientStream
+}
+
+func New{{.Name}}ClientStream(stream httprpc.IClientStream) *{{.Name}}ClientStream {
+	return &{{.Name}}ClientStream{stream: stream}
+}
+
+func (s *{{.Name}}ClientStream) Send(v *{{.Request}}) error {
+	return s.stream.SendMsg(v)
+}
+
+func (s *{{.Name}}ClientStream) Recv() (*{{.Reply}}, error) {
+	v := new({{.Reply}})
+	if err := s.stream.RecvMsg(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *{{.Name}}ClientStream) CloseSend() error {
+	return s.stream.CloseSend()
+}
+
+func (c *{{$svrType}}HTTPClientImpl) {{.Name}}Stream(ctx context.Context, opts ...httprpc.CallOption) (*{{.Name}}ClientStream, error) {
+	pattern := "{{.Path}}"
+	opts = append(opts, httprpc.Operation("/{{$svrName}}/{{.Name}}"))
+	opts = append(opts, httprpc.PathTemplate(pattern))
+	opts = append(opts, httprpc.WebSocket())
+	stream, err := c.cc.Stream(ctx, "GET", pattern, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return New{{.Name}}ClientStream(stream), nil
+}
+	{{- else if .IsStreamingServer}}
 type {{.Name}}ClientStream struct {
 	recver httprpc.IClientStream
 }
@@ -290,6 +381,7 @@ type methodDesc struct {
 	Body              string
 	ResponseBody      string
 	IsStreamingServer bool
+	IsStreamingClient bool
 	IsPublish         bool
 	Async             bool
 }
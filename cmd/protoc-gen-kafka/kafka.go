@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/liuwangchen/toy/cmd/internal/genkey"
 	"github.com/liuwangchen/toy/transport/rpc/kafkarpc"
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/proto"
@@ -101,6 +103,16 @@ func buildMethodDesc(g *protogen.GeneratedFile, m *protogen.Method) *methodDesc
 	if ok {
 		method.Queue = queue
 	}
+	retry, ok := proto.GetExtension(m.Desc.Options(), kafkarpc.E_Retry).(*kafkarpc.RetryPolicy)
+	if ok && retry != nil && (retry.MaxAttempts > 0 || retry.Dlq) {
+		method.Retry = retry
+	}
+	key, err := genkey.Expr(g, m.Input, kafkarpc.E_Key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	method.Key = key
 	return method
 }
 
@@ -7,6 +7,7 @@ import (
 	"text/template"
 
 	"github.com/Masterminds/sprig"
+	"github.com/liuwangchen/toy/transport/rpc/kafkarpc"
 )
 
 var sTpl *template.Template
@@ -38,6 +39,8 @@ type methodDesc struct {
 	Reply   string
 	AutoAck bool
 	Queue   string
+	Retry   *kafkarpc.RetryPolicy
+	Key     string // 取消息key的表达式，为空表示不指定key
 }
 
 func (s *serviceDesc) execute() string {
@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/liuwangchen/toy/cmd/internal/genkey"
 	"github.com/liuwangchen/toy/transport/rpc"
 	"github.com/liuwangchen/toy/transport/rpc/natsrpc"
 	"google.golang.org/protobuf/compiler/protogen"
@@ -84,6 +86,14 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 		sd.Queue = queue
 	}
 
+	if proto.HasExtension(service.Desc.Options(), natsrpc.E_ServiceJetStream) {
+		sd.JetStream = proto.GetExtension(service.Desc.Options(), natsrpc.E_ServiceJetStream).(*natsrpc.JetStream)
+	}
+
+	if proto.HasExtension(service.Desc.Options(), natsrpc.E_ServiceConcurrency) {
+		sd.Concurrency = proto.GetExtension(service.Desc.Options(), natsrpc.E_ServiceConcurrency).(*natsrpc.Concurrency)
+	}
+
 	serviceAsync, ok := proto.GetExtension(service.Desc.Options(), rpc.E_ServiceAsync).(bool)
 	if ok {
 		sd.Async = serviceAsync
@@ -91,6 +101,7 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 
 	for _, method := range service.Methods {
 		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			sd.Streams = append(sd.Streams, buildStreamDesc(g, method))
 			continue
 		}
 
@@ -107,9 +118,26 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 		if methodDesc.Async {
 			sd.Async = true
 		}
+		if methodDesc.JetStream != nil {
+			sd.HasMethodJetStream = true
+		}
+		if methodDesc.Gather {
+			sd.HasMethodGather = true
+		}
+		if methodDesc.Concurrency != nil {
+			sd.HasMethodConcurrency = true
+		}
+		if methodDesc.Partition != nil {
+			sd.HasMethodPartition = true
+		}
 		sd.Methods = append(sd.Methods, methodDesc)
 	}
-	if len(sd.Methods) != 0 {
+	if sd.Async && len(sd.Streams) != 0 {
+		// 流在整个生命周期内收发消息，不能放到async里执行
+		fmt.Fprintf(os.Stderr, "async service %s can not have stream methods\n", service.Desc.FullName())
+		os.Exit(2)
+	}
+	if len(sd.Methods) != 0 || len(sd.Streams) != 0 {
 		g.P(sd.execute())
 	}
 }
@@ -150,9 +178,61 @@ func buildMethodDesc(g *protogen.GeneratedFile, m *protogen.Method) *methodDesc
 	if ok {
 		method.Sequence = sequence
 	}
+
+	if proto.HasExtension(m.Desc.Options(), natsrpc.E_Jetstream) {
+		if !method.Publish {
+			fmt.Fprintf(os.Stderr, "method %s with jetstream must return google.protobuf.Empty\n", m.Desc.FullName())
+			os.Exit(2)
+		}
+		method.JetStream = proto.GetExtension(m.Desc.Options(), natsrpc.E_Jetstream).(*natsrpc.JetStream)
+	}
+
+	gather, ok := proto.GetExtension(m.Desc.Options(), natsrpc.E_Gather).(bool)
+	if ok && gather {
+		if method.Publish {
+			fmt.Fprintf(os.Stderr, "method %s with gather must not return google.protobuf.Empty\n", m.Desc.FullName())
+			os.Exit(2)
+		}
+		method.Gather = true
+	}
+
+	if proto.HasExtension(m.Desc.Options(), natsrpc.E_MethodConcurrency) {
+		method.Concurrency = proto.GetExtension(m.Desc.Options(), natsrpc.E_MethodConcurrency).(*natsrpc.Concurrency)
+	}
+
+	if proto.HasExtension(m.Desc.Options(), natsrpc.E_Partition) {
+		method.Partition = proto.GetExtension(m.Desc.Options(), natsrpc.E_Partition).(*natsrpc.Partition)
+		if method.Sequence {
+			fmt.Fprintf(os.Stderr, "method %s can not be both sequence and partition\n", m.Desc.FullName())
+			os.Exit(2)
+		}
+		if len(method.Partition.Header) == 0 {
+			key, err := genkey.Expr(g, m.Input, natsrpc.E_PartitionKey)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			method.PartitionKey = key
+			if len(method.PartitionKey) == 0 {
+				fmt.Fprintf(os.Stderr, "method %s partition needs a header or a partitionKey field in %s\n", m.Desc.FullName(), m.Input.Desc.FullName())
+				os.Exit(2)
+			}
+		}
+	}
 	return method
 }
 
+// buildStreamDesc 流方法
+func buildStreamDesc(g *protogen.GeneratedFile, m *protogen.Method) *methodDesc {
+	return &methodDesc{
+		MethodName:      m.GoName,
+		Request:         g.QualifiedGoIdent(m.Input.GoIdent),
+		Reply:           g.QualifiedGoIdent(m.Output.GoIdent),
+		ClientStreaming: m.Desc.IsStreamingClient(),
+		ServerStreaming: m.Desc.IsStreamingServer(),
+	}
+}
+
 func camelCaseVars(s string) string {
 	subs := strings.Split(s, ".")
 	vars := make([]string, 0, len(subs))
@@ -7,6 +7,7 @@ import (
 	"text/template"
 
 	"github.com/Masterminds/sprig"
+	"github.com/liuwangchen/toy/transport/rpc/natsrpc"
 )
 
 var sTpl *template.Template
@@ -23,20 +24,27 @@ func init() {
 }
 
 type serviceDesc struct {
-	GoPackageName      string
-	ServiceType        string // Greeter
-	ServiceName        string // helloworld.Greeter
-	Metadata           string // api/helloworld/helloworld.proto
-	Methods            []*methodDesc
-	MethodSets         map[string]*methodDesc
-	Comment            string
-	Topic              string // topic
-	Queue              string // service级别的queue
-	HasServiceQueue    bool   // service级别的queue
-	HasMethodQueue     bool
-	HasMethodReqRespId bool
-	HasMethodSequence  bool
-	Async              bool
+	GoPackageName        string
+	ServiceType          string // Greeter
+	ServiceName          string // helloworld.Greeter
+	Metadata             string // api/helloworld/helloworld.proto
+	Methods              []*methodDesc
+	Streams              []*methodDesc // 流方法
+	MethodSets           map[string]*methodDesc
+	Comment              string
+	Topic                string // topic
+	Queue                string // service级别的queue
+	HasServiceQueue      bool   // service级别的queue
+	HasMethodQueue       bool
+	HasMethodReqRespId   bool
+	HasMethodSequence    bool
+	HasMethodJetStream   bool
+	HasMethodGather      bool
+	JetStream            *natsrpc.JetStream   // service级别的JetStream
+	Concurrency          *natsrpc.Concurrency // service级别的并发限制
+	HasMethodConcurrency bool
+	HasMethodPartition   bool
+	Async                bool
 }
 
 type methodDesc struct {
@@ -54,6 +62,14 @@ type methodDesc struct {
 	Async        bool
 	Sequence     bool
 	HasSequence  bool
+	JetStream    *natsrpc.JetStream   // 不为nil表示走JetStream
+	Gather       bool                 // 广播给所有实例，生成XxxGather
+	Concurrency  *natsrpc.Concurrency // 方法级别的并发限制
+	Partition    *natsrpc.Partition   // 按key分区
+	PartitionKey string               // 从请求in中取分区key的表达式，为空时用header
+	// stream
+	ClientStreaming bool
+	ServerStreaming bool
 }
 
 func (s *serviceDesc) execute() string {
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var (
+	showVersion = flag.Bool("version", false, "print the version and exit")
+	format      = flag.String("format", "yaml", "output format, yaml or json")
+	title       = flag.String("title", "", "info.title of the document, default is the proto package")
+	docVersion  = flag.String("doc_version", "0.0.1", "info.version of the document")
+)
+
+func main() {
+	flag.Parse()
+	if *showVersion {
+		fmt.Printf("protoc-gen-openapi %v\n", release)
+		return
+	}
+	protogen.Options{
+		ParamFunc: flag.CommandLine.Set,
+	}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		for _, f := range gen.Files {
+			if !f.Generate {
+				continue
+			}
+			if err := generateFile(gen, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/liuwangchen/toy/transport/rpc/httprpc"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	schemaRefPrefix = "#/components/schemas/"
+	statusSchema    = "Status"
+	contentJSON     = "application/json"
+)
+
+var pathVarPattern = regexp.MustCompile(`(?i){([a-z\.0-9_\s]*)=?([^{}]*)}`)
+
+type document struct {
+	OpenAPI    string               `json:"openapi" yaml:"openapi"`
+	Info       *info                `json:"info" yaml:"info"`
+	Tags       []*tag               `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths      map[string]*pathItem `json:"paths" yaml:"paths"`
+	Components *components          `json:"components" yaml:"components"`
+}
+
+type info struct {
+	Title   string `json:"title" yaml:"title"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type tag struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type components struct {
+	Schemas map[string]*schema `json:"schemas" yaml:"schemas"`
+}
+
+type pathItem struct {
+	Get    *operation `json:"get,omitempty" yaml:"get,omitempty"`
+	Put    *operation `json:"put,omitempty" yaml:"put,omitempty"`
+	Post   *operation `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete *operation `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Patch  *operation `json:"patch,omitempty" yaml:"patch,omitempty"`
+	Head   *operation `json:"head,omitempty" yaml:"head,omitempty"`
+	Other  *operation `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+type operation struct {
+	Tags        []string             `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string               `json:"description,omitempty" yaml:"description,omitempty"`
+	OperationID string               `json:"operationId" yaml:"operationId"`
+	Parameters  []*parameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses" yaml:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name" yaml:"name"`
+	In          string  `json:"in" yaml:"in"`
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema      *schema `json:"schema" yaml:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*mediaType `json:"content" yaml:"content"`
+}
+
+type response struct {
+	Description string                `json:"description" yaml:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema" yaml:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Items                *schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+}
+
+// generateFile 每个带service的proto文件生成一份文档
+func generateFile(gen *protogen.Plugin, file *protogen.File) error {
+	g := newGenerator(file)
+	for _, service := range file.Services {
+		if err := g.addService(service); err != nil {
+			return err
+		}
+	}
+	if len(g.doc.Paths) == 0 {
+		return nil
+	}
+
+	var (
+		b   []byte
+		err error
+		ext = ".openapi.yaml"
+	)
+	if *format == "json" {
+		ext = ".openapi.json"
+		b, err = json.MarshalIndent(g.doc, "", "  ")
+	} else {
+		b, err = yaml.Marshal(g.doc)
+	}
+	if err != nil {
+		return err
+	}
+	f := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+ext, "")
+	_, err = f.Write(b)
+	return err
+}
+
+type generator struct {
+	doc *document
+}
+
+func newGenerator(file *protogen.File) *generator {
+	t := *title
+	if len(t) == 0 {
+		t = string(file.Desc.Package())
+	}
+	return &generator{doc: &document{
+		OpenAPI: "3.0.3",
+		Info:    &info{Title: t, Version: *docVersion},
+		Paths:   map[string]*pathItem{},
+		Components: &components{Schemas: map[string]*schema{
+			statusSchema: {
+				Type: "object",
+				Properties: map[string]*schema{
+					"code":     {Type: "integer", Format: "int32", Description: "错误码，与http status一致"},
+					"reason":   {Type: "string", Description: "错误原因，业务定义的枚举"},
+					"message":  {Type: "string", Description: "错误信息"},
+					"metadata": {Type: "object", AdditionalProperties: &schema{Type: "string"}, Description: "错误元数据"},
+				},
+			},
+		}},
+	}}
+}
+
+// addService 和protoc-gen-http的路由规则一致，没有HttpRule的方法为POST /package.Service/Method
+func (g *generator) addService(service *protogen.Service) error {
+	name := string(service.Desc.FullName())
+	added := false
+	for _, method := range service.Methods {
+		// 客户端流走websocket，openapi无法描述
+		if method.Desc.IsStreamingClient() {
+			continue
+		}
+		rule, ok := proto.GetExtension(method.Desc.Options(), httprpc.E_Rule).(*httprpc.HttpRule)
+		if rule == nil || !ok {
+			path := fmt.Sprintf("/%s/%s", name, method.Desc.Name())
+			if err := g.addOperation(service, method, "POST", path, "*", "", 0); err != nil {
+				return err
+			}
+			added = true
+			continue
+		}
+		verb, path := ruleOf(rule)
+		if err := g.addOperation(service, method, verb, path, rule.Body, rule.ResponseBody, 0); err != nil {
+			return err
+		}
+		for i, bind := range rule.AdditionalBindings {
+			verb, path := ruleOf(bind)
+			if err := g.addOperation(service, method, verb, path, bind.Body, bind.ResponseBody, i+1); err != nil {
+				return err
+			}
+		}
+		added = true
+	}
+	if added {
+		g.doc.Tags = append(g.doc.Tags, &tag{Name: name, Description: comment(service.Comments.Leading)})
+	}
+	return nil
+}
+
+func ruleOf(rule *httprpc.HttpRule) (string, string) {
+	switch pattern := rule.Pattern.(type) {
+	case *httprpc.HttpRule_Get:
+		return "GET", pattern.Get
+	case *httprpc.HttpRule_Put:
+		return "PUT", pattern.Put
+	case *httprpc.HttpRule_Post:
+		return "POST", pattern.Post
+	case *httprpc.HttpRule_Delete:
+		return "DELETE", pattern.Delete
+	case *httprpc.HttpRule_Patch:
+		return "PATCH", pattern.Patch
+	case *httprpc.HttpRule_Custom:
+		return strings.ToUpper(pattern.Custom.Kind), pattern.Custom.Path
+	}
+	return "", ""
+}
+
+// addOperation 添加一个绑定，同一个verb和path被多次绑定时返回错误
+func (g *generator) addOperation(service *protogen.Service, method *protogen.Method, verb, path, body, responseBody string, binding int) error {
+	vars := pathVars(path)
+	op := &operation{
+		Tags:        []string{string(service.Desc.FullName())},
+		Description: comment(method.Comments.Leading),
+		OperationID: fmt.Sprintf("%s_%s", service.GoName, method.GoName),
+		Responses:   map[string]*response{},
+		Deprecated:  method.Desc.Options().(*descriptorpb.MethodOptions).GetDeprecated(),
+	}
+	if binding > 0 {
+		op.OperationID = fmt.Sprintf("%s_%d", op.OperationID, binding)
+	}
+	if i := strings.Index(op.Description, "\n"); i > 0 {
+		op.Summary = op.Description[:i]
+	} else {
+		op.Summary, op.Description = op.Description, ""
+	}
+
+	// path参数
+	bound := map[string]bool{}
+	for _, v := range vars {
+		f := fieldByPath(method.Input, v)
+		if f == nil {
+			return fmt.Errorf("the corresponding field '%s' declaration in message could not be found in '%s'", v, path)
+		}
+		bound[v] = true
+		op.Parameters = append(op.Parameters, &parameter{
+			Name:        v,
+			In:          "path",
+			Description: fieldComment(f),
+			Required:    true,
+			Schema:      g.singularSchema(f),
+		})
+	}
+
+	// body和query参数，body为*时所有字段都在body中
+	switch body {
+	case "*":
+		op.RequestBody = &requestBody{Required: true, Content: jsonContent(g.messageRef(method.Input))}
+	case "":
+		op.Parameters = append(op.Parameters, g.queryParams("", "", method.Input, bound, "", map[protoreflect.FullName]bool{})...)
+	default:
+		f := fieldByPath(method.Input, body)
+		if f == nil {
+			return fmt.Errorf("the body field '%s' declaration in message could not be found in '%s'", body, path)
+		}
+		op.RequestBody = &requestBody{Required: true, Content: jsonContent(g.fieldSchema(f))}
+		op.Parameters = append(op.Parameters, g.queryParams("", "", method.Input, bound, body, map[protoreflect.FullName]bool{})...)
+	}
+
+	// 回复
+	rsp := &response{Description: "OK"}
+	if method.Output.Desc.FullName() != "google.protobuf.Empty" {
+		var s *schema
+		if len(responseBody) > 0 && responseBody != "*" {
+			f := fieldByPath(method.Output, responseBody)
+			if f == nil {
+				return fmt.Errorf("the response body field '%s' declaration in message could not be found in '%s'", responseBody, path)
+			}
+			s = g.fieldSchema(f)
+		} else {
+			s = g.messageRef(method.Output)
+		}
+		rsp.Content = jsonContent(s)
+		if method.Desc.IsStreamingServer() {
+			rsp.Description = "Stream of " + string(method.Output.Desc.FullName())
+		}
+	}
+	op.Responses["200"] = rsp
+	op.Responses["default"] = &response{Description: "Error", Content: jsonContent(&schema{Ref: schemaRefPrefix + statusSchema})}
+
+	p := openAPIPath(path)
+	item, ok := g.doc.Paths[p]
+	if !ok {
+		item = &pathItem{}
+		g.doc.Paths[p] = item
+	}
+	slot := item.operation(verb)
+	if slot == nil {
+		fmt.Fprintf(os.Stderr, "\u001B[31mWARN\u001B[m: %s %s is not supported by openapi.\n", verb, path)
+		return nil
+	}
+	if *slot != nil {
+		return fmt.Errorf("%s %s is bound by both %s and %s", verb, p, (*slot).OperationID, op.OperationID)
+	}
+	*slot = op
+	return nil
+}
+
+// operation verb对应的operation，不支持的verb返回nil
+func (item *pathItem) operation(verb string) **operation {
+	switch verb {
+	case "GET":
+		return &item.Get
+	case "PUT":
+		return &item.Put
+	case "POST":
+		return &item.Post
+	case "DELETE":
+		return &item.Delete
+	case "PATCH":
+		return &item.Patch
+	case "HEAD":
+		return &item.Head
+	case "OPTIONS":
+		return &item.Other
+	}
+	return nil
+}
+
+// queryParams 没有绑定到path和body的字段，嵌套message展开成a.b
+// protoPrefix按proto字段名匹配绑定，jsonPrefix是参数名的前缀
+func (g *generator) queryParams(protoPrefix, jsonPrefix string, m *protogen.Message, bound map[string]bool, body string, visited map[protoreflect.FullName]bool) []*parameter {
+	visited[m.Desc.FullName()] = true
+	defer delete(visited, m.Desc.FullName())
+
+	var params []*parameter
+	for _, f := range m.Fields {
+		protoPath := protoPrefix + string(f.Desc.Name())
+		if bound[protoPath] || protoPath == body || f.Desc.IsMap() {
+			continue
+		}
+		name := jsonPrefix + f.Desc.JSONName()
+		if f.Message != nil && wellKnownSchema(f.Message.Desc) == nil {
+			if f.Desc.IsList() || visited[f.Message.Desc.FullName()] {
+				continue
+			}
+			params = append(params, g.queryParams(protoPath+".", name+".", f.Message, bound, body, visited)...)
+			continue
+		}
+		s := g.singularSchema(f)
+		if f.Desc.IsList() {
+			s = &schema{Type: "array", Items: s}
+		}
+		params = append(params, &parameter{
+			Name:        name,
+			In:          "query",
+			Description: fieldComment(f),
+			Schema:      s,
+		})
+	}
+	return params
+}
+
+// messageRef 引用components中的schema，第一次引用时生成
+func (g *generator) messageRef(m *protogen.Message) *schema {
+	if s := wellKnownSchema(m.Desc); s != nil {
+		return s
+	}
+	name := string(m.Desc.FullName())
+	if _, ok := g.doc.Components.Schemas[name]; !ok {
+		s := &schema{
+			Type:        "object",
+			Description: comment(m.Comments.Leading),
+			Properties:  map[string]*schema{},
+		}
+		// 先占位，支持递归引用
+		g.doc.Components.Schemas[name] = s
+		for _, f := range m.Fields {
+			s.Properties[f.Desc.JSONName()] = g.fieldSchema(f)
+		}
+	}
+	return &schema{Ref: schemaRefPrefix + name}
+}
+
+// fieldSchema 带注释的字段schema
+func (g *generator) fieldSchema(f *protogen.Field) *schema {
+	var s *schema
+	switch {
+	case f.Desc.IsMap():
+		s = &schema{Type: "object", AdditionalProperties: g.singularSchema(f.Message.Fields[1])}
+	case f.Desc.IsList():
+		s = &schema{Type: "array", Items: g.singularSchema(f)}
+	default:
+		s = g.singularSchema(f)
+	}
+	desc := fieldComment(f)
+	if len(desc) == 0 {
+		return s
+	}
+	// $ref不能有其他字段
+	if len(s.Ref) > 0 {
+		return &schema{AllOf: []*schema{s}, Description: desc}
+	}
+	c := *s
+	c.Description = desc
+	return &c
+}
+
+// singularSchema 字段单个值的schema，和protojson的编码一致
+func (g *generator) singularSchema(f *protogen.Field) *schema {
+	switch f.Desc.Kind() {
+	case protoreflect.BoolKind:
+		return &schema{Type: "boolean"}
+	case protoreflect.StringKind:
+		return &schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &schema{Type: "string", Format: "byte"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &schema{Type: "integer", Format: "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &schema{Type: "string", Format: "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return &schema{Type: "string", Format: "uint64"}
+	case protoreflect.FloatKind:
+		return &schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &schema{Type: "number", Format: "double"}
+	case protoreflect.EnumKind:
+		s := &schema{Type: "string"}
+		for _, v := range f.Enum.Values {
+			s.Enum = append(s.Enum, string(v.Desc.Name()))
+		}
+		return s
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return g.messageRef(f.Message)
+	}
+	return &schema{}
+}
+
+// wellKnownSchema google.protobuf中有特殊json编码的类型
+func wellKnownSchema(m protoreflect.MessageDescriptor) *schema {
+	switch m.FullName() {
+	case "google.protobuf.Timestamp":
+		return &schema{Type: "string", Format: "date-time"}
+	case "google.protobuf.Duration":
+		return &schema{Type: "string", Format: "duration"}
+	case "google.protobuf.FieldMask":
+		return &schema{Type: "string", Format: "field-mask"}
+	case "google.protobuf.Empty", "google.protobuf.Struct":
+		return &schema{Type: "object"}
+	case "google.protobuf.Any":
+		return &schema{Type: "object", Properties: map[string]*schema{"@type": {Type: "string"}}}
+	case "google.protobuf.Value":
+		return &schema{}
+	case "google.protobuf.ListValue":
+		return &schema{Type: "array", Items: &schema{}}
+	case "google.protobuf.DoubleValue":
+		return &schema{Type: "number", Format: "double"}
+	case "google.protobuf.FloatValue":
+		return &schema{Type: "number", Format: "float"}
+	case "google.protobuf.Int64Value":
+		return &schema{Type: "string", Format: "int64"}
+	case "google.protobuf.UInt64Value":
+		return &schema{Type: "string", Format: "uint64"}
+	case "google.protobuf.Int32Value":
+		return &schema{Type: "integer", Format: "int32"}
+	case "google.protobuf.UInt32Value":
+		return &schema{Type: "integer", Format: "uint32"}
+	case "google.protobuf.BoolValue":
+		return &schema{Type: "boolean"}
+	case "google.protobuf.StringValue":
+		return &schema{Type: "string"}
+	case "google.protobuf.BytesValue":
+		return &schema{Type: "string", Format: "byte"}
+	}
+	return nil
+}
+
+// fieldByPath 按a.b取字段
+func fieldByPath(m *protogen.Message, path string) *protogen.Field {
+	var f *protogen.Field
+	for _, name := range strings.Split(path, ".") {
+		if m == nil {
+			return nil
+		}
+		f = nil
+		for _, field := range m.Fields {
+			if string(field.Desc.Name()) == strings.TrimSpace(name) {
+				f = field
+				break
+			}
+		}
+		if f == nil {
+			return nil
+		}
+		m = f.Message
+	}
+	return f
+}
+
+// pathVars 按出现顺序返回path中的变量
+func pathVars(path string) []string {
+	var vars []string
+	for _, m := range pathVarPattern.FindAllStringSubmatch(path, -1) {
+		vars = append(vars, strings.TrimSpace(m[1]))
+	}
+	return vars
+}
+
+// openAPIPath {name=messages/*}转成{name}
+func openAPIPath(path string) string {
+	return pathVarPattern.ReplaceAllStringFunc(path, func(s string) string {
+		return "{" + strings.TrimSpace(pathVarPattern.FindStringSubmatch(s)[1]) + "}"
+	})
+}
+
+func jsonContent(s *schema) map[string]*mediaType {
+	return map[string]*mediaType{contentJSON: {Schema: s}}
+}
+
+// comment 去掉每行//后的空格
+func comment(c protogen.Comments) string {
+	lines := strings.Split(strings.TrimSpace(string(c)), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fieldComment 字段的前置注释，没有时用行尾注释
+func fieldComment(f *protogen.Field) string {
+	if c := comment(f.Comments.Leading); len(c) > 0 {
+		return c
+	}
+	return comment(f.Comments.Trailing)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/liuwangchen/toy/transport/rpc/httprpc"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := map[string]string{
+		"/test/noparams":                          "/test/noparams",
+		"/test/{message.id}":                      "/test/{message.id}",
+		"/test/{ id }/{message.name=messages/*}":  "/test/{id}/{message.name}",
+		"/test/{message.name=messages/*}/books":   "/test/{message.name}/books",
+		"/test/{shop_id}/items/{item.id=items/*}": "/test/{shop_id}/items/{item.id}",
+	}
+	for path, want := range tests {
+		if got := openAPIPath(path); got != want {
+			t.Fatalf("%s: expect %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestPathVars(t *testing.T) {
+	vars := pathVars("/test/{b}/{a.id=messages/*}/{c}")
+	if !reflect.DeepEqual(vars, []string{"b", "a.id", "c"}) {
+		t.Fatalf("unexpected vars %v", vars)
+	}
+}
+
+func TestComment(t *testing.T) {
+	if c := comment(protogen.Comments(" line1\n line2\n")); c != "line1\nline2" {
+		t.Fatalf("unexpected comment %q", c)
+	}
+}
+
+func TestWellKnownSchema(t *testing.T) {
+	if s := wellKnownSchema((&timestamppb.Timestamp{}).ProtoReflect().Descriptor()); s == nil || s.Format != "date-time" {
+		t.Fatalf("unexpected timestamp schema %+v", s)
+	}
+	if s := wellKnownSchema((&wrapperspb.Int64Value{}).ProtoReflect().Descriptor()); s == nil || s.Type != "string" {
+		t.Fatalf("unexpected int64 value schema %+v", s)
+	}
+	if s := wellKnownSchema((&httprpc.HttpRule{}).ProtoReflect().Descriptor()); s != nil {
+		t.Fatalf("expect no well known schema, got %+v", s)
+	}
+}
+
+// newTestFile 构造test.proto
+//
+//	message Inner { string id = 1; int32 n = 2; }
+//	message Req { string id = 1; Inner inner = 2; repeated string tags = 3; Inner body = 4; }
+//	message Rsp { Inner data = 1; }
+//	service Svc { rpc Xxx(Req) returns (Rsp) { option (httprpc.rule) = rules[Xxx]; } }
+func newTestFile(t *testing.T, rules map[string]*httprpc.HttpRule, order ...string) *protogen.File {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if repeated {
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+		if len(typeName) > 0 {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i32 = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String("Svc")}
+	for _, name := range order {
+		opts := &descriptorpb.MethodOptions{}
+		proto.SetExtension(opts, httprpc.E_Rule, rules[name])
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".test.Req"),
+			OutputType: proto.String(".test.Rsp"),
+			Options:    opts,
+		})
+	}
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/test;test")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Inner"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, str, "", false),
+				field("n", 2, i32, "", false),
+			}},
+			{Name: proto.String("Req"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, str, "", false),
+				field("inner", 2, msg, ".test.Inner", false),
+				field("tags", 3, str, "", true),
+				field("body", 4, msg, ".test.Inner", false),
+			}},
+			{Name: proto.String("Rsp"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("data", 1, msg, ".test.Inner", false),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{service},
+	}
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"test.proto"},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{fd},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen.Files[0]
+}
+
+func paramNames(op *operation) []string {
+	var names []string
+	for _, p := range op.Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	return names
+}
+
+func TestAddService(t *testing.T) {
+	file := newTestFile(t, map[string]*httprpc.HttpRule{
+		"Get": {
+			Pattern:      &httprpc.HttpRule_Get{Get: "/v1/{id}"},
+			ResponseBody: "data",
+			AdditionalBindings: []*httprpc.HttpRule{
+				{Pattern: &httprpc.HttpRule_Post{Post: "/v1/{id=items/*}/body"}, Body: "body"},
+			},
+		},
+		"Create": {Pattern: &httprpc.HttpRule_Post{Post: "/v1"}, Body: "*"},
+	}, "Get", "Create")
+	g := newGenerator(file)
+	if err := g.addService(file.Services[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有body时path之外的字段都是query，嵌套message展开
+	get := g.doc.Paths["/v1/{id}"].Get
+	if get == nil || get.OperationID != "Svc_Get" || get.RequestBody != nil {
+		t.Fatalf("unexpected get %+v", get)
+	}
+	if names := paramNames(get); !reflect.DeepEqual(names, []string{"path:id", "query:inner.id", "query:inner.n", "query:tags", "query:body.id", "query:body.n"}) {
+		t.Fatalf("unexpected get params %v", names)
+	}
+	if s := get.Parameters[3].Schema; s.Type != "array" || s.Items.Type != "string" {
+		t.Fatalf("unexpected repeated param schema %+v", s)
+	}
+	// response_body
+	if s := get.Responses["200"].Content[contentJSON].Schema; s.Ref != schemaRefPrefix+"test.Inner" {
+		t.Fatalf("unexpected get response %+v", s)
+	}
+
+	// additional binding，body字段不再出现在query中
+	post := g.doc.Paths["/v1/{id}/body"].Post
+	if post == nil || post.OperationID != "Svc_Get_1" {
+		t.Fatalf("unexpected additional binding %+v", post)
+	}
+	if names := paramNames(post); !reflect.DeepEqual(names, []string{"path:id", "query:inner.id", "query:inner.n", "query:tags"}) {
+		t.Fatalf("unexpected binding params %v", names)
+	}
+	if s := post.RequestBody.Content[contentJSON].Schema; s.Ref != schemaRefPrefix+"test.Inner" {
+		t.Fatalf("unexpected binding body %+v", s)
+	}
+	if s := post.Responses["200"].Content[contentJSON].Schema; s.Ref != schemaRefPrefix+"test.Rsp" {
+		t.Fatalf("unexpected binding response %+v", s)
+	}
+
+	// body为*时没有query参数
+	create := g.doc.Paths["/v1"].Post
+	if create == nil || len(create.Parameters) != 0 || create.RequestBody.Content[contentJSON].Schema.Ref != schemaRefPrefix+"test.Req" {
+		t.Fatalf("unexpected create %+v", create)
+	}
+	for _, name := range []string{"test.Inner", "test.Req", "test.Rsp", statusSchema} {
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			t.Fatalf("expect schema %s", name)
+		}
+	}
+}
+
+func TestAddServiceDuplicate(t *testing.T) {
+	file := newTestFile(t, map[string]*httprpc.HttpRule{
+		"Get":   {Pattern: &httprpc.HttpRule_Get{Get: "/v1/{id}"}},
+		"Other": {Pattern: &httprpc.HttpRule_Get{Get: "/v1/{id=items/*}"}},
+	}, "Get", "Other")
+	err := newGenerator(file).addService(file.Services[0])
+	if err == nil || !strings.Contains(err.Error(), "Svc_Get") || !strings.Contains(err.Error(), "Svc_Other") {
+		t.Fatalf("expect duplicate binding error, got %v", err)
+	}
+}
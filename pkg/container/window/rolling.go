@@ -0,0 +1,83 @@
+package window
+
+import (
+	"time"
+)
+
+// Bucket 一个时间段内的统计
+type Bucket struct {
+	Sum   int64
+	Count int64
+}
+
+// Rolling 滑动窗口，窗口按时间分成多个bucket滚动，不是并发安全的
+type Rolling struct {
+	buckets []Bucket
+	width   time.Duration
+	offset  int
+	last    time.Time // 当前bucket的起始时间
+}
+
+// NewRolling 窗口长度size分成n个bucket，n<=0时为1，bucket宽度至少1ns
+func NewRolling(size time.Duration, n int, now time.Time) *Rolling {
+	if n <= 0 {
+		n = 1
+	}
+	width := size / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	return &Rolling{
+		buckets: make([]Bucket, n),
+		width:   width,
+		last:    now,
+	}
+}
+
+// Width bucket的宽度
+func (r *Rolling) Width() time.Duration {
+	return r.width
+}
+
+// advance 滚动到now所在的bucket，过期的bucket清零
+func (r *Rolling) advance(now time.Time) {
+	span := int(now.Sub(r.last) / r.width)
+	if span <= 0 {
+		return
+	}
+	r.last = r.last.Add(time.Duration(span) * r.width)
+	if span > len(r.buckets) {
+		span = len(r.buckets)
+	}
+	for i := 0; i < span; i++ {
+		r.offset = (r.offset + 1) % len(r.buckets)
+		r.buckets[r.offset] = Bucket{}
+	}
+}
+
+// Add 当前bucket加上v，计数加1
+func (r *Rolling) Add(now time.Time, v int64) {
+	r.advance(now)
+	r.buckets[r.offset].Sum += v
+	r.buckets[r.offset].Count++
+}
+
+// Reduce 遍历窗口内的bucket，skipCurrent时跳过尚未结束的当前bucket
+func (r *Rolling) Reduce(now time.Time, skipCurrent bool, f func(b Bucket)) {
+	r.advance(now)
+	for i, b := range r.buckets {
+		if skipCurrent && i == r.offset {
+			continue
+		}
+		f(b)
+	}
+}
+
+// Reset 清空所有bucket，从now重新开始
+func (r *Rolling) Reset(now time.Time) {
+	for i := range r.buckets {
+		r.buckets[i] = Bucket{}
+	}
+	r.offset = 0
+	r.last = now
+}
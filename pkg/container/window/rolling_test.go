@@ -0,0 +1,50 @@
+package window
+
+import (
+	"testing"
+	"time"
+)
+
+func sum(r *Rolling, now time.Time, skipCurrent bool) (s, c int64) {
+	r.Reduce(now, skipCurrent, func(b Bucket) {
+		s += b.Sum
+		c += b.Count
+	})
+	return
+}
+
+func TestRolling(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewRolling(time.Second, 10, now)
+	r.Add(now, 2)
+	r.Add(now.Add(150*time.Millisecond), 3)
+	if s, c := sum(r, now.Add(150*time.Millisecond), false); s != 5 || c != 2 {
+		t.Fatalf("sum %d count %d", s, c)
+	}
+	if s, _ := sum(r, now.Add(150*time.Millisecond), true); s != 2 {
+		t.Fatalf("skip current sum %d", s)
+	}
+	// 第一个bucket滚出窗口
+	if s, _ := sum(r, now.Add(time.Second), false); s != 3 {
+		t.Fatalf("sum %d", s)
+	}
+	if s, _ := sum(r, now.Add(time.Hour), false); s != 0 {
+		t.Fatalf("sum %d", s)
+	}
+	r.Add(now.Add(time.Hour), 1)
+	r.Reset(now.Add(time.Hour))
+	if _, c := sum(r, now.Add(time.Hour), false); c != 0 {
+		t.Fatalf("count %d after reset", c)
+	}
+}
+
+func TestRollingSmallWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	for _, r := range []*Rolling{NewRolling(0, 10, now), NewRolling(5, 10, now), NewRolling(time.Second, 0, now)} {
+		r.Add(now, 1)
+		r.Add(now.Add(time.Millisecond), 1)
+		if _, c := sum(r, now.Add(time.Millisecond), false); c < 1 {
+			t.Fatalf("count %d", c)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/liuwangchen/toy/transport/rpc/httprpc/status"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestError(t *testing.T) {
+	var base *Error
+	err := Newf(http.StatusBadRequest, "reason", "message")
+	err2 := Newf(http.StatusBadRequest, "reason", "message")
+	err3 := err.WithMetadata(map[string]string{
+		"foo": "bar",
+	})
+	werr := fmt.Errorf("wrap %w", err)
+
+	if errors.Is(err, new(Error)) {
+		t.Errorf("should not be equal: %v", err)
+	}
+	if !errors.Is(werr, err) {
+		t.Errorf("should be equal: %v", err)
+	}
+	if !errors.Is(werr, err2) {
+		t.Errorf("should be equal: %v", err)
+	}
+	if !errors.As(err, &base) {
+		t.Errorf("should be matches: %v", err)
+	}
+	if !IsBadRequest(err) {
+		t.Errorf("should be matches: %v", err)
+	}
+	if reason := Reason(err); reason != err3.Reason {
+		t.Errorf("got %s want: %s", reason, err)
+	}
+	if err3.Metadata["foo"] != "bar" {
+		t.Error("not expected metadata")
+	}
+	if err.Metadata != nil {
+		t.Error("WithMetadata should not modify the origin error")
+	}
+
+	cause := errors.New("cause")
+	err4 := err.WithCause(cause)
+	if !errors.Is(err4, cause) {
+		t.Errorf("should be matches cause: %v", err4)
+	}
+	if err.Unwrap() != nil {
+		t.Error("WithCause should not modify the origin error")
+	}
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	err := NotFound("USER_NOT_FOUND", "user 1 not found").WithMetadata(map[string]string{"id": "1"})
+	gs := err.GRPCStatus()
+	if gs.Code() != codes.NotFound {
+		t.Fatalf("expect %v, got %v", codes.NotFound, gs.Code())
+	}
+	// 模拟经过grpc传输
+	se := FromError(gs.Err())
+	if !proto.Equal(&err.Status, &se.Status) {
+		t.Errorf("expect %v, got %v", &err.Status, &se.Status)
+	}
+	if !errors.Is(se, NotFound("USER_NOT_FOUND", "")) {
+		t.Errorf("should be matches: %v", se)
+	}
+
+	// 非标准的code也不会丢失
+	err = New(418, "TEAPOT", "teapot")
+	se = FromError(err.GRPCStatus().Err())
+	if se.Code != 418 || se.Reason != "TEAPOT" {
+		t.Errorf("expect %v, got %v", err, se)
+	}
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   int
+		reason string
+	}{
+		{"plain", errors.New("plain"), UnknownCode, UnknownReason},
+		{"wrapped", fmt.Errorf("wrap: %w", Conflict("DUP", "dup")), http.StatusConflict, "DUP"},
+		{"canceled", context.Canceled, status.ClientClosed, UnknownReason},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, UnknownReason},
+		{"grpc", grpcstatus.Error(codes.Unavailable, "unavailable"), http.StatusServiceUnavailable, UnknownReason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			se := FromError(tt.err)
+			if int(se.Code) != tt.code {
+				t.Errorf("expect %v, got %v", tt.code, se.Code)
+			}
+			if se.Reason != tt.reason {
+				t.Errorf("expect %v, got %v", tt.reason, se.Reason)
+			}
+		})
+	}
+	if FromError(nil) != nil {
+		t.Error("FromError(nil) should be nil")
+	}
+	if Code(nil) != http.StatusOK {
+		t.Errorf("expect %v, got %v", http.StatusOK, Code(nil))
+	}
+}
@@ -36,8 +36,8 @@ func RegisterGreeterHTTPServer(conn *httprpc.ServerConn, srv GreeterHTTPServer,
 	for _, o := range opts {
 		o(opt)
 	}
-	r.POST("/helloworld.Greeter/SayHello", _Greeter_SayHello0_HTTP_Handler(srv, opt))
-	r.POST("/helloworld.Greeter/MultiSayHello", _Greeter_MultiSayHello0_HTTP_Handler(srv, opt))
+	r.HandleOperation("POST", "/helloworld.Greeter/SayHello", "/helloworld.Greeter/SayHello", _Greeter_SayHello0_HTTP_Handler(srv, opt))
+	r.HandleStream("POST", "/helloworld.Greeter/MultiSayHello", "/helloworld.Greeter/MultiSayHello", _Greeter_MultiSayHello0_HTTP_Handler(srv, opt))
 }
 
 func _Greeter_SayHello0_HTTP_Handler(srv GreeterHTTPServer, opt *httprpc.ServiceOpt) func(ctx httprpc.Context) error {
@@ -71,6 +71,10 @@ func (st *MultiSayHelloServerStream) Send(v *HelloReply) error {
 	return st.SendMsg(v)
 }
 
+func (st *MultiSayHelloServerStream) SendEvent(id, event string, v *HelloReply) error {
+	return httprpc.SendEvent(st.IServerStream, id, event, v)
+}
+
 func _Greeter_MultiSayHello0_HTTP_Handler(srv GreeterHTTPServer, opt *httprpc.ServiceOpt) func(ctx httprpc.Context) error {
 	return func(ctx httprpc.Context) error {
 		var in HelloRequest
@@ -78,16 +82,16 @@ func _Greeter_MultiSayHello0_HTTP_Handler(srv GreeterHTTPServer, opt *httprpc.Se
 			return err
 		}
 		httprpc.SetOperation(ctx, "/helloworld.Greeter/MultiSayHello")
-		stream := NewMultiSayHelloServerStream(httprpc.NewHttpServerStream(ctx.Response(), httprpc.CodecForRequest(ctx.Request(), "Accept")))
-		h := ctx.Middleware(middleware.Chain(opt.Mw...)(func(ctx context.Context, req interface{}) (interface{}, error) {
-			err := srv.MultiSayHelloStream(ctx, req.(*HelloRequest), stream)
-			if err != nil {
-				return nil, err
-			}
-			return nil, nil
-		}))
-		_, err := h(ctx, &in)
-		return err
+		return httprpc.ServeStream(ctx, func(stream httprpc.IServerStream) error {
+			h := ctx.Middleware(middleware.Chain(opt.Mw...)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				if err := httprpc.ResumeStream(ctx, stream); err != nil {
+					return nil, err
+				}
+				return nil, srv.MultiSayHelloStream(ctx, req.(*HelloRequest), NewMultiSayHelloServerStream(stream))
+			}))
+			_, err := h(ctx, &in)
+			return err
+		})
 	}
 }
 
@@ -156,7 +160,7 @@ func RegisterPusherHTTPServer(conn *httprpc.ServerConn, srv PusherHTTPServer, op
 	for _, o := range opts {
 		o(opt)
 	}
-	r.POST("/helloworld.Pusher/Push", _Pusher_Push0_HTTP_Handler(srv, opt))
+	r.HandleOperation("POST", "/helloworld.Pusher/Push", "/helloworld.Pusher/Push", _Pusher_Push0_HTTP_Handler(srv, opt))
 }
 
 func _Pusher_Push0_HTTP_Handler(srv PusherHTTPServer, opt *httprpc.ServiceOpt) func(ctx httprpc.Context) error {
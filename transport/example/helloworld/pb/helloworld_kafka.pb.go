@@ -42,7 +42,12 @@ func RegisterPusherKafkaServer(s *kafkarpc.Server, srv PusherKafkaServer) {
 		}
 		_, err = h(ctx, msg)
 		return err
-	}, true, "")
+	}, true, "", kafkarpc.WithRetryPolicy(&kafkarpc.RetryPolicy{
+		MaxAttempts:  3,
+		BackoffMs:    100,
+		MaxBackoffMs: 1000,
+		Dlq:          true,
+	}))
 }
 
 type PusherKafkaClient interface {
@@ -58,5 +63,5 @@ func NewPusherKafkaClient(client *kafkarpc.Client) PusherKafkaClient {
 }
 
 func (c *PusherKafkaClientImpl) Push(ctx context.Context, in *PushNotify) error {
-	return c.c.Publish(ctx, "Pusher.Push", in)
+	return c.c.Publish(ctx, "Pusher.Push", in, kafkarpc.PublishKey(in.GetName()))
 }
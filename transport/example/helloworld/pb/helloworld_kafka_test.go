@@ -0,0 +1,56 @@
+package pb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/liuwangchen/toy/transport/rpc/kafkarpc"
+)
+
+type pusher struct {
+	ch chan string
+}
+
+func (p *pusher) Push(ctx context.Context, req *PushNotify) error {
+	tr, _ := rpc.FromServerContext(ctx)
+	p.ch <- req.Name + ":" + string(tr.(*kafkarpc.Transport).Key())
+	return nil
+}
+
+func TestPusherKafka(t *testing.T) {
+	broker := kafkarpc.NewMemoryBroker()
+	srv, err := kafkarpc.NewServer(kafkarpc.Backend(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &pusher{ch: make(chan string, 1)}
+	RegisterPusherKafkaServer(srv, p)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = srv.Start(ctx)
+	}()
+	for !srv.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	defer srv.Stop(context.Background())
+
+	conn, err := kafkarpc.NewClient(context.Background(), kafkarpc.WithBroker(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPusherKafkaClient(conn).Push(context.Background(), &PushNotify{Name: "toy"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-p.ch:
+		// name作为消息key
+		if name != "toy:toy" {
+			t.Fatalf("expect toy:toy, got %s", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect push consumed")
+	}
+}
@@ -31,6 +31,8 @@ const _ = proto.ProtoPackageIsVersion3
 type GreeterNatsService interface {
 	// SayHello call
 	SayHello(ctx context.Context, req *HelloRequest) (*HelloReply, error)
+	// MultiSayHelloNatsStream stream
+	MultiSayHelloNatsStream(ctx context.Context, req *HelloRequest, stream *GreeterMultiSayHelloNatsServerStream) error
 }
 
 // RegisterGreeter register Greeter service
@@ -38,13 +40,48 @@ func RegisterGreeterNatsServer(conn *natsrpc.ServerConn, s GreeterNatsService, o
 	opts = append(opts, natsrpc.WithServiceMethodSequence(map[string]bool{
 		"SayHello": true,
 	}))
+	opts = append(opts, natsrpc.WithServiceMethodStream(map[string]natsrpc.StreamHandler{
+		"MultiSayHello": func(ctx context.Context, stream natsrpc.IServerStream) error {
+			req := &HelloRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return s.MultiSayHelloNatsStream(ctx, req, &GreeterMultiSayHelloNatsServerStream{stream})
+		},
+	}))
 	return conn.Register("github.com.liuwangchen.toy.transport.examples.helloworld.pb.Greeter", s, opts...)
 }
 
+// GreeterMultiSayHelloNatsServerStream MultiSayHello服务端流
+type GreeterMultiSayHelloNatsServerStream struct {
+	natsrpc.IServerStream
+}
+
+// Send 发送
+func (x *GreeterMultiSayHelloNatsServerStream) Send(m *HelloReply) error {
+	return x.SendMsg(m)
+}
+
+// GreeterMultiSayHelloNatsClientStream MultiSayHello客户端流
+type GreeterMultiSayHelloNatsClientStream struct {
+	natsrpc.IClientStream
+}
+
+// Recv 接收，server结束后返回io.EOF
+func (x *GreeterMultiSayHelloNatsClientStream) Recv() (*HelloReply, error) {
+	m := &HelloReply{}
+	if err := x.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // GreeterNatsClient
 type GreeterNatsClient interface {
 	// SayHello
 	SayHello(ctx context.Context, req *HelloRequest) (*HelloReply, error)
+	// MultiSayHelloNatsStream
+	MultiSayHelloNatsStream(ctx context.Context, req *HelloRequest) (*GreeterMultiSayHelloNatsClientStream, error)
 }
 
 type _GreeterNatsClient struct {
@@ -63,6 +100,19 @@ func (c *_GreeterNatsClient) SayHello(ctx context.Context, req *HelloRequest) (*
 	err := c.c.Request(ctx, "github.com.liuwangchen.toy.transport.examples.helloworld.pb.Greeter", "SayHello", req, rep)
 	return rep, err
 }
+func (c *_GreeterNatsClient) MultiSayHelloNatsStream(ctx context.Context, req *HelloRequest) (*GreeterMultiSayHelloNatsClientStream, error) {
+	stream, err := c.c.NewStream(ctx, "github.com.liuwangchen.toy.transport.examples.helloworld.pb.Greeter", "MultiSayHello")
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &GreeterMultiSayHelloNatsClientStream{stream}, nil
+}
 
 // Async
 // GreeterAsyncNatsClient
@@ -105,12 +155,23 @@ type PusherNatsService interface {
 	Push(ctx context.Context, req *PushNotify) error
 }
 
+// _Pusher_Push_JetStream Push走JetStream持久化投递
+var _Pusher_Push_JetStream = &natsrpc.JetStream{
+	Stream:     "",
+	Durable:    "",
+	MaxDeliver: 3,
+	AckWaitMs:  1000,
+}
+
 // RegisterPusher register Pusher service
 func RegisterPusherNatsServer(conn *natsrpc.ServerConn, s PusherNatsService, opts ...natsrpc.ServiceOption) error {
 	var buf bytes.Buffer
 	tmpl, _ := template.New("").Parse("{{.Id}}")
 	_ = tmpl.Execute(&buf, s)
 	opts = append(opts, natsrpc.WithServiceTopic(buf.String()))
+	opts = append(opts, natsrpc.WithServiceMethodJetStream(map[string]*natsrpc.JetStream{
+		"Push": _Pusher_Push_JetStream,
+	}))
 	return conn.Register("github.com.liuwangchen.toy.transport.examples.helloworld.pb.Pusher", s, opts...)
 }
 
@@ -136,6 +197,7 @@ func (c *_PusherNatsClient) Push(ctx context.Context, notify *PushNotify) error
 	tmpl, _ := template.New("").Parse("{{.Id}}")
 	_ = tmpl.Execute(&buf, notify)
 	ctx = natsrpc.WithCallTopicContext(ctx, buf.String())
+	ctx = natsrpc.WithJetStreamContext(ctx, _Pusher_Push_JetStream)
 	return c.c.Publish(ctx, "github.com.liuwangchen.toy.transport.examples.helloworld.pb.Pusher", "Push", notify)
 }
 
@@ -0,0 +1,138 @@
+package pb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/rpc/natsrpc"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+type NatsPusher struct {
+	ch chan string
+}
+
+func (p *NatsPusher) Push(ctx context.Context, req *PushNotify) error {
+	p.ch <- req.Name
+	return nil
+}
+
+type NatsGreeter struct{}
+
+func (g *NatsGreeter) SayHello(ctx context.Context, req *HelloRequest) (*HelloReply, error) {
+	return &HelloReply{Message: req.Name}, nil
+}
+
+func (g *NatsGreeter) MultiSayHelloNatsStream(ctx context.Context, req *HelloRequest, stream *GreeterMultiSayHelloNatsServerStream) error {
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(&HelloReply{Message: fmt.Sprint(req.Name, i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runNatsServer 启动开启JetStream的内嵌nats-server
+func runNatsServer(t *testing.T) *server.Server {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "helloworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: dir, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats-server not ready")
+	}
+	t.Cleanup(func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+		os.RemoveAll(dir)
+	})
+	return ns
+}
+
+func TestPusherJetStream(t *testing.T) {
+	ns := runNatsServer(t)
+
+	// 服务上线前发布
+	cc, err := natsrpc.NewClientConn(natsrpc.WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPusherNatsClient(cc).Push(context.Background(), &PushNotify{Name: "toy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := natsrpc.NewServerConn(natsrpc.WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &NatsPusher{ch: make(chan string, 1)}
+	if err := RegisterPusherNatsServer(conn, p); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	defer conn.Close(context.Background())
+	select {
+	case name := <-p.ch:
+		if name != "toy" {
+			t.Fatalf("expect toy, got %s", name)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expect push delivered after server online")
+	}
+}
+
+func TestGreeterNatsStream(t *testing.T) {
+	ns := runNatsServer(t)
+	conn, err := natsrpc.NewServerConn(natsrpc.WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterGreeterNatsServer(conn, &NatsGreeter{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	defer conn.Close(context.Background())
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	cc, err := natsrpc.NewClientConn(natsrpc.WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := NewGreeterNatsClient(cc).MultiSayHelloNatsStream(context.Background(), &HelloRequest{Name: "toy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		rep, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep.Message != fmt.Sprint("toy", i) {
+			t.Fatalf("expect toy%d, got %s", i, rep.Message)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expect EOF, got %v", err)
+	}
+}
@@ -51,6 +51,18 @@ func (s *Server) MultiSayHelloStream(ctx context.Context, request *pb.HelloReque
 	return nil
 }
 
+func (s *Server) MultiSayHelloNatsStream(ctx context.Context, request *pb.HelloRequest, stream *pb.GreeterMultiSayHelloNatsServerStream) error {
+	for i := 0; i < 10; i++ {
+		err := stream.Send(&pb.HelloReply{
+			Message: fmt.Sprintf("%d", i),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SayHello implements helloworld.GreeterServer
 func (s *Server) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloReply, error) {
 	if in.Name == "error" {
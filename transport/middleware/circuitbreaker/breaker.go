@@ -0,0 +1,174 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/liuwangchen/toy/pkg/container/window"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed 正常放行，统计窗口内的失败率
+	StateClosed State = iota
+	// StateHalfOpen 熔断超时后试探性放行少量请求
+	StateHalfOpen
+	// StateOpen 熔断中，直接拒绝请求
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker is a circuit breaker.
+type CircuitBreaker interface {
+	// Allow 是否放行，熔断时返回ErrNotAllowed
+	Allow() error
+	// MarkSuccess 标记一次成功
+	MarkSuccess()
+	// MarkFailed 标记一次失败
+	MarkFailed()
+}
+
+var _ CircuitBreaker = (*Breaker)(nil)
+
+// Breaker is a closed/open/half-open circuit breaker
+// counting results in a rolling window.
+type Breaker struct {
+	name string
+	opts options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	window   *window.Rolling
+	openedAt time.Time
+	probes   int // half-open状态下已放行的请求数
+	passes   int // half-open状态下已成功的请求数
+}
+
+// NewBreaker new a breaker named name.
+func NewBreaker(name string, opts ...Option) *Breaker {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	b := &Breaker{
+		name: name,
+		opts: o,
+		now:  time.Now,
+	}
+	b.window = window.NewRolling(o.window, o.bucket, b.now())
+	return b
+}
+
+// Name returns the breaker name.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tryHalfOpen(b.now())
+	return b.state
+}
+
+// Allow implements CircuitBreaker.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tryHalfOpen(b.now())
+	switch b.state {
+	case StateOpen:
+		return ErrNotAllowed
+	case StateHalfOpen:
+		if b.probes >= b.opts.halfOpenRequests {
+			return ErrNotAllowed
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// MarkSuccess implements CircuitBreaker.
+func (b *Breaker) MarkSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case StateClosed:
+		b.window.Add(now, 1)
+	case StateHalfOpen:
+		b.passes++
+		if b.passes >= b.opts.halfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// MarkFailed implements CircuitBreaker.
+func (b *Breaker) MarkFailed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case StateClosed:
+		b.window.Add(now, 0)
+		success, failure := windowSum(b.window, now)
+		total := success + failure
+		if total >= b.opts.requestThreshold && float64(failure)/float64(total) >= b.opts.failureRatio {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		// 试探失败，重新熔断
+		b.setState(StateOpen, now)
+	}
+}
+
+// tryHalfOpen open状态超过openTimeout后进入half-open
+func (b *Breaker) tryHalfOpen(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.opts.openTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+// setState 切换状态，持锁调用onStateChange
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.probes, b.passes = 0, 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.Reset(now)
+	}
+	if b.opts.onStateChange != nil {
+		b.opts.onStateChange(b.name, from, state)
+	}
+}
+
+// windowSum 窗口内的成功和失败数，Sum为成功数，Count为总数
+func windowSum(w *window.Rolling, now time.Time) (success, failure int64) {
+	w.Reduce(now, false, func(b window.Bucket) {
+		success += b.Sum
+		failure += b.Count - b.Sum
+	})
+	return
+}
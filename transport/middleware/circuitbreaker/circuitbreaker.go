@@ -0,0 +1,174 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+// Reason 熔断错误的reason
+const Reason = "CIRCUITBREAKER"
+
+// ErrNotAllowed is request failed due to circuit breaker triggered.
+var ErrNotAllowed = errors.New(503, Reason, "request failed due to circuit breaker triggered")
+
+// IsNotAllowed 是否为熔断拒绝的错误
+func IsNotAllowed(err error) bool {
+	return errors.Is(err, ErrNotAllowed)
+}
+
+// Option is circuit breaker option.
+type Option func(*options)
+
+type options struct {
+	window           time.Duration
+	bucket           int
+	requestThreshold int64
+	failureRatio     float64
+	openTimeout      time.Duration
+	halfOpenRequests int
+	onStateChange    func(name string, from, to State)
+	failure          func(err error) bool
+	breaker          func(name string) CircuitBreaker
+}
+
+func defaultOptions() options {
+	return options{
+		window:           10 * time.Second,
+		bucket:           10,
+		requestThreshold: 20,
+		failureRatio:     0.5,
+		openTimeout:      5 * time.Second,
+		halfOpenRequests: 1,
+		failure:          defaultFailure,
+	}
+}
+
+// defaultFailure 5xx(含超时)视为失败，4xx是调用方的问题不计入
+func defaultFailure(err error) bool {
+	return errors.Code(err) >= 500
+}
+
+// WithWindow 统计窗口大小及bucket数量
+func WithWindow(size time.Duration, bucket int) Option {
+	return func(o *options) {
+		if size > 0 && bucket > 0 {
+			o.window = size
+			o.bucket = bucket
+		}
+	}
+}
+
+// WithRequestThreshold 窗口内请求数达到阈值才会触发熔断
+func WithRequestThreshold(n int64) Option {
+	return func(o *options) {
+		o.requestThreshold = n
+	}
+}
+
+// WithFailureRatio 窗口内失败率达到ratio触发熔断
+func WithFailureRatio(ratio float64) Option {
+	return func(o *options) {
+		o.failureRatio = ratio
+	}
+}
+
+// WithOpenTimeout 熔断持续时间，之后进入half-open
+func WithOpenTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.openTimeout = d
+	}
+}
+
+// WithHalfOpenRequests half-open状态下放行的试探请求数，全部成功后恢复
+func WithHalfOpenRequests(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.halfOpenRequests = n
+		}
+	}
+}
+
+// WithStateChange 状态变化回调，持锁调用，不要在回调里再操作同一个breaker
+func WithStateChange(f func(name string, from, to State)) Option {
+	return func(o *options) {
+		o.onStateChange = f
+	}
+}
+
+// WithFailure 自定义哪些错误计为失败
+func WithFailure(f func(err error) bool) Option {
+	return func(o *options) {
+		o.failure = f
+	}
+}
+
+// WithBreaker 自定义每个operation的breaker，例如接入sre自适应熔断
+func WithBreaker(f func(name string) CircuitBreaker) Option {
+	return func(o *options) {
+		o.breaker = f
+	}
+}
+
+// Client circuitbreaker middleware will return ErrNotAllowed when the circuit
+// breaker is triggered and the request is rejected directly.
+// 按rpc.FromClientContext的Operation区分breaker
+func Client(opts ...Option) middleware.Middleware {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.breaker == nil {
+		o.breaker = func(name string) CircuitBreaker {
+			return NewBreaker(name, opts...)
+		}
+	}
+	g := &group{new: o.breaker, vals: make(map[string]CircuitBreaker)}
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			var op string
+			if info, ok := rpc.FromClientContext(ctx); ok {
+				op = info.Operation()
+			}
+			breaker := g.get(op)
+			if err := breaker.Allow(); err != nil {
+				return nil, errors.FromError(err).WithMetadata(map[string]string{"operation": op})
+			}
+			reply, err := handler(ctx, req)
+			if err != nil && o.failure(err) {
+				breaker.MarkFailed()
+			} else {
+				breaker.MarkSuccess()
+			}
+			return reply, err
+		}
+	}
+}
+
+// group 按operation懒加载breaker
+type group struct {
+	new  func(name string) CircuitBreaker
+	mu   sync.RWMutex
+	vals map[string]CircuitBreaker
+}
+
+func (g *group) get(name string) CircuitBreaker {
+	g.mu.RLock()
+	b, ok := g.vals[name]
+	g.mu.RUnlock()
+	if ok {
+		return b
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok = g.vals[name]; ok {
+		return b
+	}
+	b = g.new(name)
+	g.vals[name] = b
+	return b
+}
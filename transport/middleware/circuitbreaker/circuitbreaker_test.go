@@ -0,0 +1,142 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/liuwangchen/toy/transport/rpc/rpctest"
+)
+
+func TestBreakerStateMachine(t *testing.T) {
+	var changes []State
+	now := time.Unix(0, 0)
+	b := NewBreaker("test",
+		WithRequestThreshold(4),
+		WithFailureRatio(0.5),
+		WithOpenTimeout(time.Second),
+		WithHalfOpenRequests(2),
+		WithStateChange(func(name string, from, to State) {
+			if name != "test" {
+				t.Errorf("expect name test, got %s", name)
+			}
+			changes = append(changes, to)
+		}),
+	)
+	b.now = func() time.Time { return now }
+	b.window.Reset(now)
+
+	b.MarkSuccess()
+	b.MarkSuccess()
+	b.MarkFailed()
+	if b.State() != StateClosed {
+		t.Fatalf("expect closed below threshold, got %s", b.State())
+	}
+	b.MarkFailed()
+	if b.State() != StateOpen {
+		t.Fatalf("expect open, got %s", b.State())
+	}
+	if err := b.Allow(); !IsNotAllowed(err) {
+		t.Fatalf("expect ErrNotAllowed, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expect half-open probe allowed, got %v", err)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expect half-open probe allowed, got %v", err)
+	}
+	if err := b.Allow(); !IsNotAllowed(err) {
+		t.Fatalf("expect probes exhausted, got %v", err)
+	}
+	b.MarkFailed()
+	if b.State() != StateOpen {
+		t.Fatalf("expect reopen after failed probe, got %s", b.State())
+	}
+
+	now = now.Add(time.Second)
+	_ = b.Allow()
+	_ = b.Allow()
+	b.MarkSuccess()
+	b.MarkSuccess()
+	if b.State() != StateClosed {
+		t.Fatalf("expect closed after probes succeed, got %s", b.State())
+	}
+
+	want := []State{StateOpen, StateHalfOpen, StateOpen, StateHalfOpen, StateClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("expect changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("expect changes %v, got %v", want, changes)
+		}
+	}
+}
+
+func TestWindowExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker("test", WithWindow(time.Second, 10), WithRequestThreshold(2))
+	b.now = func() time.Time { return now }
+	b.window.Reset(now)
+	b.MarkFailed()
+	now = now.Add(2 * time.Second)
+	b.MarkFailed()
+	if b.State() != StateClosed {
+		t.Fatalf("expect expired failure not counted, got %s", b.State())
+	}
+	b.MarkFailed()
+	if b.State() != StateOpen {
+		t.Fatalf("expect open, got %s", b.State())
+	}
+}
+
+func TestClient(t *testing.T) {
+	var calls int
+	next := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		if req == "bad" {
+			return nil, errors.ServiceUnavailable("DOWN", "down")
+		}
+		if req == "invalid" {
+			return nil, errors.BadRequest("INVALID", "invalid")
+		}
+		return "ok", nil
+	}
+	h := Client(WithRequestThreshold(2), WithFailureRatio(0.3))(next)
+	ctxA := rpc.NewClientContext(context.Background(), rpctest.NewTransport("/test.A/Call", nil))
+	ctxB := rpc.NewClientContext(context.Background(), rpctest.NewTransport("/test.B/Call", nil))
+
+	// 4xx不计入失败
+	for i := 0; i < 3; i++ {
+		_, _ = h(ctxA, "invalid")
+	}
+	if _, err := h(ctxA, "ok"); err != nil {
+		t.Fatalf("expect nil, got %v", err)
+	}
+
+	_, _ = h(ctxA, "bad")
+	_, _ = h(ctxA, "bad")
+	calls = 0
+	_, err := h(ctxA, "ok")
+	if !IsNotAllowed(err) {
+		t.Fatalf("expect ErrNotAllowed, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expect rejected without calling next, got %d calls", calls)
+	}
+	if op := errors.FromError(err).Metadata["operation"]; op != "/test.A/Call" {
+		t.Fatalf("expect operation metadata, got %s", op)
+	}
+	if errors.Code(err) != 503 {
+		t.Fatalf("expect 503, got %d", errors.Code(err))
+	}
+
+	// 不同operation互不影响
+	if _, err := h(ctxB, "ok"); err != nil {
+		t.Fatalf("expect nil, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+var (
+	// ServerRequests 服务端请求计数，按code区分可算出错误率
+	ServerRequests = NewCounterVec("server_requests_code_total", "The total number of processed requests", "kind", "operation", "code", "reason")
+	// ServerSeconds 服务端请求耗时
+	ServerSeconds = NewHistogramVec("server_requests_seconds", "requests duration(sec).", DefBuckets, "kind", "operation")
+	// ClientRequests 客户端请求计数
+	ClientRequests = NewCounterVec("client_requests_code_total", "The total number of processed requests", "kind", "operation", "code", "reason")
+	// ClientSeconds 客户端请求耗时
+	ClientSeconds = NewHistogramVec("client_requests_seconds", "requests duration(sec).", DefBuckets, "kind", "operation")
+)
+
+func init() {
+	DefaultRegistry.MustRegister(ServerRequests, ServerSeconds, ClientRequests, ClientSeconds)
+}
+
+// Option is metrics option.
+type Option func(*options)
+
+type options struct {
+	requests *CounterVec   // labels: kind, operation, code, reason
+	seconds  *HistogramVec // labels: kind, operation
+}
+
+// WithRequests with requests counter.
+func WithRequests(c *CounterVec) Option {
+	return func(o *options) {
+		o.requests = c
+	}
+}
+
+// WithSeconds with seconds histogram.
+func WithSeconds(h *HistogramVec) Option {
+	return func(o *options) {
+		o.seconds = h
+	}
+}
+
+// Server is middleware server-side metrics.
+func Server(opts ...Option) middleware.Middleware {
+	o := options{requests: ServerRequests, seconds: ServerSeconds}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return record(o, rpc.FromServerContext)
+}
+
+// Client is middleware client-side metrics.
+func Client(opts ...Option) middleware.Middleware {
+	o := options{requests: ClientRequests, seconds: ClientSeconds}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return record(o, rpc.FromClientContext)
+}
+
+func record(o options, transporter func(ctx context.Context) (rpc.Transporter, bool)) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			var kind, operation string
+			if info, ok := transporter(ctx); ok {
+				kind = info.Kind().String()
+				operation = info.Operation()
+			}
+			startTime := time.Now()
+			reply, err := handler(ctx, req)
+			code, reason := errors.Code(err), errors.Reason(err)
+			if o.requests != nil {
+				o.requests.With(kind, operation, strconv.Itoa(code), reason).Inc()
+			}
+			if o.seconds != nil {
+				o.seconds.With(kind, operation).Observe(time.Since(startTime).Seconds())
+			}
+			return reply, err
+		}
+	}
+}
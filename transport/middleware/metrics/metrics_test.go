@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/liuwangchen/toy/transport/rpc/rpctest"
+)
+
+func TestServer(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "test", "kind", "operation", "code", "reason")
+	seconds := NewHistogramVec("test_seconds", "test", []float64{1, 0.5}, "kind", "operation")
+	r := NewRegistry()
+	r.MustRegister(requests, seconds)
+	if err := r.Register(requests); err == nil {
+		t.Fatal("expect duplicate register error")
+	}
+
+	next := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if req == "bad" {
+			return nil, errors.NotFound("USER_NOT_FOUND", "not found")
+		}
+		return "ok", nil
+	}
+	h := Server(WithRequests(requests), WithSeconds(seconds))(next)
+	ctx := rpc.NewServerContext(context.Background(), rpctest.NewTransport("/test.A/Call", nil))
+	_, _ = h(ctx, "ok")
+	_, _ = h(ctx, "ok")
+	_, _ = h(ctx, "bad")
+
+	buf := new(bytes.Buffer)
+	if _, err := r.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{kind="nats",operation="/test.A/Call",code="200",reason=""} 2` + "\n",
+		`test_requests_total{kind="nats",operation="/test.A/Call",code="404",reason="USER_NOT_FOUND"} 1` + "\n",
+		"# TYPE test_seconds histogram\n",
+		`test_seconds_bucket{kind="nats",operation="/test.A/Call",le="0.5"} 3` + "\n",
+		`test_seconds_bucket{kind="nats",operation="/test.A/Call",le="1"} 3` + "\n",
+		`test_seconds_bucket{kind="nats",operation="/test.A/Call",le="+Inf"} 3` + "\n",
+		`test_seconds_count{kind="nats",operation="/test.A/Call"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogramVec("h", "test", []float64{1, 2})
+	o := h.With()
+	o.Observe(0.5)
+	o.Observe(1)
+	o.Observe(1.5)
+	o.Observe(3)
+	buf := new(bytes.Buffer)
+	if err := h.Collect(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP h test\n# TYPE h histogram\n" +
+		"h_bucket{le=\"1\"} 2\n" +
+		"h_bucket{le=\"2\"} 3\n" +
+		"h_bucket{le=\"+Inf\"} 4\n" +
+		"h_sum 6\n" +
+		"h_count 4\n"
+	if buf.String() != want {
+		t.Fatalf("expect:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestLabelEscape(t *testing.T) {
+	c := NewCounterVec("c", "test", "v")
+	c.With("a\"b\\c\nd").Inc()
+	buf := new(bytes.Buffer)
+	if err := c.Collect(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `c{v="a\"b\\c\nd"} 1`) {
+		t.Fatalf("unexpected escape: %s", buf.String())
+	}
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultRegistry 默认注册中心，middleware默认的指标都注册在这里
+var DefaultRegistry = NewRegistry()
+
+// Collector 输出prometheus text exposition format
+type Collector interface {
+	// Name 指标名
+	Name() string
+	// Collect 写入所有的series
+	Collect(w io.Writer) error
+}
+
+// Registry 指标注册中心
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry new a registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register 注册，指标名重复返回错误
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		return fmt.Errorf("metrics: duplicate collector %s", c.Name())
+	}
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// MustRegister 注册，失败panic
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister 反注册
+func (r *Registry) Unregister(c Collector) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.Name()]; !ok {
+		return false
+	}
+	delete(r.collectors, c.Name())
+	return true
+}
+
+// WriteTo 按指标名排序输出
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	cs := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		cs = append(cs, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Name() < cs[j].Name()
+	})
+	buf := new(bytes.Buffer)
+	for _, c := range cs {
+		if err := c.Collect(buf); err != nil {
+			return 0, err
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// Handler /metrics handler
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		bw := bufio.NewWriter(w)
+		if _, err := r.WriteTo(bw); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = bw.Flush()
+	})
+}
+
+// Handler DefaultRegistry的handler
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// formatLabels {k1="v1",k2="v2"}
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(values[i]))
+		sb.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extra[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(extra[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", `\n`), name, typ)
+	return err
+}
@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefBuckets 默认的histogram bucket(秒)
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter 计数器
+type Counter interface {
+	Inc()
+	Add(delta float64)
+}
+
+// Observer 观测值
+type Observer interface {
+	Observe(v float64)
+}
+
+// vec 按label values区分的series
+type vec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+
+	mu      sync.Mutex
+	value   float64   // counter
+	counts  []uint64  // histogram bucket计数(非累计)
+	sum     float64   // histogram
+	count   uint64    // histogram
+	buckets []float64 // histogram upper bounds
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, series: make(map[string]*series)}
+}
+
+func (v *vec) Name() string {
+	return v.name
+}
+
+func (v *vec) with(buckets []float64, values ...string) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expected %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok = v.series[key]; ok {
+		return s
+	}
+	s = &series{values: append([]string(nil), values...), buckets: buckets}
+	if len(buckets) > 0 {
+		s.counts = make([]uint64, len(buckets))
+	}
+	v.series[key] = s
+	return s
+}
+
+// sorted series按label values排序，保证输出稳定
+func (v *vec) sorted() []*series {
+	v.mu.RLock()
+	ss := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		ss = append(ss, s)
+	}
+	v.mu.RUnlock()
+	sort.Slice(ss, func(i, j int) bool {
+		return strings.Join(ss[i].values, "\xff") < strings.Join(ss[j].values, "\xff")
+	})
+	return ss
+}
+
+// CounterVec counter with labels
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec new a counter vec.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: newVec(name, help, labels)}
+}
+
+// With 按label values获取counter
+func (c *CounterVec) With(values ...string) Counter {
+	return counter{c.with(nil, values...)}
+}
+
+// Collect implements Collector.
+func (c *CounterVec) Collect(w io.Writer) error {
+	ss := c.sorted()
+	if len(ss) == 0 {
+		return nil
+	}
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil {
+		return err
+	}
+	for _, s := range ss {
+		s.mu.Lock()
+		v := s.value
+		s.mu.Unlock()
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, s.values), formatFloat(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type counter struct {
+	s *series
+}
+
+func (c counter) Inc() {
+	c.Add(1)
+}
+
+func (c counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease in value")
+	}
+	c.s.mu.Lock()
+	c.s.value += delta
+	c.s.mu.Unlock()
+}
+
+// HistogramVec histogram with labels
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec new a histogram vec, buckets为空时使用DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{vec: newVec(name, help, labels), buckets: buckets}
+}
+
+// With 按label values获取observer
+func (h *HistogramVec) With(values ...string) Observer {
+	return histogram{h.with(h.buckets, values...)}
+}
+
+// Collect implements Collector.
+func (h *HistogramVec) Collect(w io.Writer) error {
+	ss := h.sorted()
+	if len(ss) == 0 {
+		return nil
+	}
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+	for _, s := range ss {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum, count := s.sum, s.count
+		s.mu.Unlock()
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.values, "le", formatFloat(upper)), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.values, "le", "+Inf"), count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.values), formatFloat(sum)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.values), count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogram struct {
+	s *series
+}
+
+func (h histogram) Observe(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	i := sort.SearchFloat64s(h.s.buckets, v)
+	h.s.mu.Lock()
+	if i < len(h.s.counts) {
+		h.s.counts[i]++
+	}
+	h.s.sum += v
+	h.s.count++
+	h.s.mu.Unlock()
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuwangchen/toy/pkg/container/window"
+)
+
+var _ Limiter = (*BBR)(nil)
+
+// BBROption is bbr limiter option.
+type BBROption func(*bbrOptions)
+
+type bbrOptions struct {
+	window       time.Duration
+	bucket       int
+	cpuThreshold int64
+	cpu          func() int64
+}
+
+// WithWindow 统计窗口大小及bucket数量
+func WithWindow(size time.Duration, bucket int) BBROption {
+	return func(o *bbrOptions) {
+		if size > 0 && bucket > 0 {
+			o.window = size
+			o.bucket = bucket
+		}
+	}
+}
+
+// WithCPUThreshold cpu使用率(千分比)超过阈值才开始限流
+func WithCPUThreshold(threshold int64) BBROption {
+	return func(o *bbrOptions) {
+		o.cpuThreshold = threshold
+	}
+}
+
+// WithCPU 自定义cpu使用率来源(千分比)
+func WithCPU(cpu func() int64) BBROption {
+	return func(o *bbrOptions) {
+		o.cpu = cpu
+	}
+}
+
+// BBR 自适应限流
+// cpu超过阈值时，根据窗口内最大通过量*最小耗时估算系统容量，inflight超过容量则拒绝；
+// 触发后1s内即使cpu回落也继续按容量限流，避免抖动
+type BBR struct {
+	opts             bbrOptions
+	bucketPerSecond  int64
+	inflight         int64
+	prevDropUnixNano int64
+	now              func() time.Time
+
+	mu   sync.Mutex
+	pass *window.Rolling // 每个bucket的完成请求数
+	rt   *window.Rolling // 每个bucket的耗时(ms)
+}
+
+// NewBBR new a bbr limiter.
+func NewBBR(opts ...BBROption) *BBR {
+	o := bbrOptions{
+		window:       10 * time.Second,
+		bucket:       100,
+		cpuThreshold: 800,
+		cpu:          CPU,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	l := &BBR{
+		opts: o,
+		now:  time.Now,
+	}
+	now := l.now()
+	l.pass = window.NewRolling(o.window, o.bucket, now)
+	l.rt = window.NewRolling(o.window, o.bucket, now)
+	l.bucketPerSecond = int64(time.Second / l.pass.Width())
+	return l
+}
+
+// maxPass 窗口内单个bucket的最大通过量
+func (l *BBR) maxPass(now time.Time) int64 {
+	var max int64 = 1
+	l.pass.Reduce(now, true, func(b window.Bucket) {
+		if b.Sum > max {
+			max = b.Sum
+		}
+	})
+	return max
+}
+
+// minRT 窗口内bucket平均耗时的最小值(ms)
+func (l *BBR) minRT(now time.Time) int64 {
+	var min float64 = math.MaxFloat64
+	l.rt.Reduce(now, true, func(b window.Bucket) {
+		if b.Count == 0 {
+			return
+		}
+		if avg := float64(b.Sum) / float64(b.Count); avg < min {
+			min = avg
+		}
+	})
+	if min == math.MaxFloat64 {
+		return 1
+	}
+	return int64(math.Ceil(min))
+}
+
+// maxInflight 估算的系统容量
+func (l *BBR) maxInflight() int64 {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(math.Floor(float64(l.maxPass(now)*l.minRT(now)*l.bucketPerSecond)/1000.0 + 0.5))
+}
+
+func (l *BBR) shouldDrop() bool {
+	now := l.now()
+	inflight := atomic.LoadInt64(&l.inflight)
+	if l.opts.cpu() < l.opts.cpuThreshold {
+		prevDrop := atomic.LoadInt64(&l.prevDropUnixNano)
+		if prevDrop == 0 || now.UnixNano()-prevDrop > int64(time.Second) {
+			return false
+		}
+		return inflight > 1 && inflight > l.maxInflight()
+	}
+	drop := inflight > 1 && inflight > l.maxInflight()
+	if drop {
+		atomic.StoreInt64(&l.prevDropUnixNano, now.UnixNano())
+	}
+	return drop
+}
+
+// Allow implements Limiter.
+func (l *BBR) Allow() (DoneFunc, error) {
+	if l.shouldDrop() {
+		return nil, ErrLimitExceed
+	}
+	atomic.AddInt64(&l.inflight, 1)
+	start := l.now()
+	return func(DoneInfo) {
+		now := l.now()
+		rt := int64(math.Ceil(float64(now.Sub(start)) / float64(time.Millisecond)))
+		l.mu.Lock()
+		l.rt.Add(now, rt)
+		l.pass.Add(now, 1)
+		l.mu.Unlock()
+		atomic.AddInt64(&l.inflight, -1)
+	}, nil
+}
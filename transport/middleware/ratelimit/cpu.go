@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	cpuInterval = 500 * time.Millisecond
+	cpuDecay    = 0.95
+
+	cgroupRoot = "/sys/fs/cgroup"
+	// clockTicks /proc中cpu时间的单位，linux用户态固定为100
+	clockTicks = 100
+)
+
+var (
+	cpuUsage int64 // 千分比，0~1000
+	cpuOnce  sync.Once
+)
+
+// CPU 当前进程可用cpu的使用率(千分比，平滑后)
+// 优先读所在cgroup的用量和配额，没有cgroup时读进程的cpu时间，分母为cpu核数
+// 两者都无法读取的平台恒为0
+func CPU() int64 {
+	cpuOnce.Do(func() {
+		go cpuproc()
+	})
+	return atomic.LoadInt64(&cpuUsage)
+}
+
+// cpuproc 定时采样，指数平滑
+func cpuproc() {
+	usage, cores := cpuSource()
+	used, err := usage()
+	if err != nil {
+		return
+	}
+	last := time.Now()
+	ticker := time.NewTicker(cpuInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		u, err := usage()
+		if err != nil || u < used {
+			continue
+		}
+		elapsed := now.Sub(last)
+		if elapsed <= 0 {
+			continue
+		}
+		cur := int64(1000 * float64(u-used) / (float64(elapsed) * cores))
+		if cur > 1000 {
+			cur = 1000
+		}
+		used, last = u, now
+		prev := atomic.LoadInt64(&cpuUsage)
+		atomic.StoreInt64(&cpuUsage, int64(float64(prev)*cpuDecay+float64(cur)*(1-cpuDecay)))
+	}
+}
+
+// cpuSource 累计cpu时间的来源和可用核数
+func cpuSource() (func() (time.Duration, error), float64) {
+	cores := float64(runtime.NumCPU())
+	// cgroup v2
+	if path, ok := cgroupPath(""); ok {
+		dir := cgroupDir(cgroupRoot, path)
+		usage := func() (time.Duration, error) {
+			v, err := readKeyValue(filepath.Join(dir, "cpu.stat"), "usage_usec")
+			return time.Duration(v) * time.Microsecond, err
+		}
+		if _, err := usage(); err == nil {
+			if quota, period, err := readCPUMax(filepath.Join(dir, "cpu.max")); err == nil && quota > 0 && period > 0 {
+				cores = quota / period
+			}
+			return usage, cores
+		}
+	}
+	// cgroup v1
+	if path, ok := cgroupPath("cpuacct"); ok {
+		dir := cgroupDir(filepath.Join(cgroupRoot, "cpuacct"), path)
+		usage := func() (time.Duration, error) {
+			v, err := readUint(filepath.Join(dir, "cpuacct.usage"))
+			return time.Duration(v), err
+		}
+		if _, err := usage(); err == nil {
+			if path, ok := cgroupPath("cpu"); ok {
+				dir := cgroupDir(filepath.Join(cgroupRoot, "cpu"), path)
+				quota, qerr := readInt(filepath.Join(dir, "cpu.cfs_quota_us"))
+				period, perr := readInt(filepath.Join(dir, "cpu.cfs_period_us"))
+				if qerr == nil && perr == nil && quota > 0 && period > 0 {
+					cores = float64(quota) / float64(period)
+				}
+			}
+			return usage, cores
+		}
+	}
+	return processCPU, cores
+}
+
+// cgroupPath /proc/self/cgroup中controller所在的路径，controller为空时取v2的统一层级
+func cgroupPath(controller string) (string, bool) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(sc.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if len(controller) == 0 {
+			if parts[0] == "0" && len(parts[1]) == 0 {
+				return parts[2], true
+			}
+			continue
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == controller {
+				return parts[2], true
+			}
+		}
+	}
+	return "", false
+}
+
+// cgroupDir 容器内挂载的是自己的cgroup，路径不存在时用挂载点
+func cgroupDir(mount, path string) string {
+	dir := filepath.Join(mount, path)
+	if _, err := os.Stat(dir); err == nil {
+		return dir
+	}
+	return mount
+}
+
+// processCPU 读取/proc/self/stat中进程的用户态和内核态时间
+func processCPU() (time.Duration, error) {
+	b, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, err
+	}
+	// comm可能包含空格，从最后一个')'之后开始，utime和stime是之后的第12、13个字段
+	s := string(b)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("unexpected /proc/self/stat: %s", s)
+	}
+	var ticks uint64
+	for _, field := range fields[11:13] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		ticks += v
+	}
+	return time.Duration(ticks) * time.Second / clockTicks, nil
+}
+
+// readCPUMax 读取cgroup v2的cpu.max，"max 100000"表示不限制
+func readCPUMax(name string) (quota, period float64, err error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, 0, nil
+	}
+	if quota, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return 0, 0, err
+	}
+	if period, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return 0, 0, err
+	}
+	return quota, period, nil
+}
+
+// readKeyValue 读取"key value"格式文件中key的值
+func readKeyValue(name, key string) (uint64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("%s not found in %s", key, name)
+}
+
+func readUint(name string) (uint64, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func readInt(name string) (int64, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
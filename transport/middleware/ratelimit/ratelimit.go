@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+// Reason 限流错误的reason
+const Reason = "RATELIMIT"
+
+// ErrLimitExceed is service unavailable due to rate limit exceeded.
+var ErrLimitExceed = errors.ResourceExhausted(Reason, "service unavailable due to rate limit exceeded")
+
+// IsLimitExceed 是否为限流拒绝的错误
+func IsLimitExceed(err error) bool {
+	return errors.Is(err, ErrLimitExceed)
+}
+
+// DoneInfo 请求结束时的信息
+type DoneInfo struct {
+	Err error
+}
+
+// DoneFunc 请求结束时回调
+type DoneFunc func(DoneInfo)
+
+// Limiter is a rate limiter.
+type Limiter interface {
+	// Allow 是否放行，放行后必须调用DoneFunc
+	Allow() (DoneFunc, error)
+}
+
+func noopDone(DoneInfo) {}
+
+// Option is ratelimit option.
+type Option func(*options)
+
+type options struct {
+	limiter func(operation string) Limiter
+}
+
+// WithLimiter 所有operation共用一个limiter
+func WithLimiter(limiter Limiter) Option {
+	return func(o *options) {
+		o.limiter = func(string) Limiter {
+			return limiter
+		}
+	}
+}
+
+// WithOperationLimiter 每个operation独立的limiter，防止热点方法拖垮整个进程
+func WithOperationLimiter(new func(operation string) Limiter) Option {
+	return func(o *options) {
+		g := &group{new: new, vals: make(map[string]Limiter)}
+		o.limiter = g.get
+	}
+}
+
+// Server ratelimiter middleware
+// 按rpc.FromServerContext的Operation选择limiter，默认所有operation共用一个BBR
+func Server(opts ...Option) middleware.Middleware {
+	o := &options{}
+	WithLimiter(NewBBR())(o)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (reply interface{}, err error) {
+			var op string
+			if info, ok := rpc.FromServerContext(ctx); ok {
+				op = info.Operation()
+			}
+			done, e := o.limiter(op).Allow()
+			if e != nil {
+				return nil, errors.FromError(e).WithMetadata(map[string]string{"operation": op})
+			}
+			defer func() {
+				done(DoneInfo{Err: err})
+			}()
+			return handler(ctx, req)
+		}
+	}
+}
+
+// group 按operation懒加载limiter
+type group struct {
+	new  func(operation string) Limiter
+	mu   sync.RWMutex
+	vals map[string]Limiter
+}
+
+func (g *group) get(operation string) Limiter {
+	g.mu.RLock()
+	l, ok := g.vals[operation]
+	g.mu.RUnlock()
+	if ok {
+		return l
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if l, ok = g.vals[operation]; ok {
+		return l
+	}
+	l = g.new(operation)
+	g.vals[operation] = l
+	return l
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/pkg/container/window"
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/liuwangchen/toy/transport/rpc/rpctest"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	tb := NewTokenBucket(10, 2)
+	tb.now = func() time.Time { return now }
+	tb.last = now
+	for i := 0; i < 2; i++ {
+		if _, err := tb.Allow(); err != nil {
+			t.Fatalf("expect allowed within burst, got %v", err)
+		}
+	}
+	if _, err := tb.Allow(); !IsLimitExceed(err) {
+		t.Fatalf("expect ErrLimitExceed, got %v", err)
+	}
+	now = now.Add(100 * time.Millisecond)
+	if _, err := tb.Allow(); err != nil {
+		t.Fatalf("expect refilled token, got %v", err)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	sw := NewSlidingWindow(3, time.Second, 10)
+	sw.now = func() time.Time { return now }
+	sw.rolling = window.NewRolling(time.Second, 10, now)
+	for i := 0; i < 3; i++ {
+		if _, err := sw.Allow(); err != nil {
+			t.Fatalf("expect allowed, got %v", err)
+		}
+		now = now.Add(200 * time.Millisecond)
+	}
+	if _, err := sw.Allow(); !IsLimitExceed(err) {
+		t.Fatalf("expect ErrLimitExceed, got %v", err)
+	}
+	// 第一个请求滑出窗口
+	now = now.Add(500 * time.Millisecond)
+	if _, err := sw.Allow(); err != nil {
+		t.Fatalf("expect allowed after slide, got %v", err)
+	}
+}
+
+func TestBBR(t *testing.T) {
+	var cpu int64
+	now := time.Unix(0, 0)
+	l := NewBBR(WithWindow(time.Second, 10), WithCPU(func() int64 { return cpu }))
+	l.now = func() time.Time { return now }
+	l.pass = window.NewRolling(time.Second, 10, now)
+	l.rt = window.NewRolling(time.Second, 10, now)
+
+	// 每个bucket(100ms)完成10个耗时10ms的请求 -> 容量 10*10*10/1000 = 1
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 10; j++ {
+			done, err := l.Allow()
+			if err != nil {
+				t.Fatalf("expect allowed, got %v", err)
+			}
+			now = now.Add(10 * time.Millisecond)
+			done(DoneInfo{})
+		}
+	}
+
+	var dones []DoneFunc
+	for i := 0; i < 5; i++ {
+		done, err := l.Allow()
+		if err != nil {
+			t.Fatalf("expect allowed while cpu low, got %v", err)
+		}
+		dones = append(dones, done)
+	}
+	cpu = 900
+	if _, err := l.Allow(); !IsLimitExceed(err) {
+		t.Fatalf("expect ErrLimitExceed when cpu high and inflight over capacity, got %v", err)
+	}
+	// cpu回落后1s内仍然按容量限流
+	cpu = 0
+	if _, err := l.Allow(); !IsLimitExceed(err) {
+		t.Fatalf("expect ErrLimitExceed during cool down, got %v", err)
+	}
+	for _, done := range dones {
+		done(DoneInfo{})
+	}
+	if _, err := l.Allow(); err != nil {
+		t.Fatalf("expect allowed after inflight drained, got %v", err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	next := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	h := Server(WithOperationLimiter(func(string) Limiter {
+		return NewTokenBucket(0, 1)
+	}))(next)
+	ctxA := rpc.NewServerContext(context.Background(), rpctest.NewTransport("/test.A/Call", nil))
+	ctxB := rpc.NewServerContext(context.Background(), rpctest.NewTransport("/test.B/Call", nil))
+
+	if _, err := h(ctxA, nil); err != nil {
+		t.Fatalf("expect nil, got %v", err)
+	}
+	_, err := h(ctxA, nil)
+	if !IsLimitExceed(err) {
+		t.Fatalf("expect ErrLimitExceed, got %v", err)
+	}
+	if errors.Code(err) != 429 {
+		t.Fatalf("expect 429, got %d", errors.Code(err))
+	}
+	if op := errors.FromError(err).Metadata["operation"]; op != "/test.A/Call" {
+		t.Fatalf("expect operation metadata, got %s", op)
+	}
+	// 不同operation互不影响
+	if _, err := h(ctxB, nil); err != nil {
+		t.Fatalf("expect nil, got %v", err)
+	}
+}
+
+func TestCPUSource(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cpu usage only on linux")
+	}
+	usage, cores := cpuSource()
+	u1, err := usage()
+	if err != nil || cores <= 0 {
+		t.Fatalf("usage %v, cores %v", err, cores)
+	}
+	// 忙等一段时间，cpu时间应该增加
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+	}
+	if u2, err := usage(); err != nil || u2 <= u1 {
+		t.Fatalf("usage %v -> %v, %v", u1, u2, err)
+	}
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/liuwangchen/toy/pkg/container/window"
+)
+
+var _ Limiter = (*SlidingWindow)(nil)
+
+// SlidingWindow 滑动窗口计数，窗口内最多放行limit个请求
+type SlidingWindow struct {
+	limit int64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	rolling *window.Rolling
+}
+
+// NewSlidingWindow new a sliding window limiter, window of size is split into bucket buckets.
+func NewSlidingWindow(limit int64, size time.Duration, bucket int) *SlidingWindow {
+	if bucket <= 0 {
+		bucket = 10
+	}
+	sw := &SlidingWindow{
+		limit: limit,
+		now:   time.Now,
+	}
+	sw.rolling = window.NewRolling(size, bucket, sw.now())
+	return sw
+}
+
+// Allow implements Limiter.
+func (sw *SlidingWindow) Allow() (DoneFunc, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	now := sw.now()
+	var total int64
+	sw.rolling.Reduce(now, false, func(b window.Bucket) {
+		total += b.Count
+	})
+	if total >= sw.limit {
+		return nil, ErrLimitExceed
+	}
+	sw.rolling.Add(now, 1)
+	return noopDone, nil
+}
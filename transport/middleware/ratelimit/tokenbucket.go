@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+var _ Limiter = (*TokenBucket)(nil)
+
+// TokenBucket 令牌桶，每秒补充rate个令牌，最多积攒burst个
+type TokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket new a token bucket limiter, the bucket is full at first.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	tb := &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+	tb.last = tb.now()
+	return tb
+}
+
+// Allow implements Limiter.
+func (tb *TokenBucket) Allow() (DoneFunc, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	now := tb.now()
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens += elapsed.Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.last = now
+	}
+	if tb.tokens < 1 {
+		return nil, ErrLimitExceed
+	}
+	tb.tokens--
+	return noopDone, nil
+}
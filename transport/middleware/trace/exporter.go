@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"sync"
+)
+
+// Exporter 导出结束的span
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+// ExporterFunc func适配Exporter
+type ExporterFunc func(span *Span)
+
+// ExportSpan implements Exporter.
+func (f ExporterFunc) ExportSpan(span *Span) {
+	f(span)
+}
+
+var _ Exporter = (*InMemoryExporter)(nil)
+
+// InMemoryExporter 内存中保存span，用于测试
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewInMemoryExporter new a in memory exporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan implements Exporter.
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans 按结束顺序返回所有span
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset 清空
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
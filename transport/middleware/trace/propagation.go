@@ -0,0 +1,76 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+// TraceparentHeader w3c trace context header
+// 格式: 00-{trace-id}-{parent-id}-{trace-flags}
+const TraceparentHeader = "traceparent"
+
+const (
+	traceparentVersion = "00"
+	flagSampled        = "01"
+	flagNotSampled     = "00"
+)
+
+// FormatTraceparent 序列化为traceparent
+func FormatTraceparent(sc SpanContext) string {
+	flags := flagNotSampled
+	if sc.Sampled {
+		flags = flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", traceparentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent 解析traceparent
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+	// version 00 必须是4段，更高版本向后兼容只取前4段
+	if parts[0] == traceparentVersion && len(parts) != 4 {
+		return sc, false
+	}
+	traceID, ok := ParseTraceID(parts[1])
+	if !ok {
+		return sc, false
+	}
+	spanID, ok := ParseSpanID(parts[2])
+	if !ok {
+		return sc, false
+	}
+	if len(parts[3]) != 2 {
+		return sc, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return sc, false
+	}
+	sc.TraceID = traceID
+	sc.SpanID = spanID
+	sc.Sampled = flags&1 == 1
+	return sc, true
+}
+
+// Inject 写入header
+func Inject(sc SpanContext, header rpc.Header) {
+	if header == nil || !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+}
+
+// Extract 从header解析
+func Extract(header rpc.Header) (SpanContext, bool) {
+	if header == nil {
+		return SpanContext{}, false
+	}
+	return ParseTraceparent(header.Get(TraceparentHeader))
+}
@@ -0,0 +1,216 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID w3c trace id，16字节
+type TraceID [16]byte
+
+// IsValid 全0为非法
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID w3c span id，8字节
+type SpanID [8]byte
+
+// IsValid 全0为非法
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext 跨进程传递的span信息
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid trace id和span id都合法
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind span类型
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+// Span 一次调用的耗时记录
+type Span struct {
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+	Parent      SpanID // 根span为空
+	StartTime   time.Time
+	EndTime     time.Time
+	Err         error
+
+	mu         sync.Mutex
+	attributes map[string]string
+	exporter   Exporter
+	once       sync.Once
+}
+
+// SetAttribute 设置属性
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// Attributes 属性的拷贝
+func (s *Span) Attributes() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attrs := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// Duration 耗时
+func (s *Span) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// End 结束span并导出，重复调用只生效一次
+func (s *Span) End(err error) {
+	s.once.Do(func() {
+		s.EndTime = time.Now()
+		s.Err = err
+		if s.exporter != nil && s.SpanContext.Sampled {
+			s.exporter.ExportSpan(s)
+		}
+	})
+}
+
+type spanKey struct{}
+
+// ContextWithSpan 把span放入ctx
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext 获取ctx中的span
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Tracer 创建span
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer new a tracer, exporter为nil时不导出
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start 以ctx中的span为父span创建子span，ctx中没有span时沿用GetTraceIdFromCtx的trace id
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return t.start(ctx, name, kind, parentFromContext(ctx))
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	span := &Span{
+		Name:      name,
+		Kind:      kind,
+		StartTime: time.Now(),
+		exporter:  t.exporter,
+	}
+	if parent.TraceID.IsValid() {
+		span.SpanContext.TraceID = parent.TraceID
+		span.SpanContext.Sampled = parent.Sampled
+		span.Parent = parent.SpanID
+	} else {
+		span.SpanContext.TraceID = newTraceID()
+		span.SpanContext.Sampled = true
+	}
+	span.SpanContext.SpanID = newSpanID()
+	return ContextWithSpan(ctx, span), span
+}
+
+// parentFromContext ctx中的span，或者老版本32位的trace id
+func parentFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext
+	}
+	if traceID, ok := ParseTraceID(legacyTraceId(ctx)); ok {
+		return SpanContext{TraceID: traceID, Sampled: true}
+	}
+	return SpanContext{}
+}
+
+// ParseTraceID 解析32位16进制trace id
+func ParseTraceID(s string) (TraceID, bool) {
+	var t TraceID
+	if len(s) != 32 {
+		return t, false
+	}
+	if _, err := hex.Decode(t[:], []byte(s)); err != nil {
+		return t, false
+	}
+	return t, t.IsValid()
+}
+
+// ParseSpanID 解析16位16进制span id
+func ParseSpanID(s string) (SpanID, bool) {
+	var id SpanID
+	if len(s) != 16 {
+		return id, false
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return id, false
+	}
+	return id, id.IsValid()
+}
+
+func newTraceID() (t TraceID) {
+	for !t.IsValid() {
+		_, _ = rand.Read(t[:])
+	}
+	return
+}
+
+func newSpanID() (s SpanID) {
+	for !s.IsValid() {
+		_, _ = rand.Read(s[:])
+	}
+	return
+}
@@ -57,7 +57,15 @@ func InjectTraceId(opts ...option) middleware.Middleware {
 	}
 }
 
+// GetTraceIdFromCtx 优先取span的trace id，兼容只注入了traceId的老用法
 func GetTraceIdFromCtx(ctx context.Context) string {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext.TraceID.String()
+	}
+	return legacyTraceId(ctx)
+}
+
+func legacyTraceId(ctx context.Context) string {
 	v := ctx.Value(traceKey{})
 	if v == nil {
 		return ""
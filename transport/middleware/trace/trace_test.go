@@ -0,0 +1,108 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/liuwangchen/toy/transport/rpc/rpctest"
+)
+
+func TestTraceparent(t *testing.T) {
+	s := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(s)
+	if !ok {
+		t.Fatal("expect valid traceparent")
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("unexpected span context %+v", sc)
+	}
+	if got := FormatTraceparent(sc); got != s {
+		t.Fatalf("expect %s, got %s", s, got)
+	}
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("expect %q invalid", bad)
+		}
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); !ok {
+		t.Error("expect future version with extra fields valid")
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	header := rpctest.Header{}
+
+	var serverTraceId string
+	server := Server(WithExporter(exporter))(func(ctx context.Context, req interface{}) (interface{}, error) {
+		serverTraceId = GetTraceIdFromCtx(ctx)
+		return nil, errors.NotFound("USER_NOT_FOUND", "not found")
+	})
+	client := Client(WithExporter(exporter))(func(ctx context.Context, req interface{}) (interface{}, error) {
+		// 模拟网络传输，header原样带到服务端
+		sctx := rpc.NewServerContext(context.Background(), rpctest.NewTransport("/test.A/Call", header))
+		return server(sctx, req)
+	})
+
+	ctx, root := NewTracer(exporter).Start(context.Background(), "root", SpanKindInternal)
+	cctx := rpc.NewClientContext(ctx, rpctest.NewTransport("/test.A/Call", header))
+	if _, err := client(cctx, nil); !errors.IsNotFound(err) {
+		t.Fatalf("expect not found, got %v", err)
+	}
+	root.End(nil)
+
+	spans := exporter.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expect 3 spans, got %d", len(spans))
+	}
+	serverSpan, clientSpan := spans[0], spans[1]
+	if serverSpan.Kind != SpanKindServer || clientSpan.Kind != SpanKindClient {
+		t.Fatalf("unexpected kinds %s %s", serverSpan.Kind, clientSpan.Kind)
+	}
+	if clientSpan.Parent != root.SpanContext.SpanID {
+		t.Fatal("expect client span child of root")
+	}
+	if serverSpan.Parent != clientSpan.SpanContext.SpanID {
+		t.Fatal("expect server span child of client span")
+	}
+	traceId := root.SpanContext.TraceID
+	if clientSpan.SpanContext.TraceID != traceId || serverSpan.SpanContext.TraceID != traceId {
+		t.Fatal("expect same trace id")
+	}
+	if serverTraceId != traceId.String() {
+		t.Fatalf("expect GetTraceIdFromCtx %s, got %s", traceId, serverTraceId)
+	}
+	if header.Get(TraceparentHeader) != FormatTraceparent(clientSpan.SpanContext) {
+		t.Fatalf("unexpected traceparent %s", header.Get(TraceparentHeader))
+	}
+	if attrs := serverSpan.Attributes(); attrs["rpc.code"] != "404" || attrs["rpc.operation"] != "/test.A/Call" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
+
+func TestLegacyTraceId(t *testing.T) {
+	legacy := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := ContextWithTraceId(context.Background(), legacy)
+	_, span := NewTracer(nil).Start(ctx, "test", SpanKindInternal)
+	if span.SpanContext.TraceID.String() != legacy {
+		t.Fatalf("expect legacy trace id reused, got %s", span.SpanContext.TraceID)
+	}
+	if span.Parent.IsValid() {
+		t.Fatal("expect root span")
+	}
+
+	ctx = ContextWithTraceId(context.Background(), "abcdefg")
+	if GetTraceIdFromCtx(ctx) != "abcdefg" {
+		t.Fatal("expect short trace id without span")
+	}
+}
@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+// TracingOption is tracing option.
+type TracingOption func(*tracingOptions)
+
+type tracingOptions struct {
+	exporter Exporter
+}
+
+// WithExporter 设置span导出
+func WithExporter(exporter Exporter) TracingOption {
+	return func(o *tracingOptions) {
+		o.exporter = exporter
+	}
+}
+
+// Server 服务端span，父span取自请求header中的traceparent
+func Server(opts ...TracingOption) middleware.Middleware {
+	o := tracingOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	tracer := NewTracer(o.exporter)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := rpc.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+			parent, ok := Extract(tr.RequestHeader())
+			if !ok {
+				parent = parentFromContext(ctx)
+			}
+			ctx, span := tracer.start(ctx, tr.Operation(), SpanKindServer, parent)
+			setAttributes(span, tr)
+			reply, err := handler(ctx, req)
+			endSpan(span, err)
+			return reply, err
+		}
+	}
+}
+
+// Client 客户端span，并把traceparent写入请求header
+func Client(opts ...TracingOption) middleware.Middleware {
+	o := tracingOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	tracer := NewTracer(o.exporter)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := rpc.FromClientContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+			ctx, span := tracer.Start(ctx, tr.Operation(), SpanKindClient)
+			setAttributes(span, tr)
+			Inject(span.SpanContext, tr.RequestHeader())
+			reply, err := handler(ctx, req)
+			endSpan(span, err)
+			return reply, err
+		}
+	}
+}
+
+func setAttributes(span *Span, tr rpc.Transporter) {
+	span.SetAttribute("rpc.kind", tr.Kind().String())
+	span.SetAttribute("rpc.operation", tr.Operation())
+	span.SetAttribute("rpc.endpoint", tr.Endpoint())
+}
+
+func endSpan(span *Span, err error) {
+	if err != nil {
+		span.SetAttribute("rpc.code", strconv.Itoa(errors.Code(err)))
+		if reason := errors.Reason(err); len(reason) > 0 {
+			span.SetAttribute("rpc.reason", reason)
+		}
+	}
+	span.End(err)
+}
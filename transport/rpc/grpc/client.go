@@ -10,6 +10,7 @@ import (
 	"github.com/liuwangchen/toy/registry"
 	"github.com/liuwangchen/toy/selector"
 	"github.com/liuwangchen/toy/selector/wrr"
+	"github.com/liuwangchen/toy/transport/errors"
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/liuwangchen/toy/transport/rpc"
 	"github.com/liuwangchen/toy/transport/rpc/grpc/resolver/discovery"
@@ -172,7 +173,10 @@ func unaryClientInterceptor(ms []middleware.Middleware, timeout time.Duration, f
 				}
 				ctx = grpcmd.AppendToOutgoingContext(ctx, keyvals...)
 			}
-			return reply, invoker(ctx, method, req, reply, cc, opts...)
+			if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+				return nil, errors.FromError(err)
+			}
+			return reply, nil
 		}
 		if len(ms) > 0 {
 			h = middleware.Chain(ms...)(h)
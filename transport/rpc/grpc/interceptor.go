@@ -4,6 +4,7 @@ import (
 	"context"
 
 	ic "github.com/liuwangchen/toy/pkg/context"
+	"github.com/liuwangchen/toy/transport/errors"
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/liuwangchen/toy/transport/rpc"
 	"google.golang.org/grpc"
@@ -37,7 +38,10 @@ func (s *Server) unaryServerInterceptor() grpc.UnaryServerInterceptor {
 		if len(replyHeader) > 0 {
 			_ = grpc.SetHeader(ctx, replyHeader)
 		}
-		return reply, err
+		if err != nil {
+			return nil, errors.FromError(err)
+		}
+		return reply, nil
 	}
 }
 
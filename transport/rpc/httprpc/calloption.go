@@ -20,6 +20,19 @@ type callInfo struct {
 	contentType  string
 	operation    string
 	pathTemplate string
+	retry        *RetryPolicy
+	retrySet     bool
+	websocket    bool
+	eventStream  bool
+	lastEventID  string
+}
+
+// retryPolicy 单次调用设置了Retry时覆盖客户端的策略
+func (c *callInfo) retryPolicy(def *RetryPolicy) *RetryPolicy {
+	if c.retrySet {
+		return c.retry
+	}
+	return def
 }
 
 // EmptyCallOption does not alter the Call configuration.
@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/liuwangchen/toy/pkg/copier"
@@ -17,6 +17,7 @@ import (
 	"github.com/liuwangchen/toy/selector"
 	"github.com/liuwangchen/toy/selector/wrr"
 	"github.com/liuwangchen/toy/transport/encoding"
+	"github.com/liuwangchen/toy/transport/errors"
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/liuwangchen/toy/transport/rpc"
 )
@@ -120,6 +121,8 @@ type ClientConn struct {
 	transport    http.RoundTripper
 	selector     selector.Selector
 	discovery    registry.Discovery
+	retry        *RetryPolicy
+	wsPing       time.Duration
 	middleware   []middleware.Middleware
 	target       *Target
 	r            *resolver
@@ -154,6 +157,7 @@ func NewClientConn(ctx context.Context, opts ...Option) (*ClientConn, error) {
 		errorDecoder: DefaultErrorDecoder,
 		transport:    http.DefaultTransport,
 		selector:     wrr.New(),
+		wsPing:       defaultWebSocketPing,
 	}
 	for _, o := range opts {
 		o(conn)
@@ -234,7 +238,7 @@ func (conn *ClientConn) Invoke(ctx context.Context, method, reqPath string, args
 
 func (conn *ClientConn) invoke(ctx context.Context, req *http.Request, args interface{}, reply interface{}, c callInfo, opts ...CallOption) error {
 	h := func(ctx context.Context, in interface{}) (interface{}, error) {
-		res, err := conn.do(req.WithContext(ctx))
+		res, err := conn.doRetry(ctx, req, c.retryPolicy(conn.retry))
 		if res != nil {
 			cs := csAttempt{res: res}
 			for _, o := range opts {
@@ -297,6 +301,12 @@ func (conn *ClientConn) Stream(ctx context.Context, method, reqPath string, args
 	if conn.userAgent != "" {
 		req.Header.Set("User-Agent", conn.userAgent)
 	}
+	if c.eventStream {
+		req.Header.Set("Accept", EventStreamContentType)
+		if c.lastEventID != "" {
+			req.Header.Set(LastEventIDHeader, c.lastEventID)
+		}
+	}
 	ctx = rpc.NewClientContext(ctx, &Transport{
 		endpoint:     conn.endpoint,
 		reqHeader:    headerCarrier(req.Header),
@@ -309,6 +319,9 @@ func (conn *ClientConn) Stream(ctx context.Context, method, reqPath string, args
 
 func (conn *ClientConn) stream(ctx context.Context, req *http.Request, args interface{}, c callInfo, opts ...CallOption) (IClientStream, error) {
 	h := func(ctx context.Context, in interface{}) (interface{}, error) {
+		if c.websocket {
+			return conn.websocketStream(ctx, req, in, c, opts...)
+		}
 		res, err := conn.doStream(req.WithContext(ctx))
 		if res != nil {
 			cs := csAttempt{res: res}
@@ -319,6 +332,9 @@ func (conn *ClientConn) stream(ctx context.Context, req *http.Request, args inte
 		if err != nil {
 			return nil, err
 		}
+		if strings.HasPrefix(res.Header.Get("Content-Type"), EventStreamContentType) {
+			return NewSSEClientStream(res.Body, CodecForResponse(res)), nil
+		}
 		stream := NewHttpClientStream(res.Body, CodecForResponse(res))
 		return stream, nil
 	}
@@ -332,6 +348,27 @@ func (conn *ClientConn) stream(ctx context.Context, req *http.Request, args inte
 	return stream.(IClientStream), nil
 }
 
+// websocketStream 握手后args不为空时作为第一条消息发送
+func (conn *ClientConn) websocketStream(ctx context.Context, req *http.Request, args interface{}, c callInfo, opts ...CallOption) (IClientStream, error) {
+	stream, res, err := conn.doWebSocket(req.WithContext(ctx), c)
+	if res != nil {
+		cs := csAttempt{res: res}
+		for _, o := range opts {
+			o.after(&c, &cs)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if args != nil {
+		if err := stream.SendMsg(args); err != nil {
+			_ = stream.Close(err)
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
 // Do send an HTTP request and decodes the body of response into target.
 // returns an error (of type *Error) if the response status code is not 2xx.
 func (conn *ClientConn) Do(req *http.Request, opts ...CallOption) (*http.Response, error) {
@@ -341,8 +378,7 @@ func (conn *ClientConn) Do(req *http.Request, opts ...CallOption) (*http.Respons
 			return nil, err
 		}
 	}
-
-	return conn.do(req)
+	return conn.doRetry(req.Context(), req, c.retryPolicy(conn.retry))
 }
 
 func (conn *ClientConn) doStream(req *http.Request) (*http.Response, error) {
@@ -377,15 +413,27 @@ func (conn *ClientConn) doStream(req *http.Request) (*http.Response, error) {
 }
 
 func (conn *ClientConn) do(req *http.Request) (*http.Response, error) {
+	return conn.doAttempt(req, nil)
+}
+
+// doAttempt tried不为空时优先选择没有请求过的节点，并记录本次选择的节点
+func (conn *ClientConn) doAttempt(req *http.Request, tried *triedNodes) (*http.Response, error) {
 	var done func(context.Context, selector.DoneInfo)
 	if conn.r != nil {
 		var (
 			err  error
 			node selector.Node
+			opts []selector.SelectOption
 		)
-		if node, done, err = conn.r.Select(req.Context()); err != nil {
+		if tried != nil {
+			opts = append(opts, selector.WithFilter(tried.filter))
+		}
+		if node, done, err = conn.r.Select(req.Context(), opts...); err != nil {
 			return nil, err
 		}
+		if tried != nil {
+			tried.add(node.Address())
+		}
 		if conn.insecure {
 			req.URL.Scheme = "http"
 		} else {
@@ -439,6 +487,7 @@ func DefaultResponseDecoder(ctx context.Context, res *http.Response, v interface
 }
 
 // DefaultErrorDecoder is an HTTP error decoder.
+// 将服务端DefaultErrorEncoder写入的Status还原为errors.Error
 func DefaultErrorDecoder(ctx context.Context, res *http.Response) error {
 	if res.StatusCode >= 200 && res.StatusCode <= 299 {
 		return nil
@@ -446,17 +495,14 @@ func DefaultErrorDecoder(ctx context.Context, res *http.Response) error {
 	defer res.Body.Close()
 	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return err
+		return errors.New(res.StatusCode, errors.UnknownReason, "").WithCause(err)
 	}
-	if res.StatusCode != 400 {
-		return errors.New(string(data))
-	}
-	e := make(map[string]interface{})
-	err = CodecForResponse(res).Unmarshal(data, &e)
-	if err != nil {
-		return err
+	e := new(errors.Error)
+	if err = CodecForResponse(res).Unmarshal(data, &e.Status); err != nil {
+		return errors.New(res.StatusCode, errors.UnknownReason, string(data)).WithCause(err)
 	}
-	return errors.New(fmt.Sprint(e["err"]))
+	e.Code = int32(res.StatusCode)
+	return e
 }
 
 // CodecForResponse get encoding.Codec via http.Response
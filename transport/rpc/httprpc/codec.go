@@ -6,6 +6,7 @@ import (
 
 	"github.com/liuwangchen/toy/pkg/httputil"
 	"github.com/liuwangchen/toy/transport/encoding"
+	"github.com/liuwangchen/toy/transport/errors"
 )
 
 // SupportPackageIsVersion1 These constants should not be referenced from any other code.
@@ -56,16 +57,21 @@ func DefaultResponseEncoder(w http.ResponseWriter, r *http.Request, v interface{
 }
 
 // DefaultErrorEncoder encodes the error to the HTTP response.
+// 错误统一转换为errors.Error，http status即为错误码
 func DefaultErrorEncoder(w http.ResponseWriter, r *http.Request, err error) {
-	se := map[string]interface{}{"err": err.Error(), "code": 400}
+	se := errors.FromError(err)
 	codec := CodecForRequest(r, "Accept")
-	body, err := codec.Marshal(se)
+	body, err := codec.Marshal(&se.Status)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	code := int(se.Code)
+	if code < 100 || code > 999 {
+		code = errors.UnknownCode
+	}
 	w.Header().Set("Content-Type", httputil.ContentType(codec.Name()))
-	w.WriteHeader(se["code"].(int))
+	w.WriteHeader(code)
 	_, _ = w.Write(body)
 }
 
@@ -0,0 +1,60 @@
+package httprpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	terrors "github.com/liuwangchen/toy/transport/errors"
+)
+
+func TestDefaultErrorEncoderDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		accept string
+		code   int
+		reason string
+	}{
+		{"json", terrors.NotFound("USER_NOT_FOUND", "not found").WithMetadata(map[string]string{"id": "1"}), "application/json", http.StatusNotFound, "USER_NOT_FOUND"},
+		{"proto", terrors.Conflict("DUP", "dup"), "application/proto", http.StatusConflict, "DUP"},
+		{"plain", errors.New("plain"), "application/json", terrors.UnknownCode, terrors.UnknownReason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			DefaultErrorEncoder(w, req, tt.err)
+
+			err := DefaultErrorDecoder(context.Background(), w.Result())
+			se := new(terrors.Error)
+			if !errors.As(err, &se) {
+				t.Fatalf("expect *errors.Error, got %T", err)
+			}
+			if int(se.Code) != tt.code {
+				t.Errorf("expect %v, got %v", tt.code, se.Code)
+			}
+			if se.Reason != tt.reason {
+				t.Errorf("expect %v, got %v", tt.reason, se.Reason)
+			}
+			if want := terrors.FromError(tt.err); se.Message != want.Message || len(se.Metadata) != len(want.Metadata) {
+				t.Errorf("expect %v, got %v", want, se)
+			}
+		})
+	}
+}
+
+func TestDefaultErrorDecoderPlainBody(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       httptest.NewRecorder().Result().Body,
+	}
+	err := DefaultErrorDecoder(context.Background(), res)
+	if terrors.Code(err) != http.StatusBadGateway {
+		t.Errorf("expect %v, got %v", http.StatusBadGateway, terrors.Code(err))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/liuwangchen/toy/transport/errors"
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/liuwangchen/toy/transport/rpc/httprpc/binding"
 )
@@ -91,10 +92,22 @@ func (c *wrapper) Response() http.ResponseWriter { return c.res }
 func (c *wrapper) Middleware(h middleware.Handler) middleware.Handler {
 	return middleware.Chain(c.router.srv.ms...)(h)
 }
-func (c *wrapper) Bind(v interface{}) error      { return c.router.srv.dec(c.req, v) }
-func (c *wrapper) BindVars(v interface{}) error  { return binding.BindQuery(c.Vars(), v) }
-func (c *wrapper) BindQuery(v interface{}) error { return binding.BindQuery(c.Query(), v) }
-func (c *wrapper) BindForm(v interface{}) error  { return binding.BindForm(c.req, v) }
+func (c *wrapper) Bind(v interface{}) error      { return bindError(c.router.srv.dec(c.req, v)) }
+func (c *wrapper) BindVars(v interface{}) error  { return bindError(binding.BindQuery(c.Vars(), v)) }
+func (c *wrapper) BindQuery(v interface{}) error { return bindError(binding.BindQuery(c.Query(), v)) }
+func (c *wrapper) BindForm(v interface{}) error  { return bindError(binding.BindForm(c.req, v)) }
+
+// bindError 参数解析失败属于客户端错误
+func bindError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if se := new(errors.Error); errors.As(err, &se) {
+		return se
+	}
+	return errors.BadRequest("CODEC", err.Error())
+}
+
 func (c *wrapper) Returns(v interface{}, err error) error {
 	if err != nil {
 		return err
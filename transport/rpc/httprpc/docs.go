@@ -0,0 +1,489 @@
+package httprpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// routeAnyMethod 不区分method的路由
+const routeAnyMethod = "*"
+
+// RouteInfo 路由表中的一条路由
+type RouteInfo struct {
+	Method    string   `json:"method"`
+	Path      string   `json:"path"`
+	Operation string   `json:"operation,omitempty"`
+	Filters   []string `json:"filters,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+}
+
+// RouteTable 路由表，Filters为server级别的filter
+type RouteTable struct {
+	Filters []string    `json:"filters,omitempty"`
+	Routes  []RouteInfo `json:"routes"`
+}
+
+// WithServerDocs 在prefix下提供路由表和接口文档，默认不开启
+//
+//	prefix/routes       路由表json
+//	prefix/openapi      openapi文档，openapi为空时不提供，可以用embed嵌入protoc-gen-openapi生成的文档
+//	prefix/             文档页面
+func WithServerDocs(prefix string, openapi []byte) Option {
+	return func(o ISetOption) {
+		server, ok := o.(*ServerConn)
+		if !ok {
+			return
+		}
+		prefix = strings.Trim(prefix, "/")
+		if len(prefix) > 0 {
+			prefix = "/" + prefix
+		}
+		server.docs = &docs{prefix: prefix, openapi: openapi}
+	}
+}
+
+type docs struct {
+	prefix  string
+	openapi []byte
+}
+
+// addRoute 记录路由
+func (s *ServerConn) addRoute(method, p, operation string, filters []FilterFunc) {
+	route := RouteInfo{Method: method, Path: p, Operation: operation}
+	for _, f := range filters {
+		route.Filters = append(route.Filters, funcName(f))
+	}
+	s.routesMu.Lock()
+	s.routes = append(s.routes, route)
+	s.routesMu.Unlock()
+}
+
+// Routes 已注册的路由，按path和method排序
+func (s *ServerConn) Routes() *RouteTable {
+	table := &RouteTable{}
+	for _, f := range s.filters {
+		table.Filters = append(table.Filters, funcName(f))
+	}
+	s.routesMu.RLock()
+	table.Routes = append(table.Routes, s.routes...)
+	s.routesMu.RUnlock()
+	sort.SliceStable(table.Routes, func(i, j int) bool {
+		if table.Routes[i].Path != table.Routes[j].Path {
+			return table.Routes[i].Path < table.Routes[j].Path
+		}
+		return table.Routes[i].Method < table.Routes[j].Method
+	})
+	return table
+}
+
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return fn.Name()
+}
+
+// mountDocs 注册文档路由，不记录到路由表
+func (s *ServerConn) mountDocs() {
+	d := s.docs
+	s.router.Handle(d.prefix+"/routes", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Routes())
+	})).Methods(http.MethodGet)
+	if len(d.openapi) > 0 {
+		contentType := "application/yaml"
+		if bytes.HasPrefix(bytes.TrimSpace(d.openapi), []byte("{")) {
+			contentType = "application/json"
+		}
+		s.router.Handle(d.prefix+"/openapi", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write(d.openapi)
+		})).Methods(http.MethodGet)
+	}
+	spec := parseOpenAPI(d.openapi)
+	s.router.Handle(d.prefix+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		table := s.Routes()
+		for i := range table.Routes {
+			route := &table.Routes[i]
+			if op := spec.operation(route.Method, stripPathPattern(route.Path)); op != nil {
+				route.Summary = op.summary()
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = docsPage.Execute(w, map[string]interface{}{
+			"Prefix":     d.prefix,
+			"OpenAPI":    len(d.openapi) > 0,
+			"Table":      table,
+			"Operations": spec.operations(),
+			"Schemas":    spec.schemas(),
+		})
+	})).Methods(http.MethodGet)
+}
+
+// apiSpec 文档页面用到的openapi字段
+type apiSpec struct {
+	Paths      map[string]*apiPathItem `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*apiSchema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+// apiPathItem path下每个method的接口，其他字段忽略
+type apiPathItem struct {
+	Get     *apiOperation `yaml:"get"`
+	Put     *apiOperation `yaml:"put"`
+	Post    *apiOperation `yaml:"post"`
+	Delete  *apiOperation `yaml:"delete"`
+	Patch   *apiOperation `yaml:"patch"`
+	Head    *apiOperation `yaml:"head"`
+	Options *apiOperation `yaml:"options"`
+}
+
+// byMethod 按GET PUT POST DELETE PATCH HEAD OPTIONS的顺序返回
+func (item *apiPathItem) byMethod() []*apiOperation {
+	return []*apiOperation{item.Get, item.Put, item.Post, item.Delete, item.Patch, item.Head, item.Options}
+}
+
+var apiMethods = []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch, http.MethodHead, http.MethodOptions}
+
+type apiOperation struct {
+	Method      string          `yaml:"-"`
+	Path        string          `yaml:"-"`
+	OperationID string          `yaml:"operationId"`
+	Summary     string          `yaml:"summary"`
+	Description string          `yaml:"description"`
+	Deprecated  bool            `yaml:"deprecated"`
+	Parameters  []*apiParameter `yaml:"parameters"`
+	RequestBody *struct {
+		Content map[string]*apiMediaType `yaml:"content"`
+	} `yaml:"requestBody"`
+	Responses map[string]*apiResponse `yaml:"responses"`
+}
+
+type apiParameter struct {
+	Name        string     `yaml:"name"`
+	In          string     `yaml:"in"`
+	Description string     `yaml:"description"`
+	Required    bool       `yaml:"required"`
+	Schema      *apiSchema `yaml:"schema"`
+}
+
+type apiMediaType struct {
+	Schema *apiSchema `yaml:"schema"`
+}
+
+type apiResponse struct {
+	Code        string                   `yaml:"-"`
+	Description string                   `yaml:"description"`
+	Content     map[string]*apiMediaType `yaml:"content"`
+}
+
+type apiSchema struct {
+	Name                 string                `yaml:"-"`
+	Ref                  string                `yaml:"$ref"`
+	AllOf                []*apiSchema          `yaml:"allOf"`
+	Type                 string                `yaml:"type"`
+	Format               string                `yaml:"format"`
+	Description          string                `yaml:"description"`
+	Enum                 []string              `yaml:"enum"`
+	Items                *apiSchema            `yaml:"items"`
+	Properties           map[string]*apiSchema `yaml:"properties"`
+	AdditionalProperties *apiSchema            `yaml:"additionalProperties"`
+}
+
+// apiField 对象的一个属性
+type apiField struct {
+	Name   string
+	Schema *apiSchema
+}
+
+// parseOpenAPI json是yaml的子集，解析失败时返回空文档
+func parseOpenAPI(doc []byte) *apiSpec {
+	spec := &apiSpec{}
+	if len(doc) == 0 {
+		return spec
+	}
+	if err := yaml.Unmarshal(doc, spec); err != nil {
+		return &apiSpec{}
+	}
+	for p, item := range spec.Paths {
+		if item == nil {
+			delete(spec.Paths, p)
+			continue
+		}
+		for i, op := range item.byMethod() {
+			if op != nil {
+				op.Method, op.Path = apiMethods[i], p
+			}
+		}
+	}
+	for name, schema := range spec.Components.Schemas {
+		if schema != nil {
+			schema.Name = name
+		}
+	}
+	return spec
+}
+
+// operation 文档中的path变量没有正则，路由中的{name:pattern}去掉正则后匹配
+func (spec *apiSpec) operation(method, p string) *apiOperation {
+	item, ok := spec.Paths[p]
+	if !ok {
+		return nil
+	}
+	for i, op := range item.byMethod() {
+		if apiMethods[i] == method {
+			return op
+		}
+	}
+	return nil
+}
+
+// operations 按path和method排序
+func (spec *apiSpec) operations() []*apiOperation {
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var ops []*apiOperation
+	for _, p := range paths {
+		for _, op := range spec.Paths[p].byMethod() {
+			if op != nil {
+				ops = append(ops, op)
+			}
+		}
+	}
+	return ops
+}
+
+// schemas 按名字排序
+func (spec *apiSpec) schemas() []*apiSchema {
+	var schemas []*apiSchema
+	for _, schema := range spec.Components.Schemas {
+		if schema != nil {
+			schemas = append(schemas, schema)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+	return schemas
+}
+
+// summary 没有summary时用description
+func (op *apiOperation) summary() string {
+	if len(op.Summary) > 0 {
+		return op.Summary
+	}
+	return op.Description
+}
+
+// Body 请求体的schema，优先json
+func (op *apiOperation) Body() *apiSchema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return contentSchema(op.RequestBody.Content)
+}
+
+// SortedResponses 按状态码排序，default在最后
+func (op *apiOperation) SortedResponses() []*apiResponse {
+	var rsps []*apiResponse
+	for code, rsp := range op.Responses {
+		if rsp == nil {
+			continue
+		}
+		rsp.Code = code
+		rsps = append(rsps, rsp)
+	}
+	sort.Slice(rsps, func(i, j int) bool {
+		if (rsps[i].Code == "default") != (rsps[j].Code == "default") {
+			return rsps[j].Code == "default"
+		}
+		return rsps[i].Code < rsps[j].Code
+	})
+	return rsps
+}
+
+// Schema 回复的schema
+func (rsp *apiResponse) Schema() *apiSchema {
+	return contentSchema(rsp.Content)
+}
+
+func contentSchema(content map[string]*apiMediaType) *apiSchema {
+	mt, ok := content["application/json"]
+	if !ok {
+		for _, v := range content {
+			mt = v
+			break
+		}
+	}
+	if mt == nil {
+		return nil
+	}
+	return mt.Schema
+}
+
+// Fields 按名字排序的属性
+func (schema *apiSchema) Fields() []apiField {
+	fields := make([]apiField, 0, len(schema.Properties))
+	for name, s := range schema.Properties {
+		fields = append(fields, apiField{Name: name, Schema: s})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// Desc 自身没有说明时用allOf中的说明
+func (schema *apiSchema) Desc() string {
+	if schema == nil {
+		return ""
+	}
+	if len(schema.Description) > 0 {
+		return schema.Description
+	}
+	for _, s := range schema.AllOf {
+		if len(s.Description) > 0 {
+			return s.Description
+		}
+	}
+	return ""
+}
+
+// schemaType schema的简短类型，引用链接到schemas中的定义
+func schemaType(schema *apiSchema) template.HTML {
+	if schema == nil {
+		return ""
+	}
+	esc := template.HTMLEscapeString
+	switch {
+	case len(schema.Ref) > 0:
+		name := schema.Ref[strings.LastIndex(schema.Ref, "/")+1:]
+		return template.HTML(`<a href="#schema-` + esc(name) + `">` + esc(name) + `</a>`)
+	case len(schema.AllOf) == 1:
+		return schemaType(schema.AllOf[0])
+	case schema.Type == "array":
+		return "[]" + schemaType(schema.Items)
+	case schema.AdditionalProperties != nil:
+		return "map&lt;string, " + schemaType(schema.AdditionalProperties) + "&gt;"
+	case len(schema.Enum) > 0:
+		return template.HTML("enum(" + esc(strings.Join(schema.Enum, " | ")) + ")")
+	case len(schema.Format) > 0:
+		return template.HTML(esc(schema.Type) + "(" + esc(schema.Format) + ")")
+	case len(schema.Type) > 0:
+		return template.HTML(esc(schema.Type))
+	}
+	return "any"
+}
+
+var pathPattern = regexp.MustCompile(`{([^{}:]+):[^{}]*}`)
+
+// stripPathPattern {name:pattern}转成{name}
+func stripPathPattern(p string) string {
+	return pathPattern.ReplaceAllString(p, "{$1}")
+}
+
+var docsPage = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"join":       strings.Join,
+	"schemaType": schemaType,
+	"lower":      strings.ToLower,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+.method { font-weight: bold; font-family: monospace; }
+.path, .filters, .type { font-family: monospace; }
+.op { border: 1px solid #ddd; border-radius: 4px; margin-bottom: 1em; }
+.op summary { padding: 8px; cursor: pointer; background: #fafafa; }
+.op > div { padding: 0 1em; }
+.get { color: #2f8132; } .post { color: #186faf; } .put { color: #95507c; }
+.delete { color: #c33; } .patch { color: #b7791f; }
+.deprecated { text-decoration: line-through; }
+.desc { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h2>Routes</h2>
+<p><a href="{{.Prefix}}/routes">routes.json</a>{{if .OpenAPI}} | <a href="{{.Prefix}}/openapi">openapi</a>{{end}}</p>
+{{- if .Table.Filters}}
+<p>Server filters: <span class="filters">{{join .Table.Filters ", "}}</span></p>
+{{- end}}
+<table>
+<tr><th>Method</th><th>Path</th><th>Operation</th><th>Summary</th><th>Filters</th></tr>
+{{- range .Table.Routes}}
+<tr><td class="method">{{.Method}}</td><td class="path">{{.Path}}</td><td>{{.Operation}}</td><td>{{.Summary}}</td><td class="filters">{{range .Filters}}{{.}}<br>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- if .Operations}}
+<h2>Operations</h2>
+{{- range .Operations}}
+<details class="op" id="op-{{.OperationID}}">
+<summary><span class="method {{lower .Method}}">{{.Method}}</span> <span class="path{{if .Deprecated}} deprecated{{end}}">{{.Path}}</span> {{.Summary}}</summary>
+<div>
+{{- if .Description}}<p class="desc">{{.Description}}</p>{{end}}
+<p>operationId: <span class="type">{{.OperationID}}</span></p>
+{{- if .Parameters}}
+<h4>Parameters</h4>
+<table>
+<tr><th>Name</th><th>In</th><th>Type</th><th>Required</th><th>Description</th></tr>
+{{- range .Parameters}}
+<tr><td class="path">{{.Name}}</td><td>{{.In}}</td><td class="type">{{schemaType .Schema}}</td><td>{{if .Required}}yes{{end}}</td><td class="desc">{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Body}}
+<h4>Request body</h4>
+<p class="type">{{schemaType .}}</p>
+{{- end}}
+{{- if .SortedResponses}}
+<h4>Responses</h4>
+<table>
+<tr><th>Code</th><th>Type</th><th>Description</th></tr>
+{{- range .SortedResponses}}
+<tr><td>{{.Code}}</td><td class="type">{{schemaType .Schema}}</td><td class="desc">{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</div>
+</details>
+{{- end}}
+{{- end}}
+{{- if .Schemas}}
+<h2>Schemas</h2>
+{{- range .Schemas}}
+<h3 id="schema-{{.Name}}" class="type">{{.Name}}</h3>
+{{- if .Desc}}<p class="desc">{{.Desc}}</p>{{end}}
+{{- if .Properties}}
+<table>
+<tr><th>Field</th><th>Type</th><th>Description</th></tr>
+{{- range .Fields}}
+<tr><td class="path">{{.Name}}</td><td class="type">{{schemaType .Schema}}</td><td class="desc">{{.Schema.Desc}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="type">{{schemaType .}}</p>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,145 @@
+package httprpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testOpenAPI = `openapi: 3.0.3
+paths:
+  /v1/users/{name}:
+    parameters:
+      - name: name
+        in: path
+    get:
+      summary: 获取用户
+      operationId: User_Get
+      parameters:
+        - name: name
+          in: path
+          required: true
+          schema:
+            type: string
+        - name: fields
+          in: query
+          description: 返回的字段
+          schema:
+            type: array
+            items:
+              type: string
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/test.User'
+        default:
+          description: Error
+components:
+  schemas:
+    test.User:
+      type: object
+      description: 用户
+      properties:
+        name:
+          type: string
+          description: 用户名
+        age:
+          type: string
+          format: int64
+        tags:
+          type: object
+          additionalProperties:
+            type: string
+`
+
+func newDocsServer(t *testing.T, opts ...Option) *ServerConn {
+	t.Helper()
+	srv, err := NewServerConn(append([]Option{WithAddress("127.0.0.1:0"), WithServerFilter(loggingFilter)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.lis.Close() })
+	r := srv.Route("/v1", corsFilter)
+	r.HandleOperation(http.MethodGet, "/users/{name:[a-z]+}", "/test.User/Get", func(ctx Context) error {
+		return ctx.Result(200, &User{Name: ctx.Vars().Get("name")})
+	}, authFilter)
+	r.POST("/users", func(ctx Context) error {
+		return nil
+	})
+	srv.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	return srv
+}
+
+func TestRoutes(t *testing.T) {
+	srv := newDocsServer(t)
+	table := srv.Routes()
+	if len(table.Filters) != 1 || !strings.HasSuffix(table.Filters[0], ".loggingFilter") {
+		t.Fatalf("unexpected server filters %v", table.Filters)
+	}
+	expect := []RouteInfo{
+		{Method: routeAnyMethod, Path: "/healthz"},
+		{Method: http.MethodPost, Path: "/v1/users", Filters: []string{"corsFilter"}},
+		{Method: http.MethodGet, Path: "/v1/users/{name:[a-z]+}", Operation: "/test.User/Get", Filters: []string{"corsFilter", "authFilter"}},
+	}
+	if len(table.Routes) != len(expect) {
+		t.Fatalf("unexpected routes %+v", table.Routes)
+	}
+	for i, route := range table.Routes {
+		e := expect[i]
+		if route.Method != e.Method || route.Path != e.Path || route.Operation != e.Operation || len(route.Filters) != len(e.Filters) {
+			t.Fatalf("expect %+v, got %+v", e, route)
+		}
+		for j, f := range route.Filters {
+			if !strings.HasSuffix(f, "."+e.Filters[j]) {
+				t.Fatalf("expect filter %s, got %s", e.Filters[j], f)
+			}
+		}
+	}
+
+	// 默认不开启
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/routes", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect docs disabled, got %d", w.Code)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	srv := newDocsServer(t, WithServerDocs("/debug/api/", []byte(testOpenAPI)))
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/api/routes", nil))
+	table := &RouteTable{}
+	if err := json.Unmarshal(w.Body.Bytes(), table); err != nil {
+		t.Fatal(err)
+	}
+	// 文档路由不在路由表中
+	if len(table.Routes) != 3 {
+		t.Fatalf("unexpected routes %+v", table.Routes)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/api/openapi", nil))
+	if w.Body.String() != testOpenAPI || w.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("unexpected openapi %s %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/api/", nil))
+	body := w.Body.String()
+	for _, s := range []string{
+		"/v1/users/{name:[a-z]", "/test.User/Get", "获取用户", ".authFilter",
+		// openapi中的接口、参数和schema
+		`id="op-User_Get"`, "返回的字段", "[]string", `<a href="#schema-test.User">test.User</a>`,
+		`id="schema-test.User"`, "用户名", "string(int64)", "map&lt;string, string&gt;",
+	} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("missing %q in page:\n%s", s, body)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package httprpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient 连接到测试server的client
+func newTestClient(t *testing.T, ts *httptest.Server, opts ...Option) *ClientConn {
+	t.Helper()
+	conn, err := NewClientConn(context.Background(), append([]Option{WithAddress(ts.Listener.Addr().String())}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
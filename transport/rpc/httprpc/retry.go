@@ -0,0 +1,284 @@
+package httprpc
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/liuwangchen/toy/selector"
+	"github.com/liuwangchen/toy/transport/errors"
+)
+
+// DefaultRetryCodes 默认重试的状态码
+var DefaultRetryCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy 重试策略
+// 连接错误和Codes中的状态码会重试，每次重试优先选择没有请求过的节点
+// 默认只重试幂等方法(GET/HEAD/OPTIONS/PUT/DELETE/TRACE)
+type RetryPolicy struct {
+	MaxAttempts   int           // 最多请求次数，包括第一次，<=1不重试
+	Backoff       time.Duration // 第一次重试前的等待，之后每次翻倍
+	MaxBackoff    time.Duration // 等待上限，0不限制
+	Jitter        float64       // 等待时间随机浮动的比例，0~1
+	Codes         []int         // 重试的状态码，为空时使用DefaultRetryCodes
+	NonIdempotent bool          // POST/PATCH等非幂等方法也重试
+	HedgeDelay    time.Duration // >0时开启对冲，HedgeDelay内没有结果就向其他节点再发一次，最多MaxAttempts个请求同时进行
+}
+
+// WithClientRetry 客户端默认的重试策略，可以用Retry在单次调用覆盖
+func WithClientRetry(policy *RetryPolicy) Option {
+	return func(o ISetOption) {
+		c, ok := o.(*ClientConn)
+		if !ok {
+			return
+		}
+		c.retry = policy
+	}
+}
+
+// Retry 单次调用的重试策略，nil表示不重试
+func Retry(policy *RetryPolicy) CallOption {
+	return RetryCallOption{Policy: policy}
+}
+
+// RetryCallOption is set retry policy for client call
+type RetryCallOption struct {
+	EmptyCallOption
+	Policy *RetryPolicy
+}
+
+func (o RetryCallOption) before(c *callInfo) error {
+	c.retry = o.Policy
+	c.retrySet = true
+	return nil
+}
+
+// allow 是否允许对method重试
+func (p *RetryPolicy) allow(method string) bool {
+	if p == nil || p.MaxAttempts <= 1 {
+		return false
+	}
+	if p.NonIdempotent {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// retryable err是否可以重试
+func (p *RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || err == selector.ErrNoAvailable {
+		return false
+	}
+	se := new(errors.Error)
+	if !errors.As(err, &se) {
+		// 连接错误
+		return true
+	}
+	codes := p.Codes
+	if len(codes) == 0 {
+		codes = DefaultRetryCodes
+	}
+	for _, code := range codes {
+		if int(se.Code) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 第attempt次重试前的等待
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 && d > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// triedNodes 已经请求过的节点
+type triedNodes struct {
+	mu    sync.Mutex
+	addrs map[string]struct{}
+}
+
+func (t *triedNodes) add(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.addrs == nil {
+		t.addrs = make(map[string]struct{})
+	}
+	t.addrs[addr] = struct{}{}
+}
+
+// filter 过滤掉请求过的节点，都请求过时不过滤
+func (t *triedNodes) filter(_ context.Context, nodes []selector.Node) []selector.Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.addrs) == 0 {
+		return nodes
+	}
+	filtered := make([]selector.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := t.addrs[n.Address()]; !ok {
+			filtered = append(filtered, n)
+		}
+	}
+	if len(filtered) == 0 {
+		return nodes
+	}
+	return filtered
+}
+
+// attemptRequest 每次请求复制req并重建body
+func attemptRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	r := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// doRetry 按策略重试或对冲
+func (conn *ClientConn) doRetry(ctx context.Context, req *http.Request, policy *RetryPolicy) (*http.Response, error) {
+	// body不能重建时不重试
+	if !policy.allow(req.Method) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return conn.do(req.WithContext(ctx))
+	}
+	if policy.HedgeDelay > 0 {
+		return conn.doHedge(ctx, req, policy)
+	}
+	tried := &triedNodes{}
+	for attempt := 1; ; attempt++ {
+		r, err := attemptRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		res, err := conn.doAttempt(r, tried)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(ctx, err) {
+			return res, err
+		}
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+type hedgeResult struct {
+	idx int
+	res *http.Response
+	err error
+}
+
+// doHedge 对冲请求，取第一个成功或不可重试的结果，其余请求取消
+func (conn *ClientConn) doHedge(ctx context.Context, req *http.Request, policy *RetryPolicy) (*http.Response, error) {
+	results := make(chan hedgeResult, policy.MaxAttempts)
+	cancels := make([]context.CancelFunc, 0, policy.MaxAttempts)
+	tried := &triedNodes{}
+	send := func() {
+		actx, cancel := context.WithCancel(ctx)
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		r, err := attemptRequest(actx, req)
+		if err != nil {
+			results <- hedgeResult{idx: idx, err: err}
+			return
+		}
+		go func() {
+			res, err := conn.doAttempt(r, tried)
+			results <- hedgeResult{idx: idx, res: res, err: err}
+		}()
+	}
+	// 取消其余请求，成功的关闭body
+	abort := func(winner, n int) {
+		for i, cancel := range cancels {
+			if i != winner {
+				cancel()
+			}
+		}
+		go func() {
+			for i := 0; i < n; i++ {
+				if hr := <-results; hr.res != nil {
+					_ = hr.res.Body.Close()
+				}
+			}
+		}()
+	}
+
+	send()
+	done := 0
+	timer := time.NewTimer(policy.HedgeDelay)
+	defer timer.Stop()
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < policy.MaxAttempts {
+				send()
+				timer.Reset(policy.HedgeDelay)
+			}
+		case hr := <-results:
+			done++
+			if hr.err == nil || !policy.retryable(ctx, hr.err) {
+				abort(hr.idx, len(cancels)-done)
+				if hr.err != nil {
+					cancels[hr.idx]()
+					return nil, hr.err
+				}
+				// body读完关闭时再取消
+				hr.res.Body = &cancelBody{ReadCloser: hr.res.Body, cancel: cancels[hr.idx]}
+				return hr.res, nil
+			}
+			lastErr = hr.err
+			if len(cancels) < policy.MaxAttempts {
+				// 失败了立刻补发
+				send()
+				timer.Reset(policy.HedgeDelay)
+			} else if done == len(cancels) {
+				abort(-1, 0)
+				return nil, lastErr
+			}
+		case <-ctx.Done():
+			abort(-1, len(cancels)-done)
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+			return nil, lastErr
+		}
+	}
+}
+
+// cancelBody 关闭body时取消请求的ctx
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
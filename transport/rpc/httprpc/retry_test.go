@@ -0,0 +1,207 @@
+package httprpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/registry"
+	"github.com/liuwangchen/toy/transport/errors"
+)
+
+// staticDiscovery 固定节点的discovery
+type staticDiscovery struct {
+	services []*registry.ServiceInstance
+}
+
+func (d *staticDiscovery) GetService(ctx context.Context, name string) ([]*registry.ServiceInstance, error) {
+	return d.services, nil
+}
+
+func (d *staticDiscovery) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	return &staticWatcher{services: d.services, stop: make(chan struct{})}, nil
+}
+
+type staticWatcher struct {
+	services []*registry.ServiceInstance
+	sent     bool
+	stop     chan struct{}
+}
+
+func (w *staticWatcher) Next() ([]*registry.ServiceInstance, error) {
+	if !w.sent {
+		w.sent = true
+		return w.services, nil
+	}
+	<-w.stop
+	return nil, context.Canceled
+}
+
+func (w *staticWatcher) Stop() error {
+	close(w.stop)
+	return nil
+}
+
+// countServer 记录请求次数，按status返回
+func countServer(t *testing.T, status int, delay time.Duration) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"name":"ok"}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &hits
+}
+
+func newDiscoveryClient(t *testing.T, addrs []string, opts ...Option) *ClientConn {
+	t.Helper()
+	d := &staticDiscovery{}
+	for i, addr := range addrs {
+		d.services = append(d.services, &registry.ServiceInstance{
+			ID:        string(rune('a' + i)),
+			Name:      "retry",
+			Endpoints: []string{"http://" + addr},
+		})
+	}
+	conn, err := NewClientConn(context.Background(), append([]Option{
+		WithAddress("discovery:///retry"),
+		WithClientDiscovery(d),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestRetryOtherNode(t *testing.T) {
+	bad, badHits := countServer(t, http.StatusServiceUnavailable, 0)
+	good, goodHits := countServer(t, http.StatusOK, 0)
+	conn := newDiscoveryClient(t, []string{bad.Listener.Addr().String(), good.Listener.Addr().String()},
+		WithClientRetry(&RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}))
+	for i := 0; i < 10; i++ {
+		var reply User
+		if err := conn.Invoke(context.Background(), http.MethodGet, "/user", nil, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Name != "ok" {
+			t.Fatalf("unexpected reply %+v", reply)
+		}
+	}
+	// 失败的节点每次调用最多请求一次
+	if atomic.LoadInt32(goodHits) != 10 || atomic.LoadInt32(badHits) > 10 {
+		t.Fatalf("good hits %d, bad hits %d", *goodHits, *badHits)
+	}
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := lis.Addr().String()
+	_ = lis.Close()
+	good, _ := countServer(t, http.StatusOK, 0)
+	conn := newDiscoveryClient(t, []string{dead, good.Listener.Addr().String()},
+		WithClientRetry(&RetryPolicy{MaxAttempts: 2}))
+	for i := 0; i < 4; i++ {
+		if err := conn.Invoke(context.Background(), http.MethodDelete, "/user", nil, &User{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRetryConditions(t *testing.T) {
+	unavailable, hits := countServer(t, http.StatusServiceUnavailable, 0)
+	notFound, notFoundHits := countServer(t, http.StatusNotFound, 0)
+	policy := &RetryPolicy{MaxAttempts: 3}
+
+	conn := newDiscoveryClient(t, []string{unavailable.Listener.Addr().String()}, WithClientRetry(policy))
+	// 非幂等方法默认不重试
+	err := conn.Invoke(context.Background(), http.MethodPost, "/user", &User{Name: "a"}, nil)
+	if errors.Code(err) != http.StatusServiceUnavailable || atomic.LoadInt32(hits) != 1 {
+		t.Fatalf("post: err %v, hits %d", err, *hits)
+	}
+	// 单次调用打开非幂等重试，body每次都重新发送
+	err = conn.Invoke(context.Background(), http.MethodPost, "/user", &User{Name: "a"}, nil,
+		Retry(&RetryPolicy{MaxAttempts: 3, NonIdempotent: true}))
+	if errors.Code(err) != http.StatusServiceUnavailable || atomic.LoadInt32(hits) != 4 {
+		t.Fatalf("post retry: err %v, hits %d", err, *hits)
+	}
+	// 单次调用关闭重试
+	err = conn.Invoke(context.Background(), http.MethodGet, "/user", nil, nil, Retry(nil))
+	if errors.Code(err) != http.StatusServiceUnavailable || atomic.LoadInt32(hits) != 5 {
+		t.Fatalf("retry off: err %v, hits %d", err, *hits)
+	}
+
+	// 404不重试
+	conn = newDiscoveryClient(t, []string{notFound.Listener.Addr().String()}, WithClientRetry(policy))
+	err = conn.Invoke(context.Background(), http.MethodGet, "/user", nil, nil)
+	if errors.Code(err) != http.StatusNotFound || atomic.LoadInt32(notFoundHits) != 1 {
+		t.Fatalf("404: err %v, hits %d", err, *notFoundHits)
+	}
+}
+
+func TestRetryContextDone(t *testing.T) {
+	unavailable, hits := countServer(t, http.StatusServiceUnavailable, 0)
+	conn := newDiscoveryClient(t, []string{unavailable.Listener.Addr().String()},
+		WithClientRetry(&RetryPolicy{MaxAttempts: 10, Backoff: time.Second}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := conn.Invoke(ctx, http.MethodGet, "/user", nil, nil)
+	if errors.Code(err) != http.StatusServiceUnavailable || time.Since(start) > time.Second {
+		t.Fatalf("err %v after %v", err, time.Since(start))
+	}
+	if atomic.LoadInt32(hits) != 1 {
+		t.Fatalf("hits %d", *hits)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	slow, _ := countServer(t, http.StatusOK, 2*time.Second)
+	fast, fastHits := countServer(t, http.StatusOK, 0)
+	conn := newDiscoveryClient(t, []string{slow.Listener.Addr().String(), fast.Listener.Addr().String()},
+		WithClientRetry(&RetryPolicy{MaxAttempts: 2, HedgeDelay: 20 * time.Millisecond}))
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		var reply User
+		if err := conn.Invoke(context.Background(), http.MethodGet, "/user", nil, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Name != "ok" || time.Since(start) > time.Second {
+			t.Fatalf("reply %+v after %v", reply, time.Since(start))
+		}
+	}
+	if atomic.LoadInt32(fastHits) != 4 {
+		t.Fatalf("fast hits %d", *fastHits)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if d := p.backoff(i + 1); d != w*time.Millisecond {
+			t.Fatalf("attempt %d: %v != %v", i+1, d, w*time.Millisecond)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(2); d < 10*time.Millisecond || d > 30*time.Millisecond {
+			t.Fatalf("jitter out of range %v", d)
+		}
+	}
+}
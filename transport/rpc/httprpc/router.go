@@ -39,6 +39,20 @@ func (r *Router) Group(prefix string, filters ...FilterFunc) *Router {
 
 // Handle registers a new route with a matcher for the URL path and method.
 func (r *Router) Handle(method, relativePath string, h HandlerFunc, filters ...FilterFunc) {
+	r.HandleOperation(method, relativePath, "", h, filters...)
+}
+
+// HandleOperation 同Handle，operation在路由表中显示，生成代码中为/package.Service/Method
+func (r *Router) HandleOperation(method, relativePath, operation string, h HandlerFunc, filters ...FilterFunc) {
+	r.handle(method, relativePath, operation, false, h, filters)
+}
+
+// HandleStream 注册websocket和服务端流的路由，不受server超时限制
+func (r *Router) HandleStream(method, relativePath, operation string, h HandlerFunc, filters ...FilterFunc) {
+	r.handle(method, relativePath, operation, true, h, filters)
+}
+
+func (r *Router) handle(method, relativePath, operation string, stream bool, h HandlerFunc, filters []FilterFunc) {
 	next := http.Handler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		ctx := r.pool.Get().(Context)
 		ctx.Reset(res, req)
@@ -50,7 +64,12 @@ func (r *Router) Handle(method, relativePath string, h HandlerFunc, filters ...F
 	}))
 	next = FilterChain(filters...)(next)
 	next = FilterChain(r.filters...)(next)
-	r.srv.router.Handle(path.Join(r.prefix, relativePath), next).Methods(method)
+	p := path.Join(r.prefix, relativePath)
+	route := r.srv.router.Handle(p, next).Methods(method)
+	r.srv.addRoute(method, p, operation, append(r.filters[:len(r.filters):len(r.filters)], filters...))
+	if stream {
+		r.srv.addStreamRoute(route)
+	}
 }
 
 // GET registers a new GET route for a path with matching handler in the router.
@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -103,20 +104,29 @@ func WithServerListener(lis net.Listener) Option {
 // ServerConn is an HTTP server wrapper.
 type ServerConn struct {
 	*http.Server
-	lis         net.Listener
-	tlsConf     *tls.Config
-	endpoint    *url.URL
-	network     string
-	address     string
-	timeout     time.Duration
-	filters     []FilterFunc
-	ms          []middleware.Middleware
-	dec         DecodeRequestFunc
-	enc         EncodeResponseFunc
-	ene         EncodeErrorFunc
-	strictSlash bool
-	router      *mux.Router
-	ready       bool
+	lis           net.Listener
+	tlsConf       *tls.Config
+	endpoint      *url.URL
+	network       string
+	address       string
+	timeout       time.Duration
+	filters       []FilterFunc
+	ms            []middleware.Middleware
+	dec           DecodeRequestFunc
+	enc           EncodeResponseFunc
+	ene           EncodeErrorFunc
+	strictSlash   bool
+	router        *mux.Router
+	ready         bool
+	routesMu      sync.RWMutex
+	routes        []RouteInfo
+	docs          *docs
+	wsPing        time.Duration
+	wsCheckOrigin func(r *http.Request) bool
+	streamMu      sync.RWMutex
+	streamRoutes  map[*mux.Route]struct{} // 不加超时的长连接路由
+	sseHeartbeat  time.Duration
+	sseResume     SSEResumeFunc
 }
 
 func (s *ServerConn) SetTimeout(timeout time.Duration) {
@@ -138,19 +148,24 @@ func (s *ServerConn) SetTLSConfig(c *tls.Config) {
 // NewServerConn creates an HTTP server by options.
 func NewServerConn(opts ...Option) (*ServerConn, error) {
 	srv := &ServerConn{
-		network:     "tcp",
-		address:     ":80",
-		timeout:     1 * time.Second,
-		dec:         DefaultRequestDecoder,
-		enc:         DefaultResponseEncoder,
-		ene:         DefaultErrorEncoder,
-		strictSlash: true,
+		network:      "tcp",
+		address:      ":80",
+		timeout:      1 * time.Second,
+		dec:          DefaultRequestDecoder,
+		enc:          DefaultResponseEncoder,
+		ene:          DefaultErrorEncoder,
+		strictSlash:  true,
+		wsPing:       defaultWebSocketPing,
+		sseHeartbeat: defaultSSEHeartbeat,
 	}
 	for _, o := range opts {
 		o(srv)
 	}
 	srv.router = mux.NewRouter().StrictSlash(srv.strictSlash)
 	srv.router.Use(srv.filter())
+	if srv.docs != nil {
+		srv.mountDocs()
+	}
 	srv.Server = &http.Server{
 		Handler:   FilterChain(srv.filters...)(srv.router),
 		TLSConfig: srv.tlsConf,
@@ -170,16 +185,19 @@ func (s *ServerConn) Route(prefix string, filters ...FilterFunc) *Router {
 // Handle registers a new route with a matcher for the URL path.
 func (s *ServerConn) Handle(path string, h http.Handler) {
 	s.router.Handle(path, h)
+	s.addRoute(routeAnyMethod, path, "", nil)
 }
 
 // HandlePrefix registers a new route with a matcher for the URL path prefix.
 func (s *ServerConn) HandlePrefix(prefix string, h http.Handler) {
 	s.router.PathPrefix(prefix).Handler(h)
+	s.addRoute(routeAnyMethod, prefix+"*", "", nil)
 }
 
 // HandleFunc registers a new route with a matcher for the URL path.
 func (s *ServerConn) HandleFunc(path string, h http.HandlerFunc) {
 	s.router.HandleFunc(path, h)
+	s.addRoute(routeAnyMethod, path, "", nil)
 }
 
 // HandleHeader registers a new route with a matcher for the header.
@@ -187,6 +205,27 @@ func (s *ServerConn) HandleHeader(key, val string, h http.HandlerFunc) {
 	s.router.Headers(key, val).Handler(h)
 }
 
+// addStreamRoute 记录长连接路由
+func (s *ServerConn) addStreamRoute(route *mux.Route) {
+	s.streamMu.Lock()
+	if s.streamRoutes == nil {
+		s.streamRoutes = map[*mux.Route]struct{}{}
+	}
+	s.streamRoutes[route] = struct{}{}
+	s.streamMu.Unlock()
+}
+
+// isStreamRoute 是否是HandleStream注册的路由
+func (s *ServerConn) isStreamRoute(route *mux.Route) bool {
+	if route == nil {
+		return false
+	}
+	s.streamMu.RLock()
+	_, ok := s.streamRoutes[route]
+	s.streamMu.RUnlock()
+	return ok
+}
+
 // ServeHTTP should write reply headers and data to the ResponseWriter and then return.
 func (s *ServerConn) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	s.Handler.ServeHTTP(res, req)
@@ -199,7 +238,8 @@ func (s *ServerConn) filter() mux.MiddlewareFunc {
 				ctx    context.Context
 				cancel context.CancelFunc
 			)
-			if s.timeout > 0 {
+			// HandleStream注册的长连接路由不加超时，不能由请求头决定
+			if s.timeout > 0 && !s.isStreamRoute(mux.CurrentRoute(req)) {
 				ctx, cancel = context.WithTimeout(req.Context(), s.timeout)
 			} else {
 				ctx, cancel = context.WithCancel(req.Context())
@@ -0,0 +1,346 @@
+package httprpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/encoding"
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+// Server-Sent Events，Accept为text/event-stream时服务端流按事件输出
+//
+//	id: <id>
+//	event: <event>
+//	data: <codec编码的消息>
+//
+// 处理返回错误时发送event为error的事件，data为Status
+// 空闲时按心跳间隔发送注释行，避免代理断开连接
+
+const (
+	// EventStreamContentType SSE的Content-Type
+	EventStreamContentType = "text/event-stream"
+	// LastEventIDHeader 断线重连时浏览器带上最后收到的事件id
+	LastEventIDHeader = "Last-Event-ID"
+	// EventError 错误事件的名字
+	EventError = "error"
+
+	defaultSSEHeartbeat = 15 * time.Second
+)
+
+var _ IServerStream = (*SSEServerStream)(nil)
+var _ IClientStream = (*SSEClientStream)(nil)
+
+// SSEResumeFunc 客户端带Last-Event-ID重连时调用，可以补发断线期间的事件
+type SSEResumeFunc func(ctx context.Context, lastEventID string, stream IServerStream) error
+
+// WithSSEHeartbeat SSE心跳间隔，<=0不发送心跳
+func WithSSEHeartbeat(interval time.Duration) Option {
+	return func(o ISetOption) {
+		server, ok := o.(*ServerConn)
+		if !ok {
+			return
+		}
+		server.sseHeartbeat = interval
+	}
+}
+
+// WithSSEResume 断线重连的hook，由ResumeStream在中间件之内、处理函数之前调用
+func WithSSEResume(fn SSEResumeFunc) Option {
+	return func(o ISetOption) {
+		server, ok := o.(*ServerConn)
+		if !ok {
+			return
+		}
+		server.sseResume = fn
+	}
+}
+
+// EventStream 用SSE读取服务端流，lastEventID不为空时从该事件之后继续
+func EventStream(lastEventID string) CallOption {
+	return EventStreamCallOption{LastEventID: lastEventID}
+}
+
+// EventStreamCallOption is set event stream for client call
+type EventStreamCallOption struct {
+	EmptyCallOption
+	LastEventID string
+}
+
+func (o EventStreamCallOption) before(c *callInfo) error {
+	c.eventStream = true
+	c.lastEventID = o.LastEventID
+	return nil
+}
+
+// isEventStream 请求是否接受SSE
+func isEventStream(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		if strings.Contains(accept, EventStreamContentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// LastEventID 服务端ctx中请求的Last-Event-ID
+func LastEventID(ctx context.Context) string {
+	if tr, ok := rpc.FromServerContext(ctx); ok {
+		return tr.RequestHeader().Get(LastEventIDHeader)
+	}
+	return ""
+}
+
+// SendEvent 发送带id和事件名的消息，不是SSE时等同于SendMsg
+func SendEvent(stream IServerStream, id, event string, m interface{}) error {
+	if s, ok := stream.(*SSEServerStream); ok {
+		return s.SendEvent(id, event, m)
+	}
+	return stream.SendMsg(m)
+}
+
+// ServeStream 按Accept选择SSE或者原有的分行输出，fn返回后结束流
+// 路由需用HandleStream注册，否则受server超时限制
+func ServeStream(ctx Context, fn func(stream IServerStream) error) error {
+	req := ctx.Request()
+	codec := CodecForRequest(req, "Accept")
+	if !isEventStream(req) {
+		return fn(NewHttpServerStream(ctx.Response(), codec))
+	}
+	heartbeat := defaultSSEHeartbeat
+	stream := NewSSEServerStream(ctx.Response(), codec)
+	if w, ok := ctx.(*wrapper); ok {
+		heartbeat = w.router.srv.sseHeartbeat
+		stream.resume = w.router.srv.sseResume
+	}
+	stream.lastEventID = req.Header.Get(LastEventIDHeader)
+	defer stream.close()
+	if heartbeat > 0 {
+		go stream.heartbeat(req.Context(), heartbeat)
+	}
+	err := fn(stream)
+	if err == nil || !stream.started() {
+		// 还没有写回复时按普通请求返回错误
+		return err
+	}
+	se := errors.FromError(err)
+	_ = stream.SendEvent("", EventError, &se.Status)
+	return nil
+}
+
+// ResumeStream 客户端带Last-Event-ID重连时调用WithSSEResume的hook，只调用一次
+// 不是SSE或者没有hook时直接返回，生成代码在中间件之内、处理函数之前调用
+func ResumeStream(ctx context.Context, stream IServerStream) error {
+	s, ok := stream.(*SSEServerStream)
+	if !ok || s.resume == nil || len(s.lastEventID) == 0 || s.resumed {
+		return nil
+	}
+	s.resumed = true
+	return s.resume(ctx, s.lastEventID, stream)
+}
+
+// SSEServerStream 按SSE格式输出的服务端流
+type SSEServerStream struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	enc    encoding.Codec
+	begun  bool
+	closed bool
+	done   chan struct{}
+
+	resume      SSEResumeFunc
+	lastEventID string
+	resumed     bool
+}
+
+func NewSSEServerStream(w http.ResponseWriter, codec encoding.Codec) *SSEServerStream {
+	return &SSEServerStream{
+		w:    w,
+		enc:  codec,
+		done: make(chan struct{}),
+	}
+}
+
+func (s *SSEServerStream) SendMsg(m interface{}) error {
+	return s.SendEvent("", "", m)
+}
+
+// SendEvent 发送一个事件，id和event为空时不输出对应字段
+func (s *SSEServerStream) SendEvent(id, event string, m interface{}) error {
+	b, err := s.enc.Marshal(m)
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	if len(id) > 0 {
+		buf.WriteString("id: " + sseField(id) + "\n")
+	}
+	if len(event) > 0 {
+		buf.WriteString("event: " + sseField(event) + "\n")
+	}
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.Bytes())
+}
+
+func (s *SSEServerStream) RecvMsg(m interface{}) error {
+	return nil
+}
+
+// write 第一次写入时写头
+func (s *SSEServerStream) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	if !s.begun {
+		s.begun = true
+		h := s.w.Header()
+		h.Set("Content-Type", EventStreamContentType)
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		h.Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (s *SSEServerStream) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.begun
+}
+
+// heartbeat 定时发送注释行
+func (s *SSEServerStream) heartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// close 处理返回后ResponseWriter不能再用
+func (s *SSEServerStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// sseField id和event中不能有换行
+func sseField(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+// SSEClientStream 读取SSE的客户端流
+type SSEClientStream struct {
+	r           *bufio.Reader
+	body        io.Closer
+	dec         encoding.Codec
+	lastEventID string
+	event       string
+}
+
+func NewSSEClientStream(body io.ReadCloser, codec encoding.Codec) *SSEClientStream {
+	return &SSEClientStream{
+		r:    bufio.NewReader(body),
+		body: body,
+		dec:  codec,
+	}
+}
+
+func (s *SSEClientStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *SSEClientStream) CloseSend() error {
+	return nil
+}
+
+// LastEventID 最后收到的事件id，重连时传给EventStream
+func (s *SSEClientStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Event 最后收到的事件名
+func (s *SSEClientStream) Event() string {
+	return s.event
+}
+
+// RecvMsg 读取下一个有data的事件，error事件转成错误，结束时返回io.EOF
+func (s *SSEClientStream) RecvMsg(m interface{}) error {
+	var (
+		data  [][]byte
+		event string
+	)
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			_ = s.body.Close()
+			return err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			if data == nil {
+				event = ""
+				continue
+			}
+			s.event = event
+			b := bytes.Join(data, []byte("\n"))
+			if event == EventError {
+				e := new(errors.Error)
+				if err := s.dec.Unmarshal(b, &e.Status); err != nil {
+					return errors.New(errors.UnknownCode, errors.UnknownReason, string(b)).WithCause(err)
+				}
+				return e
+			}
+			return s.dec.Unmarshal(b, m)
+		}
+		// 注释，心跳
+		if line[0] == ':' {
+			continue
+		}
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+		switch string(field) {
+		case "id":
+			s.lastEventID = string(value)
+		case "event":
+			event = string(value)
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
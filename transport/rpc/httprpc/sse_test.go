@@ -0,0 +1,191 @@
+package httprpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+)
+
+func newSSEServer(t *testing.T, opts ...Option) *httptest.Server {
+	t.Helper()
+	srv, err := NewServerConn(append([]Option{WithAddress("127.0.0.1:0")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.lis.Close() })
+	r := srv.Route("/sse")
+	// 从Last-Event-ID之后发送到3，和生成代码一样在中间件之内补发
+	r.HandleStream(http.MethodGet, "/count", "", func(ctx Context) error {
+		return ServeStream(ctx, func(stream IServerStream) error {
+			h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+				if err := ResumeStream(ctx, stream); err != nil {
+					return nil, err
+				}
+				from, _ := strconv.Atoi(LastEventID(ctx))
+				for i := from + 1; i <= 3; i++ {
+					id := strconv.Itoa(i)
+					if err := SendEvent(stream, id, "count", &User{Name: id}); err != nil {
+						return nil, err
+					}
+				}
+				return nil, nil
+			})
+			_, err := h(ctx, nil)
+			return err
+		})
+	})
+	r.HandleStream(http.MethodGet, "/fail", "", func(ctx Context) error {
+		return ServeStream(ctx, func(stream IServerStream) error {
+			if err := stream.SendMsg(&User{Name: "a"}); err != nil {
+				return err
+			}
+			return errors.Conflict("USER_CONFLICT", "user\nconflict")
+		})
+	})
+	r.HandleStream(http.MethodGet, "/reject", "", func(ctx Context) error {
+		return ServeStream(ctx, func(stream IServerStream) error {
+			return errors.Forbidden("NO_PERMISSION", "no permission")
+		})
+	})
+	r.HandleStream(http.MethodGet, "/idle", "", func(ctx Context) error {
+		return ServeStream(ctx, func(stream IServerStream) error {
+			time.Sleep(60 * time.Millisecond)
+			return stream.SendMsg(&User{Name: "done"})
+		})
+	})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func recvNames(t *testing.T, stream IClientStream) []string {
+	t.Helper()
+	var names []string
+	for {
+		var u User
+		err := stream.RecvMsg(&u)
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, u.Name)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	ts := newSSEServer(t)
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/sse/count", nil, EventStream(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sse, ok := stream.(*SSEClientStream)
+	if !ok {
+		t.Fatalf("unexpected stream %T", stream)
+	}
+	if names := recvNames(t, stream); strings.Join(names, ",") != "1,2,3" {
+		t.Fatalf("got %v", names)
+	}
+	if sse.LastEventID() != "3" || sse.Event() != "count" {
+		t.Fatalf("last event id %q, event %q", sse.LastEventID(), sse.Event())
+	}
+
+	// 断线重连
+	stream, err = conn.Stream(context.Background(), http.MethodGet, "/sse/count", nil, EventStream("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := recvNames(t, stream); strings.Join(names, ",") != "2,3" {
+		t.Fatalf("got %v", names)
+	}
+
+	// 不是SSE时按行输出
+	stream, err = conn.Stream(context.Background(), http.MethodGet, "/sse/count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stream.(*HttpClientStream); !ok {
+		t.Fatalf("unexpected stream %T", stream)
+	}
+}
+
+func TestSSEFormat(t *testing.T) {
+	ts := newSSEServer(t, WithSSEHeartbeat(10*time.Millisecond))
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/sse/idle", nil)
+	req.Header.Set("Accept", EventStreamContentType)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != EventStreamContentType {
+		t.Fatalf("content type %q", ct)
+	}
+	b, _ := io.ReadAll(res.Body)
+	body := string(b)
+	if !strings.HasPrefix(body, ": ping\n\n") || !strings.HasSuffix(body, "data: {\"name\":\"done\"}\n\n") {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+type sseMiddlewareKey struct{}
+
+func TestSSEResume(t *testing.T) {
+	var resumed string
+	mw := func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler(context.WithValue(ctx, sseMiddlewareKey{}, true), req)
+		}
+	}
+	setMiddleware := func(o ISetOption) { o.SetConnMiddleWare(mw) }
+	ts := newSSEServer(t, setMiddleware, WithSSEResume(func(ctx context.Context, lastEventID string, stream IServerStream) error {
+		// hook在中间件之内
+		if ctx.Value(sseMiddlewareKey{}) == nil {
+			return errors.InternalServer("NO_MIDDLEWARE", "resume outside middleware")
+		}
+		resumed = lastEventID
+		return SendEvent(stream, "", "resume", &User{Name: "resume"})
+	}))
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/sse/count", nil, EventStream("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := recvNames(t, stream); strings.Join(names, ",") != "resume,3" || resumed != "2" {
+		t.Fatalf("got %v, resumed %q", names, resumed)
+	}
+}
+
+func TestSSEError(t *testing.T) {
+	ts := newSSEServer(t)
+	conn := newTestClient(t, ts)
+	// 已经开始输出时用error事件返回错误
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/sse/fail", nil, EventStream(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u User
+	if err := stream.RecvMsg(&u); err != nil || u.Name != "a" {
+		t.Fatalf("got %v, %v", u, err)
+	}
+	err = stream.RecvMsg(&u)
+	if !errors.IsConflict(err) || errors.Reason(err) != "USER_CONFLICT" || errors.FromError(err).Message != "user\nconflict" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 还没有输出时返回http错误
+	_, err = conn.Stream(context.Background(), http.MethodGet, "/sse/reject", nil, EventStream(""))
+	if !errors.IsForbidden(err) || errors.Reason(err) != "NO_PERMISSION" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
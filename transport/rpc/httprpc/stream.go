@@ -37,6 +37,10 @@ type IClientStream interface {
 	// calling RecvMsg on the same stream at the same time, but it is not
 	// safe to call RecvMsg on the same stream in different goroutines.
 	RecvMsg(m interface{}) error
+	// CloseSend closes the send direction of the stream. It closes the stream
+	// when non-nil error is met. It is also not safe to call CloseSend
+	// concurrently with SendMsg.
+	CloseSend() error
 }
 
 type IServerStream interface {
@@ -121,6 +125,10 @@ func (this *HttpClientStream) SendMsg(m interface{}) error {
 	return nil
 }
 
+func (this *HttpClientStream) CloseSend() error {
+	return nil
+}
+
 func (this *HttpClientStream) RecvMsg(v interface{}) error {
 	b, err := this.r.(*bufio.Reader).ReadBytes('\n')
 	if len(b) > 0 {
@@ -0,0 +1,422 @@
+package httprpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/liuwangchen/toy/pkg/httputil"
+	"github.com/liuwangchen/toy/selector"
+	"github.com/liuwangchen/toy/transport/encoding"
+	"github.com/liuwangchen/toy/transport/errors"
+)
+
+// websocket上的双向流
+//
+//	编码    Sec-WebSocket-Protocol协商codec名字(json/proto...)，默认json，每条消息一帧
+//	半关闭  客户端CloseSend发送一个与数据帧类型相反的空消息(json为binary，proto为text)，服务端RecvMsg返回io.EOF，之后仍可以发送
+//	        关闭帧只在流真正结束时发送
+//	结束    服务端处理返回后发送关闭帧，nil为1000，错误为4000+状态码，reason为"Reason: message"
+//	保活    双方每隔ping间隔发送ping，读取时2倍间隔内没有收到任何帧(包括ping)认为连接断开
+//	        应用没有调用RecvMsg的时间不计入
+
+const (
+	// wsCloseErrorBase 错误的关闭码为wsCloseErrorBase+http状态码
+	wsCloseErrorBase = 4000
+	// wsCloseWait 发送关闭帧后等待对端关闭的时间
+	wsCloseWait = time.Second
+
+	defaultWebSocketPing = 30 * time.Second
+)
+
+var _ IClientStream = (*WebSocketStream)(nil)
+var _ IServerStream = (*WebSocketStream)(nil)
+
+// WithWebSocketPing websocket保活的ping间隔，<=0不发送ping
+func WithWebSocketPing(interval time.Duration) Option {
+	return func(o ISetOption) {
+		switch c := o.(type) {
+		case *ServerConn:
+			c.wsPing = interval
+		case *ClientConn:
+			c.wsPing = interval
+		}
+	}
+}
+
+// WithWebSocketCheckOrigin 校验websocket握手的Origin，默认只允许同源
+func WithWebSocketCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(o ISetOption) {
+		server, ok := o.(*ServerConn)
+		if !ok {
+			return
+		}
+		server.wsCheckOrigin = fn
+	}
+}
+
+// WebSocket 用websocket发起流式调用，支持客户端流和双向流
+func WebSocket() CallOption {
+	return WebSocketCallOption{}
+}
+
+// WebSocketCallOption is set websocket stream for client call
+type WebSocketCallOption struct {
+	EmptyCallOption
+}
+
+func (o WebSocketCallOption) before(c *callInfo) error {
+	c.websocket = true
+	return nil
+}
+
+// WebSocketStream websocket双向流
+type WebSocketStream struct {
+	conn     *websocket.Conn
+	codec    encoding.Codec
+	msgType  int
+	ping     time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wmu      sync.Mutex
+	msgs     chan []byte
+	readDone chan struct{}
+	err      error
+	sendDone bool // 已经CloseSend，wmu保护
+}
+
+func newWebSocketStream(ctx context.Context, conn *websocket.Conn, codec encoding.Codec, ping time.Duration) *WebSocketStream {
+	s := &WebSocketStream{
+		conn:     conn,
+		codec:    codec,
+		msgType:  wsMessageType(codec),
+		ping:     ping,
+		msgs:     make(chan []byte),
+		readDone: make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	if ping > 0 {
+		conn.SetPongHandler(func(string) error {
+			return s.extendReadDeadline()
+		})
+		// 对端的ping也说明连接正常
+		conn.SetPingHandler(func(data string) error {
+			_ = s.extendReadDeadline()
+			err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(ping))
+			if err == websocket.ErrCloseSent {
+				return nil
+			}
+			if e, ok := err.(net.Error); ok && e.Temporary() {
+				return nil
+			}
+			return err
+		})
+	}
+	go s.readLoop()
+	go s.keepalive()
+	return s
+}
+
+// Context 流的ctx，连接断开或结束时取消
+func (s *WebSocketStream) Context() context.Context {
+	return s.ctx
+}
+
+// Codec 协商的编码
+func (s *WebSocketStream) Codec() encoding.Codec {
+	return s.codec
+}
+
+// extendReadDeadline 收到帧后延长读超时
+func (s *WebSocketStream) extendReadDeadline() error {
+	if s.ping <= 0 {
+		return nil
+	}
+	return s.conn.SetReadDeadline(time.Now().Add(2 * s.ping))
+}
+
+// readLoop 每次读之前设置超时，等待应用接收的时间不计入
+// 对端结束发送后继续读，处理ping和关闭帧
+func (s *WebSocketStream) readLoop() {
+	defer close(s.readDone)
+	eos := false
+	for {
+		_ = s.extendReadDeadline()
+		typ, b, err := s.conn.ReadMessage()
+		if err != nil {
+			if !eos {
+				s.err = s.readError(err)
+				close(s.msgs)
+			}
+			s.cancel()
+			return
+		}
+		if eos {
+			// 结束发送后的数据帧忽略
+			continue
+		}
+		if typ != s.msgType && len(b) == 0 {
+			eos = true
+			s.err = io.EOF
+			close(s.msgs)
+			continue
+		}
+		select {
+		case s.msgs <- b:
+		case <-s.ctx.Done():
+			s.err = s.ctx.Err()
+			close(s.msgs)
+			return
+		}
+	}
+}
+
+// keepalive 定时ping，结束时关闭连接
+func (s *WebSocketStream) keepalive() {
+	var tick <-chan time.Time
+	if s.ping > 0 {
+		ticker := time.NewTicker(s.ping)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-s.ctx.Done():
+			_ = s.conn.Close()
+			return
+		case <-tick:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.ping)); err != nil && err != websocket.ErrCloseSent {
+				s.cancel()
+			}
+		}
+	}
+}
+
+func (s *WebSocketStream) readError(err error) error {
+	if ce, ok := err.(*websocket.CloseError); ok {
+		return wsCloseToError(ce)
+	}
+	if s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+	return err
+}
+
+// SendMsg 发送一条消息
+func (s *WebSocketStream) SendMsg(m interface{}) error {
+	b, err := s.codec.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.sendDone {
+		return errors.BadRequest("WEBSOCKET_SEND_CLOSED", "send on closed stream")
+	}
+	return s.write(s.msgType, b)
+}
+
+// write 需持有wmu
+func (s *WebSocketStream) write(msgType int, b []byte) error {
+	if s.ping > 0 {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(2 * s.ping))
+	}
+	if err := s.conn.WriteMessage(msgType, b); err != nil {
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// RecvMsg 接收一条消息，对端正常结束返回io.EOF
+func (s *WebSocketStream) RecvMsg(m interface{}) error {
+	b, ok := <-s.msgs
+	if !ok {
+		return s.err
+	}
+	return s.codec.Unmarshal(b, m)
+}
+
+// CloseSend 客户端结束发送，仍可以接收服务端的消息
+func (s *WebSocketStream) CloseSend() error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.sendDone {
+		return nil
+	}
+	s.sendDone = true
+	return s.write(wsEOSType(s.msgType), nil)
+}
+
+// Close 结束流，发送err对应的关闭帧后关闭连接
+func (s *WebSocketStream) Close(err error) error {
+	code, text := errorToWsClose(err)
+	werr := s.writeClose(code, text)
+	timer := time.NewTimer(wsCloseWait)
+	defer timer.Stop()
+	select {
+	case <-s.readDone:
+	case <-timer.C:
+	}
+	s.cancel()
+	return werr
+}
+
+func (s *WebSocketStream) writeClose(code int, text string) error {
+	err := s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsCloseWait))
+	if err == websocket.ErrCloseSent {
+		return nil
+	}
+	return err
+}
+
+// wsMessageType 文本编码用TextMessage，浏览器可以直接读
+func wsMessageType(codec encoding.Codec) int {
+	switch codec.Name() {
+	case "json", "xml", "yaml":
+		return websocket.TextMessage
+	}
+	return websocket.BinaryMessage
+}
+
+// wsEOSType 结束发送的空消息类型，和数据帧相反
+func wsEOSType(msgType int) int {
+	if msgType == websocket.TextMessage {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+// errorToWsClose 错误转关闭码，reason超长时截断
+func errorToWsClose(err error) (int, string) {
+	if err == nil {
+		return websocket.CloseNormalClosure, ""
+	}
+	se := errors.FromError(err)
+	text := se.Reason + ": " + se.Message
+	// 关闭帧的payload最多125字节，2字节是关闭码
+	if len(text) > 123 {
+		text = text[:123]
+	}
+	return wsCloseErrorBase + int(se.Code), text
+}
+
+// wsCloseToError 关闭码转错误，1000为io.EOF
+func wsCloseToError(ce *websocket.CloseError) error {
+	switch {
+	case ce.Code == websocket.CloseNormalClosure:
+		return io.EOF
+	case ce.Code >= wsCloseErrorBase && ce.Code < wsCloseErrorBase+1000:
+		reason, message := ce.Text, ""
+		if i := strings.Index(ce.Text, ": "); i >= 0 {
+			reason, message = ce.Text[:i], ce.Text[i+2:]
+		}
+		return errors.New(ce.Code-wsCloseErrorBase, reason, message)
+	case ce.Code == websocket.CloseGoingAway:
+		return errors.ServiceUnavailable("WEBSOCKET_GOING_AWAY", ce.Text)
+	case ce.Code == websocket.ClosePolicyViolation:
+		return errors.Forbidden("WEBSOCKET_POLICY_VIOLATION", ce.Text)
+	case ce.Code == websocket.CloseMessageTooBig:
+		return errors.New(http.StatusRequestEntityTooLarge, "WEBSOCKET_MESSAGE_TOO_BIG", ce.Text)
+	case ce.Code == websocket.CloseAbnormalClosure:
+		return errors.ServiceUnavailable("WEBSOCKET_ABNORMAL_CLOSURE", ce.Text)
+	}
+	return errors.InternalServer("WEBSOCKET_CLOSED", fmt.Sprintf("close %d %s", ce.Code, ce.Text))
+}
+
+// ServeWebSocket 升级为websocket并在fn中处理流，fn返回后按错误发送关闭帧
+// 路由需用HandleStream注册，否则受server超时限制
+// 不是websocket握手时返回400，握手失败时返回对应状态码的错误
+func ServeWebSocket(ctx Context, fn func(stream *WebSocketStream) error) error {
+	req := ctx.Request()
+	if !websocket.IsWebSocketUpgrade(req) {
+		return errors.BadRequest("WEBSOCKET_UPGRADE", "websocket upgrade required")
+	}
+	var (
+		ping        = defaultWebSocketPing
+		checkOrigin func(r *http.Request) bool
+	)
+	if w, ok := ctx.(*wrapper); ok {
+		ping = w.router.srv.wsPing
+		checkOrigin = w.router.srv.wsCheckOrigin
+	}
+	// 按客户端给出的顺序选第一个支持的codec
+	name := "json"
+	header := http.Header{}
+	for _, p := range websocket.Subprotocols(req) {
+		if encoding.GetCodec(p) != nil {
+			name = p
+			header.Set("Sec-WebSocket-Protocol", p)
+			break
+		}
+	}
+	var upgradeErr error
+	upgrader := websocket.Upgrader{
+		CheckOrigin: checkOrigin,
+		// 错误回复交给server的错误编码
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			upgradeErr = errors.New(status, "WEBSOCKET_UPGRADE", reason.Error())
+		},
+	}
+	conn, err := upgrader.Upgrade(ctx.Response(), req, header)
+	if err != nil {
+		if upgradeErr != nil {
+			return upgradeErr
+		}
+		return err
+	}
+	stream := newWebSocketStream(req.Context(), conn, encoding.GetCodec(name), ping)
+	_ = stream.Close(fn(stream))
+	return nil
+}
+
+// doWebSocket 选择节点并完成websocket握手
+func (conn *ClientConn) doWebSocket(req *http.Request, c callInfo) (*WebSocketStream, *http.Response, error) {
+	ctx := req.Context()
+	var done func(context.Context, selector.DoneInfo)
+	u := *req.URL
+	if conn.r != nil {
+		node, d, err := conn.r.Select(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		done = d
+		u.Host = node.Address()
+	}
+	if conn.insecure {
+		u.Scheme = "ws"
+	} else {
+		u.Scheme = "wss"
+	}
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: conn.timeout,
+		TLSClientConfig:  conn.tlsConf,
+		Subprotocols:     []string{httputil.ContentSubtype(c.contentType)},
+	}
+	ws, res, err := dialer.DialContext(ctx, u.String(), req.Header)
+	if err != nil && res != nil {
+		if derr := conn.errorDecoder(ctx, res); derr != nil {
+			err = derr
+		}
+	}
+	if done != nil {
+		done(ctx, selector.DoneInfo{Err: err})
+	}
+	if err != nil {
+		return nil, res, err
+	}
+	codec := encoding.GetCodec(ws.Subprotocol())
+	if codec == nil {
+		codec = encoding.GetCodec("json")
+	}
+	return newWebSocketStream(ctx, ws, codec, conn.wsPing), res, nil
+}
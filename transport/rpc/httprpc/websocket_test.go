@@ -0,0 +1,315 @@
+package httprpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+)
+
+func newWebSocketServer(t *testing.T, opts ...Option) *httptest.Server {
+	t.Helper()
+	srv, err := NewServerConn(append([]Option{WithAddress("127.0.0.1:0")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.lis.Close() })
+	r := srv.Route("/ws")
+	// 双向流，原样返回
+	r.HandleStream(http.MethodGet, "/echo", "", func(ctx Context) error {
+		return ServeWebSocket(ctx, func(stream *WebSocketStream) error {
+			for {
+				var u User
+				if err := stream.RecvMsg(&u); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+				if err := stream.SendMsg(&u); err != nil {
+					return err
+				}
+			}
+		})
+	})
+	// 客户端流，结束后返回拼接的名字
+	r.HandleStream(http.MethodGet, "/join", "", func(ctx Context) error {
+		return ServeWebSocket(ctx, func(stream *WebSocketStream) error {
+			var names []string
+			for {
+				var u User
+				err := stream.RecvMsg(&u)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				names = append(names, u.Name)
+			}
+			return stream.SendMsg(&User{Name: strings.Join(names, ",")})
+		})
+	})
+	r.HandleStream(http.MethodGet, "/error", "", func(ctx Context) error {
+		return ServeWebSocket(ctx, func(stream *WebSocketStream) error {
+			return errors.NotFound("USER_NOT_FOUND", "user not found")
+		})
+	})
+	// 连续发送后等客户端断开
+	r.HandleStream(http.MethodGet, "/burst", "", func(ctx Context) error {
+		return ServeWebSocket(ctx, func(stream *WebSocketStream) error {
+			for _, name := range []string{"a", "b", "c"} {
+				if err := stream.SendMsg(&User{Name: name}); err != nil {
+					return err
+				}
+			}
+			<-stream.Context().Done()
+			return nil
+		})
+	})
+	// 等客户端断开
+	r.HandleStream(http.MethodGet, "/wait", "", func(ctx Context) error {
+		return ServeWebSocket(ctx, func(stream *WebSocketStream) error {
+			<-stream.Context().Done()
+			return nil
+		})
+	})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestWebSocketBidi(t *testing.T) {
+	ts := newWebSocketServer(t)
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/ws/echo", &User{Name: "first"}, WebSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := stream.SendMsg(&User{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"first", "a", "b"} {
+		var u User
+		if err := stream.RecvMsg(&u); err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != name {
+			t.Fatalf("got %q, want %q", u.Name, name)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&User{}); err != io.EOF {
+		t.Fatalf("want io.EOF, got %v", err)
+	}
+}
+
+func TestWebSocketCodec(t *testing.T) {
+	ts := newWebSocketServer(t)
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/ws/echo", nil, WebSocket(), ContentType("application/proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := stream.(*WebSocketStream)
+	if name := ws.Codec().Name(); name != "proto" {
+		t.Fatalf("negotiated codec %s", name)
+	}
+	if err := ws.Close(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketHalfClose(t *testing.T) {
+	ts := newWebSocketServer(t)
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/ws/join", nil, WebSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := stream.SendMsg(&User{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var u User
+	if err := stream.RecvMsg(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "a,b,c" {
+		t.Fatalf("got %q", u.Name)
+	}
+	if err := stream.RecvMsg(&u); err != io.EOF {
+		t.Fatalf("want io.EOF, got %v", err)
+	}
+}
+
+func TestWebSocketError(t *testing.T) {
+	ts := newWebSocketServer(t)
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/ws/error", nil, WebSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.RecvMsg(&User{})
+	if !errors.IsNotFound(err) || errors.Reason(err) != "USER_NOT_FOUND" || errors.FromError(err).Message != "user not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 不是websocket握手
+	res, err := http.Get(ts.URL + "/ws/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d", res.StatusCode)
+	}
+	// Origin校验失败
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws/echo", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "http://evil.example.com")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden || !strings.Contains(string(b), "WEBSOCKET_UPGRADE") {
+		t.Fatalf("status %d %s", res.StatusCode, b)
+	}
+	// 握手失败返回http错误
+	_, err = conn.Stream(context.Background(), http.MethodGet, "/ws/none", nil, WebSocket())
+	if !errors.IsNotFound(err) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestWebSocketCancel(t *testing.T) {
+	ts := newWebSocketServer(t, WithWebSocketPing(20*time.Millisecond))
+	conn := newTestClient(t, ts, WithWebSocketPing(20*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := conn.Stream(ctx, http.MethodGet, "/ws/wait", nil, WebSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 空闲超过ping间隔仍保持连接
+	time.Sleep(100 * time.Millisecond)
+	if err := stream.(*WebSocketStream).Context().Err(); err != nil {
+		t.Fatalf("stream closed while idle: %v", err)
+	}
+	cancel()
+	if err := stream.RecvMsg(&User{}); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestWebSocketSlowReader(t *testing.T) {
+	ts := newWebSocketServer(t, WithWebSocketPing(20*time.Millisecond))
+	conn := newTestClient(t, ts, WithWebSocketPing(20*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := conn.Stream(ctx, http.MethodGet, "/ws/burst", nil, WebSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"a", "b", "c"} {
+		var u User
+		if err := stream.RecvMsg(&u); err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != name {
+			t.Fatalf("got %q, want %q", u.Name, name)
+		}
+		// 应用处理慢于2倍ping间隔不断开
+		if i == 0 {
+			time.Sleep(150 * time.Millisecond)
+		}
+	}
+}
+
+func TestWebSocketCloseCode(t *testing.T) {
+	code, text := errorToWsClose(errors.ServiceUnavailable("BUSY", strings.Repeat("x", 200)))
+	if code != 4503 || len(text) != 123 || !strings.HasPrefix(text, "BUSY: x") {
+		t.Fatalf("code %d, text %q", code, text)
+	}
+	if code, _ := errorToWsClose(nil); code != 1000 {
+		t.Fatalf("code %d", code)
+	}
+	if code, _ := errorToWsClose(io.ErrUnexpectedEOF); code != 4500 {
+		t.Fatalf("code %d", code)
+	}
+}
+
+func TestWebSocketSendAfterCloseSend(t *testing.T) {
+	ts := newWebSocketServer(t)
+	conn := newTestClient(t, ts)
+	stream, err := conn.Stream(context.Background(), http.MethodGet, "/ws/join", nil, WebSocket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&User{Name: "a"}); errors.Reason(err) != "WEBSOCKET_SEND_CLOSED" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 半关闭不是关闭帧，连接仍然可以收到服务端的回复
+	var u User
+	if err := stream.RecvMsg(&u); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&u); err != io.EOF {
+		t.Fatalf("want io.EOF, got %v", err)
+	}
+}
+
+func TestStreamRouteTimeout(t *testing.T) {
+	srv, err := NewServerConn(WithAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.lis.Close() })
+	deadline := func(ctx Context) error {
+		_, ok := ctx.Deadline()
+		return ctx.Result(200, &User{Name: strconv.FormatBool(ok)})
+	}
+	r := srv.Route("/")
+	r.GET("/unary", deadline)
+	r.HandleStream(http.MethodGet, "/stream", "", deadline)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	// 只有HandleStream注册的路由不加超时，请求头不能绕过
+	for path, want := range map[string]string{"/unary": "true", "/stream": "false"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("Accept", EventStreamContentType)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(b), `"name":"`+want+`"`) {
+			t.Fatalf("%s: unexpected body %q", path, b)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package kafkarpc
+
+import (
+	"context"
+)
+
+// Message 发布/消费的消息
+type Message struct {
+	Topic     string
+	Key       []byte
+	Value     []byte
+	Header    map[string]string
+	Partition int32 // 仅消费时有效
+	Offset    int64 // 仅消费时有效
+
+	ack func()
+}
+
+// Ack 提交该消息的offset，autoAck时handler返回nil后自动调用
+func (m *Message) Ack() {
+	if m.ack != nil {
+		m.ack()
+	}
+}
+
+// Handler 消费消息
+type Handler func(ctx context.Context, msg *Message) error
+
+// Subscriber 订阅
+type Subscriber interface {
+	Topic() string
+	Unsubscribe() error
+}
+
+// Broker 消息队列后端
+type Broker interface {
+	Connect() error
+	Disconnect() error
+	Address() string
+	// Publish 发布，同key的消息进入同一个partition
+	Publish(ctx context.Context, msg *Message) error
+	// Subscribe 以消费组group订阅topic，同组内每个partition只会被一个订阅者消费
+	// 同一partition的消息顺序调用handler，不同partition之间并行
+	Subscribe(topic, group string, handler Handler, autoAck bool) (Subscriber, error)
+}
@@ -2,10 +2,13 @@ package kafkarpc
 
 import (
 	"context"
+	"os"
 	"strings"
 
 	"github.com/liuwangchen/toy/transport/encoding"
 	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
 )
 
 // ClientOption is HTTP client option.
@@ -37,22 +40,96 @@ func WithMiddleware(m ...middleware.Middleware) ClientOption {
 	}
 }
 
+// WithBroker 指定broker，例如测试时使用NewMemoryBroker，未指定时按endpoint连接kafka
+func WithBroker(b Broker) ClientOption {
+	return func(c *Client) {
+		c.broker = b
+	}
+}
+
 type Client struct {
-	k           *KafkaClient
-	endpoint    string
-	ctx         context.Context
-	codec       encoding.Codec
-	namespace   string
-	middlewares []middleware.Middleware
+	broker           Broker
+	isSelfCreateConn bool
+	endpoint         string
+	ctx              context.Context
+	codec            encoding.Codec
+	namespace        string
+	publisher        string
+	middlewares      []middleware.Middleware
+}
+
+// WithPublisher 发布者标识，通过header带给消费者，默认hostname
+func WithPublisher(publisher string) ClientOption {
+	return func(c *Client) {
+		c.publisher = publisher
+	}
+}
+
+type keyCtx struct{}
+
+// NewKeyContext 指定本次发布的消息key，同key的消息进入同一partition，优先于PublishKey
+func NewKeyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyCtx{}, key)
+}
+
+// KeyFromContext 获得NewKeyContext指定的消息key
+func KeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(keyCtx{}).(string)
+	return key, ok
 }
 
-func (c Client) Publish(ctx context.Context, topic string, msg interface{}) error {
+// PublishOption 发布option
+type PublishOption func(*publishOptions)
+
+type publishOptions struct {
+	key    string
+	hasKey bool
+}
+
+// PublishKey 消息key，生成代码用kafkarpc.proto的key字段选项填充
+func PublishKey(key string) PublishOption {
+	return func(o *publishOptions) {
+		o.key = key
+		o.hasKey = true
+	}
+}
+
+func (c Client) Publish(ctx context.Context, topic string, msg interface{}, opts ...PublishOption) error {
+	var o publishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if key, ok := KeyFromContext(ctx); ok {
+		o.key, o.hasKey = key, true
+	}
+	var key []byte
+	if o.hasKey {
+		key = []byte(o.key)
+	}
+	header := headerCarrier{
+		operationHeader: topic,
+		publisherHeader: c.publisher,
+	}
+	ctx = rpc.NewClientContext(ctx, &Transport{
+		endpoint:  c.broker.Address(),
+		operation: topic,
+		topic:     c.Topic(topic),
+		publisher: c.publisher,
+		key:       key,
+		reqHeader: header,
+	})
 	h := func(ctx context.Context, m interface{}) (interface{}, error) {
 		b, err := c.codec.Marshal(m)
 		if err != nil {
 			return nil, err
 		}
-		return nil, c.k.Publish(c.Topic(topic), b)
+		trace.SetTraceIdIntoHeader(header, trace.GetTraceIdFromCtx(ctx))
+		return nil, c.broker.Publish(ctx, &Message{
+			Topic:  c.Topic(topic),
+			Key:    key,
+			Value:  b,
+			Header: header,
+		})
 	}
 	if len(c.middlewares) > 0 {
 		h = middleware.Chain(c.middlewares...)(h)
@@ -69,7 +146,10 @@ func (c Client) Topic(topic string) string {
 }
 
 func (c Client) Close() error {
-	return c.k.Disconnect()
+	if !c.isSelfCreateConn {
+		return nil
+	}
+	return c.broker.Disconnect()
 }
 
 func NewClient(ctx context.Context, opts ...ClientOption) (*Client, error) {
@@ -77,11 +157,15 @@ func NewClient(ctx context.Context, opts ...ClientOption) (*Client, error) {
 		ctx:   ctx,
 		codec: encoding.GetCodec("json"),
 	}
+	c.publisher, _ = os.Hostname()
 	for _, o := range opts {
 		o(c)
 	}
-	c.k = NewKafkaClient(c.ctx, c.endpoint)
-	err := c.k.Connect()
+	if c.broker == nil {
+		c.broker = NewKafkaClient(c.ctx, c.endpoint)
+		c.isSelfCreateConn = true
+	}
+	err := c.broker.Connect()
 	if err != nil {
 		return nil, err
 	}
@@ -12,6 +12,9 @@ import (
 // SupportPackageIsVersion1 These constants should not be referenced from any other code.
 const SupportPackageIsVersion1 = true
 
+var _ Broker = (*KafkaClient)(nil)
+
+// KafkaClient sarama实现的Broker
 type KafkaClient struct {
 	ctx  context.Context
 	addr []string
@@ -140,10 +143,16 @@ func (k *KafkaClient) Disconnect() error {
 	return nil
 }
 
-func (k *KafkaClient) Publish(topic string, msg []byte) error {
+// Publish implements Broker.
+func (k *KafkaClient) Publish(ctx context.Context, msg *Message) error {
 	var produceMsg = &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(msg),
+		Topic:   msg.Topic,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: recordHeaders(msg.Header),
+	}
+	// 默认HashPartitioner，同key进入同一个partition
+	if len(msg.Key) > 0 {
+		produceMsg.Key = sarama.ByteEncoder(msg.Key)
 	}
 
 	if k.ap != nil {
@@ -180,15 +189,16 @@ func (k *KafkaClient) createSaramaClusterClient() (sarama.Client, error) {
 	return cs, nil
 }
 
-func (k *KafkaClient) Subscribe(topic string, handler func(ctx context.Context, msg []byte) error, queue string, autoAck bool) error {
+// Subscribe implements Broker.
+func (k *KafkaClient) Subscribe(topic, group string, handler Handler, autoAck bool) (Subscriber, error) {
 	// we need to create a new client per consumer
 	c, err := k.createSaramaClusterClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	cg, err := sarama.NewConsumerGroupFromClient(queue, c)
+	cg, err := sarama.NewConsumerGroupFromClient(group, c)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	h := &consumerGroupHandler{
@@ -201,7 +211,11 @@ func (k *KafkaClient) Subscribe(topic string, handler func(ctx context.Context,
 	go func() {
 		for {
 			select {
-			case err := <-cg.Errors():
+			case err, ok := <-cg.Errors():
+				if !ok {
+					// Close时关闭了错误channel
+					return
+				}
 				if err != nil {
 					l4g.Error("consumer error:", err)
 				}
@@ -218,7 +232,20 @@ func (k *KafkaClient) Subscribe(topic string, handler func(ctx context.Context,
 			}
 		}
 	}()
-	return nil
+	return &saramaSubscriber{topic: topic, cg: cg}, nil
+}
+
+type saramaSubscriber struct {
+	topic string
+	cg    sarama.ConsumerGroup
+}
+
+func (s *saramaSubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *saramaSubscriber) Unsubscribe() error {
+	return s.cg.Close()
 }
 
 func (k *KafkaClient) String() string {
@@ -229,7 +256,14 @@ func (k *KafkaClient) getBrokerConfig() *sarama.Config {
 	if c, ok := k.ctx.Value(brokerConfigKey{}).(*sarama.Config); ok {
 		return c
 	}
-	return DefaultBrokerConfig
+	// 复制一份再修改，不影响全局的默认配置
+	c := *DefaultBrokerConfig
+	brokerConfig := &c
+	// record header requires V0_11_0_0
+	if !brokerConfig.Version.IsAtLeast(sarama.V0_11_0_0) {
+		brokerConfig.Version = sarama.V0_11_0_0
+	}
+	return brokerConfig
 }
 
 func (k *KafkaClient) getAsyncProduceChan() (chan<- *sarama.ProducerError, chan<- *sarama.ProducerMessage) {
@@ -250,10 +284,11 @@ func (k *KafkaClient) getClusterConfig() *sarama.Config {
 	if c, ok := k.ctx.Value(clusterConfigKey{}).(*sarama.Config); ok {
 		return c
 	}
-	clusterConfig := DefaultClusterConfig
-	// the oldest supported version is V0_10_2_0
-	if !clusterConfig.Version.IsAtLeast(sarama.V0_10_2_0) {
-		clusterConfig.Version = sarama.V0_10_2_0
+	c := *DefaultClusterConfig
+	clusterConfig := &c
+	// the oldest supported version is V0_11_0_0, record header requires it
+	if !clusterConfig.Version.IsAtLeast(sarama.V0_11_0_0) {
+		clusterConfig.Version = sarama.V0_11_0_0
 	}
 	clusterConfig.Consumer.Return.Errors = true
 	clusterConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
@@ -263,7 +298,7 @@ func (k *KafkaClient) getClusterConfig() *sarama.Config {
 // consumerGroupHandler is the implementation of sarama.ConsumerGroupHandler
 type consumerGroupHandler struct {
 	ctx     context.Context
-	handler func(ctx context.Context, msg []byte) error
+	handler Handler
 	autoAck bool
 }
 
@@ -271,12 +306,45 @@ func (*consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { retu
 func (*consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for msg := range claim.Messages() {
-		err := h.handler(h.ctx, msg.Value)
+		m := &Message{
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Header:    headerFromRecord(msg.Headers),
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+		}
+		cm := msg
+		m.ack = func() {
+			sess.MarkMessage(cm, "")
+		}
+		err := h.handler(h.ctx, m)
 		if err == nil && h.autoAck {
-			sess.MarkMessage(msg, "")
+			m.Ack()
 		} else if err != nil {
 			l4g.Error("[kafka]: subscriber error: %v", err)
 		}
 	}
 	return nil
 }
+
+// recordHeaders 转为kafka record header
+func recordHeaders(header map[string]string) []sarama.RecordHeader {
+	headers := make([]sarama.RecordHeader, 0, len(header))
+	for k, v := range header {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+	return headers
+}
+
+// headerFromRecord 从kafka record header还原
+func headerFromRecord(headers []*sarama.RecordHeader) map[string]string {
+	header := make(map[string]string, len(headers))
+	for _, h := range headers {
+		if h == nil {
+			continue
+		}
+		header[string(h.Key)] = string(h.Value)
+	}
+	return header
+}
@@ -11,6 +11,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
 	reflect "reflect"
+	sync "sync"
 )
 
 const (
@@ -20,6 +21,78 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// RetryPolicy 消费失败的重试策略
+type RetryPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MaxAttempts  int32 `protobuf:"varint,1,opt,name=maxAttempts,proto3" json:"maxAttempts,omitempty"`   // 最大尝试次数，包含第一次
+	BackoffMs    int64 `protobuf:"varint,2,opt,name=backoffMs,proto3" json:"backoffMs,omitempty"`       // 第一次重试的间隔，之后每次翻倍
+	MaxBackoffMs int64 `protobuf:"varint,3,opt,name=maxBackoffMs,proto3" json:"maxBackoffMs,omitempty"` // 重试间隔上限
+	Dlq          bool  `protobuf:"varint,4,opt,name=dlq,proto3" json:"dlq,omitempty"`                   // 超过最大次数后投递到<topic>.dlq
+}
+
+func (x *RetryPolicy) Reset() {
+	*x = RetryPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_kafkarpc_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RetryPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryPolicy) ProtoMessage() {}
+
+func (x *RetryPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_kafkarpc_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryPolicy.ProtoReflect.Descriptor instead.
+func (*RetryPolicy) Descriptor() ([]byte, []int) {
+	return file_kafkarpc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RetryPolicy) GetMaxAttempts() int32 {
+	if x != nil {
+		return x.MaxAttempts
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetBackoffMs() int64 {
+	if x != nil {
+		return x.BackoffMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetMaxBackoffMs() int64 {
+	if x != nil {
+		return x.MaxBackoffMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetDlq() bool {
+	if x != nil {
+		return x.Dlq
+	}
+	return false
+}
+
 var file_kafkarpc_proto_extTypes = []protoimpl.ExtensionInfo{
 	{
 		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
@@ -45,6 +118,22 @@ var file_kafkarpc_proto_extTypes = []protoimpl.ExtensionInfo{
 		Tag:           "bytes,2461,opt,name=queue",
 		Filename:      "kafkarpc.proto",
 	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*RetryPolicy)(nil),
+		Field:         2462,
+		Name:          "kafka.retry",
+		Tag:           "bytes,2462,opt,name=retry",
+		Filename:      "kafkarpc.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         2463,
+		Name:          "kafka.key",
+		Tag:           "varint,2463,opt,name=key",
+		Filename:      "kafkarpc.proto",
+	},
 }
 
 // Extension fields to descriptorpb.ServiceOptions.
@@ -59,6 +148,14 @@ var (
 	E_AutoAck = &file_kafkarpc_proto_extTypes[1] // 是否自动ack
 	// optional string queue = 2461;
 	E_Queue = &file_kafkarpc_proto_extTypes[2] // 消费组
+	// optional kafka.RetryPolicy retry = 2462;
+	E_Retry = &file_kafkarpc_proto_extTypes[3] // 消费失败的重试策略
+)
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// optional bool key = 2463;
+	E_Key = &file_kafkarpc_proto_extTypes[4] // 该字段作为消息key，同key的消息进入同一partition
 )
 
 var File_kafkarpc_proto protoreflect.FileDescriptor
@@ -67,36 +164,71 @@ var file_kafkarpc_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x72, 0x70, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x12, 0x05, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f,
 	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70,
-	0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3a, 0x45, 0x0a, 0x0c, 0x64, 0x79, 0x6e,
-	0x61, 0x6d, 0x69, 0x63, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
-	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xc8, 0xd9, 0x02, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x0c, 0x64, 0x79, 0x6e, 0x61, 0x6d, 0x69, 0x63, 0x54, 0x6f, 0x70, 0x69, 0x63,
-	0x3a, 0x39, 0x0a, 0x07, 0x61, 0x75, 0x74, 0x6f, 0x41, 0x63, 0x6b, 0x12, 0x1e, 0x2e, 0x67, 0x6f,
-	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65,
-	0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x9c, 0x13, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x07, 0x61, 0x75, 0x74, 0x6f, 0x41, 0x63, 0x6b, 0x3a, 0x35, 0x0a, 0x05, 0x71,
-	0x75, 0x65, 0x75, 0x65, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74,
-	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x9d, 0x13, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x65,
-	0x75, 0x65, 0x42, 0x33, 0x5a, 0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
-	0x2f, 0x6c, 0x69, 0x75, 0x77, 0x61, 0x6e, 0x67, 0x63, 0x68, 0x65, 0x6e, 0x2f, 0x74, 0x6f, 0x79,
-	0x2f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x6b,
-	0x61, 0x66, 0x6b, 0x61, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x83, 0x01, 0x0a, 0x0b, 0x52, 0x65,
+	0x74, 0x72, 0x79, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x6d, 0x61, 0x78,
+	0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b,
+	0x6d, 0x61, 0x78, 0x41, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x62,
+	0x61, 0x63, 0x6b, 0x6f, 0x66, 0x66, 0x4d, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09,
+	0x62, 0x61, 0x63, 0x6b, 0x6f, 0x66, 0x66, 0x4d, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x6d, 0x61, 0x78,
+	0x42, 0x61, 0x63, 0x6b, 0x6f, 0x66, 0x66, 0x4d, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0c, 0x6d, 0x61, 0x78, 0x42, 0x61, 0x63, 0x6b, 0x6f, 0x66, 0x66, 0x4d, 0x73, 0x12, 0x10, 0x0a,
+	0x03, 0x64, 0x6c, 0x71, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x03, 0x64, 0x6c, 0x71, 0x3a,
+	0x45, 0x0a, 0x0c, 0x64, 0x79, 0x6e, 0x61, 0x6d, 0x69, 0x63, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x12,
+	0x1f, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0xc8, 0xd9, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x64, 0x79, 0x6e, 0x61, 0x6d, 0x69,
+	0x63, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x3a, 0x39, 0x0a, 0x07, 0x61, 0x75, 0x74, 0x6f, 0x41, 0x63,
+	0x6b, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0x9c, 0x13, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x61, 0x75, 0x74, 0x6f, 0x41, 0x63,
+	0x6b, 0x3a, 0x35, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x75, 0x65, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x9d, 0x13, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x71, 0x75, 0x65, 0x75, 0x65, 0x3a, 0x49, 0x0a, 0x05, 0x72, 0x65, 0x74, 0x72,
+	0x79, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0x9e, 0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61,
+	0x2e, 0x52, 0x65, 0x74, 0x72, 0x79, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x05, 0x72, 0x65,
+	0x74, 0x72, 0x79, 0x3a, 0x30, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65,
+	0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x9f, 0x13, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x42, 0x33, 0x5a, 0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x69, 0x75, 0x77, 0x61, 0x6e, 0x67, 0x63, 0x68, 0x65, 0x6e, 0x2f,
+	0x74, 0x6f, 0x79, 0x2f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f, 0x72, 0x70,
+	0x63, 0x2f, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
+}
+
+var (
+	file_kafkarpc_proto_rawDescOnce sync.Once
+	file_kafkarpc_proto_rawDescData = file_kafkarpc_proto_rawDesc
+)
+
+func file_kafkarpc_proto_rawDescGZIP() []byte {
+	file_kafkarpc_proto_rawDescOnce.Do(func() {
+		file_kafkarpc_proto_rawDescData = protoimpl.X.CompressGZIP(file_kafkarpc_proto_rawDescData)
+	})
+	return file_kafkarpc_proto_rawDescData
 }
 
+var file_kafkarpc_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
 var file_kafkarpc_proto_goTypes = []interface{}{
-	(*descriptorpb.ServiceOptions)(nil), // 0: google.protobuf.ServiceOptions
-	(*descriptorpb.MethodOptions)(nil),  // 1: google.protobuf.MethodOptions
+	(*RetryPolicy)(nil),                 // 0: kafka.RetryPolicy
+	(*descriptorpb.ServiceOptions)(nil), // 1: google.protobuf.ServiceOptions
+	(*descriptorpb.MethodOptions)(nil),  // 2: google.protobuf.MethodOptions
+	(*descriptorpb.FieldOptions)(nil),   // 3: google.protobuf.FieldOptions
 }
 var file_kafkarpc_proto_depIdxs = []int32{
-	0, // 0: kafka.dynamicTopic:extendee -> google.protobuf.ServiceOptions
-	1, // 1: kafka.autoAck:extendee -> google.protobuf.MethodOptions
-	1, // 2: kafka.queue:extendee -> google.protobuf.MethodOptions
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	0, // [0:3] is the sub-list for extension extendee
+	1, // 0: kafka.dynamicTopic:extendee -> google.protobuf.ServiceOptions
+	2, // 1: kafka.autoAck:extendee -> google.protobuf.MethodOptions
+	2, // 2: kafka.queue:extendee -> google.protobuf.MethodOptions
+	2, // 3: kafka.retry:extendee -> google.protobuf.MethodOptions
+	3, // 4: kafka.key:extendee -> google.protobuf.FieldOptions
+	0, // 5: kafka.retry:type_name -> kafka.RetryPolicy
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	5, // [5:6] is the sub-list for extension type_name
+	0, // [0:5] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
 }
 
@@ -105,18 +237,33 @@ func file_kafkarpc_proto_init() {
 	if File_kafkarpc_proto != nil {
 		return
 	}
+	if !protoimpl.UnsafeEnabled {
+		file_kafkarpc_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RetryPolicy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_kafkarpc_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   0,
-			NumExtensions: 3,
+			NumMessages:   1,
+			NumExtensions: 5,
 			NumServices:   0,
 		},
 		GoTypes:           file_kafkarpc_proto_goTypes,
 		DependencyIndexes: file_kafkarpc_proto_depIdxs,
+		MessageInfos:      file_kafkarpc_proto_msgTypes,
 		ExtensionInfos:    file_kafkarpc_proto_extTypes,
 	}.Build()
 	File_kafkarpc_proto = out.File
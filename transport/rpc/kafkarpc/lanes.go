@@ -0,0 +1,159 @@
+package kafkarpc
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	l4g "github.com/liuwangchen/toy/logger"
+)
+
+// laneBuffer 每个lane排队的消息数，满了之后阻塞broker的投递
+const laneBuffer = 16
+
+// WithPartitionConcurrency 每个partition内并发处理的lane数
+// 默认1：每个partition严格顺序处理，不同partition之间并行（由broker保证）
+// n>1：同一partition的消息按key分配到n个lane并发处理，同key的消息仍然有序，
+// offset只提交到连续处理成功的位置。处理失败的消息不提交，之后的offset也不再提交，
+// rebalance或重启后从失败的消息开始重新投递；需要跳过失败消息时配合WithRetryPolicy使用
+func WithPartitionConcurrency(n int) SubscribeOption {
+	return func(s *subInfo) {
+		s.concurrency = n
+	}
+}
+
+type laneTask struct {
+	ctx context.Context
+	msg *Message
+	gen uint64 // 入队时watermark的代数
+}
+
+// watermark 一个partition内已投递但未提交的消息，按offset递增
+// rebalance后重建并换一个代数，旧代数的消息完成时忽略
+type watermark struct {
+	gen      uint64
+	last     int64 // 最后入队的offset
+	failed   bool  // 有消息处理失败，之后的offset不再提交
+	failedAt int64 // 处理失败的最小offset
+	pending  []*Message
+	done     map[int64]bool
+}
+
+// lanes 按key把partition内的消息分配到多个goroutine
+type lanes struct {
+	handler Handler
+	autoAck bool
+	chs     []chan laneTask
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	gen   uint64
+	marks map[int32]*watermark
+}
+
+func newLanes(n int, handler Handler, autoAck bool) *lanes {
+	l := &lanes{
+		handler: handler,
+		autoAck: autoAck,
+		chs:     make([]chan laneTask, n),
+		stop:    make(chan struct{}),
+		marks:   map[int32]*watermark{},
+	}
+	for i := range l.chs {
+		l.chs[i] = make(chan laneTask, laneBuffer)
+		l.wg.Add(1)
+		go l.run(l.chs[i])
+	}
+	return l
+}
+
+func (l *lanes) run(ch chan laneTask) {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case t := <-ch:
+			err := l.handler(t.ctx, t.msg)
+			if err != nil {
+				l4g.Error("[kafka]: subscriber error: %v", err)
+			}
+			l.done(t.msg, t.gen, err)
+		}
+	}
+}
+
+// lane 同partition同key的消息总是落在同一个lane，没有key时按offset打散
+func (l *lanes) lane(msg *Message) int {
+	if len(msg.Key) == 0 {
+		return int(msg.Offset % int64(len(l.chs)))
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(msg.Key)
+	return int(h.Sum32() % uint32(len(l.chs)))
+}
+
+// handle 作为broker的Handler，入队后立即返回，offset由lanes负责提交
+func (l *lanes) handle(ctx context.Context, msg *Message) error {
+	l.mu.Lock()
+	wm, ok := l.marks[msg.Partition]
+	if !ok || msg.Offset <= wm.last {
+		// 首次分配或rebalance后重新投递，丢弃旧的进度
+		l.gen++
+		wm = &watermark{gen: l.gen, done: map[int64]bool{}}
+		l.marks[msg.Partition] = wm
+	}
+	wm.last = msg.Offset
+	if !wm.failed {
+		wm.pending = append(wm.pending, msg)
+	}
+	gen := wm.gen
+	l.mu.Unlock()
+
+	select {
+	case <-l.stop:
+		return ErrBrokerClosed
+	case l.chs[l.lane(msg)] <- laneTask{ctx: ctx, msg: msg, gen: gen}:
+		return nil
+	}
+}
+
+// done 标记消息处理完成，提交连续成功的最大offset
+// 处理失败后停止提交，等待重新投递；rebalance之前入队的消息不影响新的进度
+func (l *lanes) done(msg *Message, gen uint64, err error) {
+	l.mu.Lock()
+	wm, ok := l.marks[msg.Partition]
+	if !ok || wm.gen != gen || (wm.failed && msg.Offset >= wm.failedAt) {
+		l.mu.Unlock()
+		return
+	}
+	if err != nil {
+		// 失败的消息和之后的都不提交，之前的继续等待完成
+		wm.failed, wm.failedAt = true, msg.Offset
+		for i, m := range wm.pending {
+			if m.Offset >= msg.Offset {
+				wm.pending = wm.pending[:i]
+				break
+			}
+		}
+	} else {
+		wm.done[msg.Offset] = true
+	}
+	var last *Message
+	for len(wm.pending) > 0 && wm.done[wm.pending[0].Offset] {
+		last = wm.pending[0]
+		delete(wm.done, last.Offset)
+		wm.pending = wm.pending[1:]
+	}
+	l.mu.Unlock()
+	if last != nil && l.autoAck {
+		last.Ack()
+	}
+}
+
+// close 停止所有lane，排队中未处理的消息不会提交
+func (l *lanes) close() {
+	close(l.stop)
+	l.wg.Wait()
+}
@@ -0,0 +1,254 @@
+package kafkarpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+func TestPublishKey(t *testing.T) {
+	broker := NewMemoryBroker()
+	keys := make(chan string, 2)
+	if _, err := broker.Subscribe("t", "g", func(ctx context.Context, msg *Message) error {
+		keys <- string(msg.Key)
+		return nil
+	}, true); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(context.Background(), WithBroker(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Publish(context.Background(), "t", &pushNotify{}, PublishKey("field")); err != nil {
+		t.Fatal(err)
+	}
+	// context指定的key优先
+	ctx := NewKeyContext(context.Background(), "ctx")
+	if err := client.Publish(ctx, "t", &pushNotify{}, PublishKey("field")); err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{"field", "ctx"} {
+		select {
+		case key := <-keys:
+			if key != expect {
+				t.Fatalf("expect key %s, got %s", expect, key)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expect message consumed")
+		}
+	}
+}
+
+func TestPartitionsInParallel(t *testing.T) {
+	broker := NewMemoryBroker(WithPartitions(2), WithOffsetOldest())
+	// 找到落在不同partition的两个key
+	var keys [2]string
+	for i := 0; keys[1] == ""; i++ {
+		key := fmt.Sprintf("player-%d", i)
+		p := broker.partition([]byte(key))
+		if keys[p] == "" {
+			keys[p] = key
+		}
+	}
+	block := make(chan struct{})
+	got := make(chan string, 1)
+	if _, err := broker.Subscribe("t", "g", func(ctx context.Context, msg *Message) error {
+		if string(msg.Key) == keys[0] {
+			<-block
+			return nil
+		}
+		got <- string(msg.Key)
+		return nil
+	}, true); err != nil {
+		t.Fatal(err)
+	}
+	defer close(block)
+	for _, key := range keys {
+		if err := broker.Publish(context.Background(), &Message{Topic: "t", Key: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case key := <-got:
+		if key != keys[1] {
+			t.Fatalf("unexpected key %s", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("partition blocked by another partition")
+	}
+}
+
+func TestPartitionConcurrency(t *testing.T) {
+	broker := NewMemoryBroker(WithPartitions(2), WithOffsetOldest())
+	srv, err := NewServer(Backend(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu       sync.Mutex
+		received = map[string][]int{}
+		count    int
+	)
+	srv.Subscribe("Pusher.Push", func(ctx context.Context, b []byte) error {
+		msg := new(pushNotify)
+		if err := srv.GetCodec().Unmarshal(b, msg); err != nil {
+			return err
+		}
+		tr, _ := rpc.FromServerContext(ctx)
+		var seq int
+		fmt.Sscan(msg.Name, &seq)
+		time.Sleep(time.Duration(seq%3) * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		key := string(tr.(*Transport).Key())
+		received[key] = append(received[key], seq)
+		count++
+		return nil
+	}, true, "g", WithPartitionConcurrency(4))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = srv.Start(ctx)
+	}()
+	waitFor(t, srv.Ready)
+
+	client, err := NewClient(context.Background(), WithBroker(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 100
+	for i := 0; i < total; i++ {
+		ctx := NewKeyContext(context.Background(), fmt.Sprintf("player-%d", i%7))
+		if err := client.Publish(ctx, "Pusher.Push", &pushNotify{Name: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return count == total
+	})
+	for key, seqs := range received {
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] <= seqs[i-1] {
+				t.Fatalf("key %s out of order: %v", key, seqs)
+			}
+		}
+	}
+	// 全部处理完成后offset提交到末尾
+	waitFor(t, func() bool {
+		return broker.Committed("Pusher.Push", "g", 0)+broker.Committed("Pusher.Push", "g", 1) == total
+	})
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLanesStaleDone(t *testing.T) {
+	l := &lanes{
+		autoAck: true,
+		chs:     []chan laneTask{make(chan laneTask, 8)},
+		stop:    make(chan struct{}),
+		marks:   map[int32]*watermark{},
+	}
+	var acked []int64
+	newMsg := func(offset int64) *Message {
+		return &Message{Offset: offset, ack: func() { acked = append(acked, offset) }}
+	}
+	for _, offset := range []int64{5, 6, 5} {
+		// 第二个5是rebalance后重新投递
+		if err := l.handle(context.Background(), newMsg(offset)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tasks []laneTask
+	for i := 0; i < 3; i++ {
+		tasks = append(tasks, <-l.chs[0])
+	}
+	// rebalance之前的消息完成不影响新的进度
+	l.done(tasks[1].msg, tasks[1].gen, nil)
+	l.done(tasks[0].msg, tasks[0].gen, nil)
+	if len(acked) != 0 {
+		t.Fatalf("expect stale done ignored, acked %v", acked)
+	}
+	l.done(tasks[2].msg, tasks[2].gen, nil)
+	if len(acked) != 1 || acked[0] != 5 {
+		t.Fatalf("expect offset 5 acked, got %v", acked)
+	}
+}
+
+// dlqFailBroker 死信topic投递失败
+type dlqFailBroker struct {
+	*MemoryBroker
+}
+
+func (b dlqFailBroker) Publish(ctx context.Context, msg *Message) error {
+	if strings.HasSuffix(msg.Topic, DLQSuffix) {
+		return errors.New("dlq unavailable")
+	}
+	return b.MemoryBroker.Publish(ctx, msg)
+}
+
+func TestLanesDLQFailure(t *testing.T) {
+	broker := NewMemoryBroker(WithOffsetOldest())
+	srv, err := NewServer(Backend(dlqFailBroker{broker}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		count int
+	)
+	srv.Subscribe("Pusher.Push", func(ctx context.Context, b []byte) error {
+		msg := new(pushNotify)
+		if err := srv.GetCodec().Unmarshal(b, msg); err != nil {
+			return err
+		}
+		mu.Lock()
+		count++
+		mu.Unlock()
+		if msg.Name == "1" {
+			return errors.New("fail")
+		}
+		return nil
+	}, true, "g", WithPartitionConcurrency(2), WithRetryPolicy(&RetryPolicy{MaxAttempts: 1, Dlq: true}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = srv.Start(ctx)
+	}()
+	waitFor(t, srv.Ready)
+
+	client, err := NewClient(context.Background(), WithBroker(broker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 4
+	for i := 0; i < total; i++ {
+		if err := client.Publish(context.Background(), "Pusher.Push", &pushNotify{Name: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return count == total
+	})
+	// 死信投递失败的消息和之后的offset都不提交，等待重新投递
+	waitFor(t, func() bool {
+		return broker.Committed("Pusher.Push", "g", 0) == 1
+	})
+	time.Sleep(50 * time.Millisecond)
+	if committed := broker.Committed("Pusher.Push", "g", 0); committed != 1 {
+		t.Fatalf("expect offset committed before failed message, got %d", committed)
+	}
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
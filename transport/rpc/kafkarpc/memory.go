@@ -0,0 +1,285 @@
+package kafkarpc
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+var _ Broker = (*MemoryBroker)(nil)
+
+// ErrBrokerClosed broker已关闭
+var ErrBrokerClosed = errors.New("kafkarpc: broker closed")
+
+// MemoryOption 内存broker option
+type MemoryOption func(*MemoryBroker)
+
+// WithPartitions 每个topic的partition数量，默认1
+func WithPartitions(n int) MemoryOption {
+	return func(b *MemoryBroker) {
+		if n > 0 {
+			b.partitions = n
+		}
+	}
+}
+
+// WithOffsetOldest 新消费组从最早的消息开始消费，默认只消费订阅之后的消息
+func WithOffsetOldest() MemoryOption {
+	return func(b *MemoryBroker) {
+		b.oldest = true
+	}
+}
+
+// MemoryBroker 进程内的broker，用于测试
+// 支持消费组、按key分区和offset提交：同组内partition按订阅者均分，
+// 组内订阅者变化时，换了订阅者的partition从已提交的offset重新投递
+type MemoryBroker struct {
+	partitions int
+	oldest     bool
+	rr         uint32
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	topics map[string]*memTopic
+	closed bool
+}
+
+type memTopic struct {
+	partitions [][]*Message
+	groups     map[string]*memGroup
+}
+
+type memGroup struct {
+	members   []*memSubscriber
+	committed []int64          // 每个partition已提交的offset(下一条待消费)
+	next      []int64          // 每个partition下一条投递的offset
+	owners    []*memSubscriber // 每个partition分配的订阅者
+	gens      []int            // 每个partition的分配代数，变化后旧的投递goroutine退出
+	done      []chan struct{}  // 每个partition当前投递goroutine的退出通知
+}
+
+type memSubscriber struct {
+	b       *MemoryBroker
+	topic   string
+	group   *memGroup
+	handler Handler
+	autoAck bool
+}
+
+// NewMemoryBroker new a memory broker.
+func NewMemoryBroker(opts ...MemoryOption) *MemoryBroker {
+	b := &MemoryBroker{
+		partitions: 1,
+		topics:     make(map[string]*memTopic),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Connect implements Broker.
+func (b *MemoryBroker) Connect() error {
+	return nil
+}
+
+// Disconnect implements Broker, 停止所有投递
+func (b *MemoryBroker) Disconnect() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+	return nil
+}
+
+// Address implements Broker.
+func (b *MemoryBroker) Address() string {
+	return "memory"
+}
+
+// topic 懒加载topic，持锁调用
+func (b *MemoryBroker) topic(name string) *memTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memTopic{
+			partitions: make([][]*Message, b.partitions),
+			groups:     make(map[string]*memGroup),
+		}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// partition 有key时按hash，否则轮询
+func (b *MemoryBroker) partition(key []byte) int {
+	if len(key) == 0 {
+		return int(atomic.AddUint32(&b.rr, 1)-1) % b.partitions
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % uint32(b.partitions))
+}
+
+// Publish implements Broker.
+func (b *MemoryBroker) Publish(ctx context.Context, msg *Message) error {
+	p := b.partition(msg.Key)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	t := b.topic(msg.Topic)
+	header := make(map[string]string, len(msg.Header))
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+	t.partitions[p] = append(t.partitions[p], &Message{
+		Topic:     msg.Topic,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Header:    header,
+		Partition: int32(p),
+		Offset:    int64(len(t.partitions[p])),
+	})
+	b.mu.Unlock()
+	b.cond.Broadcast()
+	return nil
+}
+
+// Subscribe implements Broker.
+func (b *MemoryBroker) Subscribe(topic, group string, handler Handler, autoAck bool) (Subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	t := b.topic(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = &memGroup{
+			committed: make([]int64, b.partitions),
+			next:      make([]int64, b.partitions),
+			owners:    make([]*memSubscriber, b.partitions),
+			gens:      make([]int, b.partitions),
+			done:      make([]chan struct{}, b.partitions),
+		}
+		if !b.oldest {
+			for p := range t.partitions {
+				g.committed[p] = int64(len(t.partitions[p]))
+			}
+		}
+		t.groups[group] = g
+	}
+	sub := &memSubscriber{b: b, topic: topic, group: g, handler: handler, autoAck: autoAck}
+	g.members = append(g.members, sub)
+	b.rebalance(t, g)
+	b.cond.Broadcast()
+	return sub, nil
+}
+
+// rebalance 重新分配partition，持锁调用
+// 换了订阅者的partition停掉原来的投递，从已提交的offset重新投递，其他partition不受影响
+func (b *MemoryBroker) rebalance(t *memTopic, g *memGroup) {
+	for p := range g.owners {
+		var owner *memSubscriber
+		if len(g.members) > 0 {
+			owner = g.members[p%len(g.members)]
+		}
+		if owner == g.owners[p] {
+			continue
+		}
+		g.owners[p] = owner
+		g.gens[p]++
+		g.next[p] = g.committed[p]
+		if owner == nil {
+			continue
+		}
+		done := make(chan struct{})
+		go b.dispatch(t, g, p, g.gens[p], g.done[p], done)
+		g.done[p] = done
+	}
+}
+
+// dispatch 顺序投递一个partition的消息，分配代数变化后退出
+// 等上一个投递goroutine退出后才开始，同一partition不会同时投递给两个订阅者
+func (b *MemoryBroker) dispatch(t *memTopic, g *memGroup, p, gen int, prev <-chan struct{}, done chan struct{}) {
+	defer close(done)
+	if prev != nil {
+		<-prev
+		// 上一个订阅者处理中的消息可能已经提交
+		b.mu.Lock()
+		if g.gens[p] == gen {
+			g.next[p] = g.committed[p]
+		}
+		b.mu.Unlock()
+	}
+	for {
+		b.mu.Lock()
+		for !b.closed && g.gens[p] == gen && g.next[p] >= int64(len(t.partitions[p])) {
+			b.cond.Wait()
+		}
+		if b.closed || g.gens[p] != gen {
+			b.mu.Unlock()
+			return
+		}
+		stored := t.partitions[p][g.next[p]]
+		member := g.owners[p]
+		g.next[p]++
+		b.mu.Unlock()
+
+		msg := *stored
+		msg.ack = func() {
+			b.commit(g, p, stored.Offset+1)
+		}
+		if err := member.handler(context.Background(), &msg); err == nil && member.autoAck {
+			msg.Ack()
+		}
+	}
+}
+
+func (b *MemoryBroker) commit(g *memGroup, p int, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if offset > g.committed[p] {
+		g.committed[p] = offset
+	}
+}
+
+// Committed 消费组在partition上已提交的offset
+func (b *MemoryBroker) Committed(topic, group string, partition int32) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.topics[topic]
+	if !ok {
+		return 0
+	}
+	g, ok := t.groups[group]
+	if !ok || int(partition) >= len(g.committed) {
+		return 0
+	}
+	return g.committed[partition]
+}
+
+// Topic implements Subscriber.
+func (s *memSubscriber) Topic() string {
+	return s.topic
+}
+
+// Unsubscribe implements Subscriber, 剩余订阅者从已提交的offset继续消费
+func (s *memSubscriber) Unsubscribe() error {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	g := s.group
+	for i, m := range g.members {
+		if m == s {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			s.b.rebalance(s.b.topics[s.topic], g)
+			s.b.cond.Broadcast()
+			return nil
+		}
+	}
+	return nil
+}
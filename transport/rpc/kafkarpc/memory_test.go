@@ -0,0 +1,242 @@
+package kafkarpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMemoryBrokerPartitionByKey(t *testing.T) {
+	b := NewMemoryBroker(WithPartitions(4), WithOffsetOldest())
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("player-%d", i%3)
+		if err := b.Publish(context.Background(), &Message{Topic: "t", Key: []byte(key), Value: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var (
+		mu         sync.Mutex
+		partitions = map[string]int32{}
+		count      int
+	)
+	_, err := b.Subscribe("t", "g", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if p, ok := partitions[string(msg.Key)]; ok && p != msg.Partition {
+			t.Errorf("key %s in partitions %d and %d", msg.Key, p, msg.Partition)
+		}
+		partitions[string(msg.Key)] = msg.Partition
+		count++
+		return nil
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return count == 20
+	})
+}
+
+func TestMemoryBrokerConsumerGroup(t *testing.T) {
+	b := NewMemoryBroker(WithPartitions(2))
+	var (
+		mu       sync.Mutex
+		received = map[string]int{}
+	)
+	handler := func(name string) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			received[name]++
+			mu.Unlock()
+			return nil
+		}
+	}
+	// 同组两个订阅者分摊partition，另一个组收到全部消息
+	if _, err := b.Subscribe("t", "g1", handler("a"), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Subscribe("t", "g1", handler("b"), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Subscribe("t", "g2", handler("c"), true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		_ = b.Publish(context.Background(), &Message{Topic: "t", Value: []byte{byte(i)}})
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return received["a"]+received["b"] == 10 && received["c"] == 10
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if received["a"] != 5 || received["b"] != 5 {
+		t.Fatalf("expect partitions split between members, got %v", received)
+	}
+	if b.Committed("t", "g1", 0)+b.Committed("t", "g1", 1) != 10 {
+		t.Fatal("expect all offsets committed")
+	}
+}
+
+func TestMemoryBrokerRedeliverUncommitted(t *testing.T) {
+	b := NewMemoryBroker(WithOffsetOldest())
+	_ = b.Publish(context.Background(), &Message{Topic: "t", Value: []byte("1")})
+	_ = b.Publish(context.Background(), &Message{Topic: "t", Value: []byte("2")})
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	// 只ack第一条
+	sub, err := b.Subscribe("t", "g", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		seen = append(seen, string(msg.Value))
+		mu.Unlock()
+		if string(msg.Value) == "1" {
+			msg.Ack()
+		}
+		return nil
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen) == 2
+	})
+	if b.Committed("t", "g", 0) != 1 {
+		t.Fatalf("expect committed 1, got %d", b.Committed("t", "g", 0))
+	}
+	_ = sub.Unsubscribe()
+
+	// 重新加入消费组，从已提交的offset继续
+	redelivered := make(chan string, 2)
+	if _, err := b.Subscribe("t", "g", func(ctx context.Context, msg *Message) error {
+		redelivered <- string(msg.Value)
+		return nil
+	}, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-redelivered:
+		if v != "2" {
+			t.Fatalf("expect uncommitted message 2 redelivered, got %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect redelivery")
+	}
+}
+
+func TestMemoryBrokerRebalanceKeepsAssigned(t *testing.T) {
+	b := NewMemoryBroker(WithPartitions(2), WithOffsetOldest())
+	for i := 0; i < 4; i++ {
+		_ = b.Publish(context.Background(), &Message{Topic: "t", Value: []byte{byte(i)}})
+	}
+	var (
+		mu       sync.Mutex
+		received = map[string][]int32{}
+	)
+	// 都不ack
+	handler := func(name string) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			received[name] = append(received[name], msg.Partition)
+			mu.Unlock()
+			return nil
+		}
+	}
+	if _, err := b.Subscribe("t", "g", handler("a"), false); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received["a"]) == 4
+	})
+	// b分到partition 1，未提交的消息重新投递给b，a的partition 0不重新投递
+	if _, err := b.Subscribe("t", "g", handler("b"), false); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received["b"]) == 2
+	})
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["a"]) != 4 {
+		t.Fatalf("expect partition 0 not redelivered, got %v", received["a"])
+	}
+	for _, p := range received["b"] {
+		if p != 1 {
+			t.Fatalf("expect b only on partition 1, got %v", received["b"])
+		}
+	}
+}
+
+type pushNotify struct {
+	Name string `json:"name"`
+}
+
+func TestServerClient(t *testing.T) {
+	broker := NewMemoryBroker()
+	srv, err := NewServer(Backend(broker), Namespace("ns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan string, 1)
+	srv.Subscribe("Pusher.Push", func(ctx context.Context, b []byte) error {
+		msg := new(pushNotify)
+		if err := srv.GetCodec().Unmarshal(b, msg); err != nil {
+			return err
+		}
+		tr, _ := rpc.FromServerContext(ctx)
+		got <- tr.Operation() + ":" + msg.Name
+		return nil
+	}, true, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = srv.Start(ctx)
+	}()
+	waitFor(t, srv.Ready)
+
+	client, err := NewClient(context.Background(), WithBroker(broker), WithNamespace("ns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Publish(context.Background(), "Pusher.Push", &pushNotify{Name: "toy"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-got:
+		if v != "Pusher.Push:toy" {
+			t.Fatalf("unexpected %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect message consumed")
+	}
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package kafkarpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/liuwangchen/toy/logger"
+	"github.com/liuwangchen/toy/transport/errors"
+)
+
+const (
+	// DLQSuffix 死信topic后缀
+	DLQSuffix = ".dlq"
+
+	// 死信消息额外携带的header
+	dlqErrorHeader     = "kafka-dlq-error"
+	dlqCodeHeader      = "kafka-dlq-code"
+	dlqReasonHeader    = "kafka-dlq-reason"
+	dlqAttemptsHeader  = "kafka-dlq-attempts"
+	dlqTopicHeader     = "kafka-dlq-topic"
+	dlqPartitionHeader = "kafka-dlq-partition"
+	dlqOffsetHeader    = "kafka-dlq-offset"
+)
+
+// SubscribeOption 订阅option
+type SubscribeOption func(*subInfo)
+
+// WithRetryPolicy 消费失败的重试策略，可由kafkarpc.proto的retry选项生成
+func WithRetryPolicy(policy *RetryPolicy) SubscribeOption {
+	return func(s *subInfo) {
+		s.retry = policy
+	}
+}
+
+// backoff 第attempt次失败后的等待时间
+func backoff(policy *RetryPolicy, attempt int) time.Duration {
+	d := time.Duration(policy.GetBackoffMs()) * time.Millisecond
+	max := time.Duration(policy.GetMaxBackoffMs()) * time.Millisecond
+	for i := 1; i < attempt && d > 0; i++ {
+		d *= 2
+		if max > 0 && d >= max {
+			break
+		}
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
+
+// retry 按策略重试handle，超过最大次数后投递死信或跳过
+// 返回nil表示消息可以被标记
+func (s *Server) retry(ctx context.Context, sub *subInfo, msg *Message) error {
+	var (
+		err     error
+		attempt int
+	)
+	for attempt = 1; ; attempt++ {
+		if err = sub.handle(ctx, msg.Value); err == nil {
+			return nil
+		}
+		if attempt >= int(sub.retry.GetMaxAttempts()) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(sub.retry, attempt)):
+		}
+	}
+	if !sub.retry.GetDlq() {
+		logger.Error("[Kafka] drop message after %d attempts, topic=%s partition=%d offset=%d err=%v", attempt, msg.Topic, msg.Partition, msg.Offset, err)
+		return nil
+	}
+	header := make(headerCarrier, len(msg.Header)+7)
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+	header.Set(dlqErrorHeader, err.Error())
+	header.Set(dlqCodeHeader, strconv.Itoa(errors.Code(err)))
+	header.Set(dlqReasonHeader, errors.Reason(err))
+	header.Set(dlqAttemptsHeader, strconv.Itoa(attempt))
+	header.Set(dlqTopicHeader, msg.Topic)
+	header.Set(dlqPartitionHeader, strconv.Itoa(int(msg.Partition)))
+	header.Set(dlqOffsetHeader, strconv.FormatInt(msg.Offset, 10))
+	dlq := &Message{
+		Topic:  msg.Topic + DLQSuffix,
+		Key:    msg.Key,
+		Value:  msg.Value,
+		Header: header,
+	}
+	if perr := s.broker.Publish(ctx, dlq); perr != nil {
+		// 死信投递失败，不标记，等待重新投递
+		return perr
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package kafkarpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := &RetryPolicy{BackoffMs: 100, MaxBackoffMs: 350}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 350 * time.Millisecond,
+		9: 350 * time.Millisecond,
+	} {
+		if got := backoff(policy, attempt); got != want {
+			t.Errorf("attempt %d expect %s, got %s", attempt, want, got)
+		}
+	}
+	if got := backoff(&RetryPolicy{}, 3); got != 0 {
+		t.Errorf("expect no backoff, got %s", got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	broker := NewMemoryBroker(WithOffsetOldest())
+	s := &Server{broker: broker}
+	msg := &Message{Topic: "Pusher.Push", Key: []byte("k"), Value: []byte("{}"), Header: map[string]string{"x-md-global-uid": "1"}}
+
+	var calls int
+	sub := &subInfo{handle: func(ctx context.Context, b []byte) error {
+		calls++
+		if calls < 3 {
+			return errors.New("fail")
+		}
+		return nil
+	}}
+	WithRetryPolicy(&RetryPolicy{MaxAttempts: 3, BackoffMs: 1})(sub)
+	if err := s.retry(context.Background(), sub, msg); err != nil {
+		t.Fatalf("expect success on third attempt, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expect 3 calls, got %d", calls)
+	}
+
+	// 超过最大次数且不投递死信，跳过该消息
+	calls = 0
+	sub.handle = func(ctx context.Context, b []byte) error {
+		calls++
+		return errors.New("fail")
+	}
+	if err := s.retry(context.Background(), sub, msg); err != nil {
+		t.Fatalf("expect message dropped, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expect 3 calls, got %d", calls)
+	}
+
+	// 投递死信
+	sub.retry.Dlq = true
+	if err := s.retry(context.Background(), sub, msg); err != nil {
+		t.Fatalf("expect dlq published, got %v", err)
+	}
+	dlq := make(chan *Message, 1)
+	_, err := broker.Subscribe("Pusher.Push"+DLQSuffix, "dlq", func(ctx context.Context, m *Message) error {
+		dlq <- m
+		return nil
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-dlq:
+		if m.Header[dlqErrorHeader] != "fail" || m.Header[dlqAttemptsHeader] != "3" || m.Header[dlqTopicHeader] != "Pusher.Push" || m.Header["x-md-global-uid"] != "1" {
+			t.Fatalf("unexpected dlq header %v", m.Header)
+		}
+		if string(m.Key) != "k" {
+			t.Fatalf("expect key kept, got %s", m.Key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect dlq message")
+	}
+
+	// 死信投递失败时不标记消息
+	_ = broker.Disconnect()
+	if err := s.retry(context.Background(), sub, msg); err == nil {
+		t.Fatal("expect dlq publish error")
+	}
+}
@@ -3,11 +3,14 @@ package kafkarpc
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/liuwangchen/toy/logger"
 	"github.com/liuwangchen/toy/transport/encoding"
 	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
 )
 
 type ServerOption func(*Server)
@@ -40,21 +43,36 @@ func Namespace(namespace string) ServerOption {
 	}
 }
 
+// Backend 指定broker，例如测试时使用NewMemoryBroker，未指定时按Address连接kafka
+func Backend(b Broker) ServerOption {
+	return func(s *Server) {
+		s.broker = b
+	}
+}
+
 type Server struct {
-	k           *KafkaClient
-	address     string
-	middlewares []middleware.Middleware // 中间件
-	codec       encoding.Codec
-	namespace   string
-	subs        map[string]*subInfo
-	ready       bool
+	broker           Broker
+	isSelfCreateConn bool
+	mu               sync.Mutex
+	subscribers      []Subscriber
+	address          string
+	middlewares      []middleware.Middleware // 中间件
+	codec            encoding.Codec
+	namespace        string
+	subs             map[string]*subInfo
+	ready            bool
 }
 
 type subInfo struct {
-	topic   string
-	handle  func(ctx context.Context, b []byte) error
-	autoAck bool
-	queue   string
+	topic     string // 带namespace的topic
+	operation string
+	handle    func(ctx context.Context, b []byte) error
+	autoAck   bool
+	queue     string
+	retry     *RetryPolicy
+
+	concurrency int
+	lanes       *lanes
 }
 
 func NewServer(opts ...ServerOption) (*Server, error) {
@@ -65,12 +83,14 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	for _, opt := range opts {
 		opt(s)
 	}
-	k := NewKafkaClient(context.Background(), s.address)
-	err := k.Connect()
+	if s.broker == nil {
+		s.broker = NewKafkaClient(context.Background(), s.address)
+		s.isSelfCreateConn = true
+	}
+	err := s.broker.Connect()
 	if err != nil {
 		return nil, err
 	}
-	s.k = k
 	return s, nil
 }
 
@@ -83,17 +103,22 @@ func (s *Server) GetCodec() encoding.Codec {
 }
 
 // Subscribe 订阅
-func (s *Server) Subscribe(topic string, handler func(ctx context.Context, b []byte) error, autoAck bool, queue string) {
+func (s *Server) Subscribe(topic string, handler func(ctx context.Context, b []byte) error, autoAck bool, queue string, opts ...SubscribeOption) {
 	newTopic := s.Topic(topic)
 	if len(queue) == 0 {
 		queue = uuid.New().String()
 	}
-	s.subs[newTopic] = &subInfo{
-		topic:   topic,
-		handle:  handler,
-		autoAck: autoAck,
-		queue:   queue,
+	sub := &subInfo{
+		topic:     newTopic,
+		operation: topic,
+		handle:    handler,
+		autoAck:   autoAck,
+		queue:     queue,
 	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	s.subs[newTopic] = sub
 }
 
 func (s *Server) Topic(topic string) string {
@@ -105,25 +130,85 @@ func (s *Server) Topic(topic string) string {
 
 func (s *Server) Start(ctx context.Context) error {
 	for _, sub := range s.subs {
-		err := s.k.Subscribe(sub.topic, sub.handle, sub.queue, sub.autoAck)
+		handler, autoAck := s.handler(sub), sub.autoAck
+		if sub.concurrency > 1 {
+			sub.lanes = newLanes(sub.concurrency, handler, sub.autoAck)
+			handler, autoAck = sub.lanes.handle, false
+		}
+		subscriber, err := s.broker.Subscribe(sub.topic, sub.queue, handler, autoAck)
 		if err != nil {
 			return err
 		}
+		s.mu.Lock()
+		s.subscribers = append(s.subscribers, subscriber)
+		s.mu.Unlock()
 	}
+	s.mu.Lock()
 	s.ready = true
-	logger.Info("[Kafka] server listening on: %s", s.address)
+	s.mu.Unlock()
+	logger.Info("[Kafka] server listening on: %s", s.broker.Address())
 	<-ctx.Done()
 	return nil
 }
 
+// handler 从record header还原server context
+func (s *Server) handler(sub *subInfo) Handler {
+	return func(ctx context.Context, msg *Message) error {
+		header := headerCarrier(msg.Header)
+		if header == nil {
+			header = headerCarrier{}
+		}
+		operation := header.Get(operationHeader)
+		if len(operation) == 0 {
+			operation = sub.operation
+		}
+		ctx = trace.ContextWithTraceId(ctx, trace.GetTraceIdFromHeader(header))
+		ctx = rpc.NewServerContext(ctx, &Transport{
+			endpoint:    s.broker.Address(),
+			operation:   operation,
+			topic:       msg.Topic,
+			publisher:   header.Get(publisherHeader),
+			partition:   msg.Partition,
+			offset:      msg.Offset,
+			key:         msg.Key,
+			reqHeader:   header,
+			replyHeader: headerCarrier{},
+		})
+		if sub.retry == nil {
+			return sub.handle(ctx, msg.Value)
+		}
+		return s.retry(ctx, sub, msg)
+	}
+}
+
 func (s *Server) Stop(ctx context.Context) error {
-	if s.k == nil {
+	if s.broker == nil {
 		return nil
 	}
 	logger.Info("[Kafka] server stopping")
-	return s.k.Disconnect()
+	s.mu.Lock()
+	subscribers := s.subscribers
+	s.subscribers = nil
+	s.mu.Unlock()
+	for _, subscriber := range subscribers {
+		if err := subscriber.Unsubscribe(); err != nil {
+			logger.Error("[Kafka] unsubscribe %s error: %v", subscriber.Topic(), err)
+		}
+	}
+	for _, sub := range s.subs {
+		if sub.lanes != nil {
+			sub.lanes.close()
+			sub.lanes = nil
+		}
+	}
+	if !s.isSelfCreateConn {
+		return nil
+	}
+	return s.broker.Disconnect()
 }
 
 func (s *Server) Ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.ready
 }
@@ -0,0 +1,100 @@
+package kafkarpc
+
+import (
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+const (
+	// operationHeader 发布时的topic(不含namespace)
+	operationHeader = "kafka-operation"
+	// publisherHeader 发布者标识
+	publisherHeader = "kafka-publisher"
+)
+
+var _ rpc.Transporter = &Transport{}
+
+// Transport is a kafka transport.
+type Transport struct {
+	endpoint    string
+	operation   string
+	topic       string
+	publisher   string
+	partition   int32
+	offset      int64
+	key         []byte
+	reqHeader   headerCarrier
+	replyHeader headerCarrier
+}
+
+// Kind returns the transport kind.
+func (tr *Transport) Kind() rpc.Kind {
+	return rpc.KindKafka
+}
+
+// Endpoint returns the transport endpoint.
+func (tr *Transport) Endpoint() string {
+	return tr.endpoint
+}
+
+// Operation returns the transport operation.
+// 发布时的topic，不含namespace
+func (tr *Transport) Operation() string {
+	return tr.operation
+}
+
+// Topic returns the kafka topic.
+func (tr *Transport) Topic() string {
+	return tr.topic
+}
+
+// Publisher returns the publisher identity, only valid for server transport.
+func (tr *Transport) Publisher() string {
+	return tr.publisher
+}
+
+// Partition returns the message partition, only valid for server transport.
+func (tr *Transport) Partition() int32 {
+	return tr.partition
+}
+
+// Offset returns the message offset, only valid for server transport.
+func (tr *Transport) Offset() int64 {
+	return tr.offset
+}
+
+// Key returns the message key.
+func (tr *Transport) Key() []byte {
+	return tr.key
+}
+
+// RequestHeader returns the request header.
+func (tr *Transport) RequestHeader() rpc.Header {
+	return tr.reqHeader
+}
+
+// ReplyHeader returns the reply header.
+// kafka没有回包，仅为满足rpc.Transporter
+func (tr *Transport) ReplyHeader() rpc.Header {
+	return tr.replyHeader
+}
+
+type headerCarrier map[string]string
+
+// Get returns the value associated with the passed key.
+func (hc headerCarrier) Get(key string) string {
+	return hc[key]
+}
+
+// Set stores the key-value pair.
+func (hc headerCarrier) Set(key string, value string) {
+	hc[key] = value
+}
+
+// Keys lists the keys stored in this carrier.
+func (hc headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(hc))
+	for k := range hc {
+		keys = append(keys, k)
+	}
+	return keys
+}
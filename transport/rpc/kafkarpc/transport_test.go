@@ -0,0 +1,83 @@
+package kafkarpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/liuwangchen/toy/transport/metadata"
+	mmd "github.com/liuwangchen/toy/transport/middleware/metadata"
+	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+func TestServerContextFromHeaders(t *testing.T) {
+	header := headerCarrier{
+		operationHeader:   "Pusher.Push",
+		publisherHeader:   "host-1",
+		"x-md-global-uid": "10086",
+	}
+	trace.SetTraceIdIntoHeader(header, "abcdefg")
+	msg := &Message{
+		Topic:     "ns.Pusher.Push",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("player-1"),
+		Value:     []byte("{}"),
+		Header:    header,
+	}
+
+	s := &Server{broker: NewMemoryBroker()}
+	var called bool
+	sub := &subInfo{
+		topic:     "ns.Pusher.Push",
+		operation: "Pusher.Push",
+		handle: func(ctx context.Context, b []byte) error {
+			called = true
+			h := mmd.Server()(func(ctx context.Context, req interface{}) (interface{}, error) {
+				md, _ := metadata.FromServerContext(ctx)
+				if md.Get("x-md-global-uid") != "10086" {
+					t.Errorf("expect metadata propagated, got %v", md)
+				}
+				return nil, nil
+			})
+			_, _ = h(ctx, nil)
+
+			tr, ok := rpc.FromServerContext(ctx)
+			if !ok {
+				t.Fatal("expect server transport")
+			}
+			if tr.Kind() != rpc.KindKafka || tr.Operation() != "Pusher.Push" {
+				t.Errorf("unexpected transport %s %s", tr.Kind(), tr.Operation())
+			}
+			ktr := tr.(*Transport)
+			if ktr.Publisher() != "host-1" || ktr.Topic() != "ns.Pusher.Push" || ktr.Partition() != 3 || ktr.Offset() != 42 || string(ktr.Key()) != "player-1" {
+				t.Errorf("unexpected kafka transport %+v", ktr)
+			}
+			if trace.GetTraceIdFromCtx(ctx) != "abcdefg" {
+				t.Errorf("expect trace id, got %s", trace.GetTraceIdFromCtx(ctx))
+			}
+			return nil
+		},
+	}
+	if err := s.handler(sub)(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Fatal("expect handle called")
+	}
+}
+
+func TestConfigNotShared(t *testing.T) {
+	version := DefaultClusterConfig.Version
+	k := NewKafkaClient(context.Background())
+	if c := k.getClusterConfig(); c == DefaultClusterConfig || !c.Version.IsAtLeast(sarama.V0_11_0_0) {
+		t.Fatalf("unexpected cluster config version %v", c.Version)
+	}
+	if c := k.getBrokerConfig(); c == DefaultBrokerConfig || !c.Version.IsAtLeast(sarama.V0_11_0_0) {
+		t.Fatalf("unexpected broker config version %v", c.Version)
+	}
+	if DefaultClusterConfig.Version != version || DefaultClusterConfig.Consumer.Return.Errors {
+		t.Fatal("expect default config unchanged")
+	}
+}
@@ -2,14 +2,15 @@ package natsrpc
 
 import (
 	"context"
-	"errors"
 	"reflect"
 	"strings"
 	"time"
 
 	"github.com/liuwangchen/toy/pkg/copier"
+	"github.com/liuwangchen/toy/transport/errors"
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
 	"github.com/nats-io/nats.go"
 )
 
@@ -25,13 +26,21 @@ type ClientConn struct {
 	mw          []middleware.Middleware // middleware
 	namespace   string                  // ns
 	timeout     time.Duration
+	streams     jetStreams
+	// 流的接收窗口
+	streamWindow int
+	// 流的心跳间隔
+	streamKeepalive time.Duration
+	// 记录请求和回复
+	recorder Recorder
 }
 
 // NewClientConn 构造器
 func NewClientConn(opts ...Option) (*ClientConn, error) {
 	c := &ClientConn{
-		encType: PROTOBUF_ENCODER,
-		timeout: time.Duration(3) * time.Second,
+		encType:         PROTOBUF_ENCODER,
+		timeout:         time.Duration(3) * time.Second,
+		streamKeepalive: defaultStreamKeepalive,
 	}
 	for _, v := range opts {
 		v(c)
@@ -144,13 +153,24 @@ func (c *Client) call(ctx context.Context, serviceName, methodName string, req i
 	ctx = c.withClientMetadata(ctx, clientConnMetadata)
 
 	ctx = WithHeaderContext(ctx, map[string]string{})
+	ctx = rpc.NewClientContext(ctx, &Transport{
+		endpoint:  c.conn.conn.ConnectedUrl(),
+		operation: operation(serviceName, methodName),
+		subject:   subject,
+		reqHeader: headerCarrier(HeaderFromCtx(ctx)),
+	})
 
 	h := func(ctx1 context.Context, req1 interface{}) (interface{}, error) {
 		// 取header
 		header := HeaderFromCtx(ctx1)
 
 		// traceId
-		trace.SetTraceIdIntoHeader(header, trace.GetTraceIdFromCtx(ctx))
+		trace.SetTraceIdIntoHeader(header, trace.GetTraceIdFromCtx(ctx1))
+
+		// 截止时间，publish不等待回复不需要
+		if deadline, ok := ctx1.Deadline(); ok && !isPublish {
+			setDeadlineIntoHeader(header, deadline)
+		}
 
 		// 取动态topic
 		callTopic := CallTopicFromCtx(ctx1)
@@ -163,24 +183,14 @@ func (c *Client) call(ctx context.Context, serviceName, methodName string, req i
 		if err != nil {
 			return nil, err
 		}
-		if isPublish { // publish
-			return nil, c.conn.enc.Publish(subject, rpcReq)
-		} else { // request
-			rp := &Reply{}
-			// call
-			err = c.conn.enc.RequestWithContext(ctx1, subject, rpcReq, rp)
-			if err != nil {
-				return nil, err
-			}
-			if len(rp.Error) > 0 {
-				return nil, errors.New(rp.Error)
-			}
-			// decode
-			if err := c.conn.enc.Enc.Decode(subject, rp.Payload, rep); err != nil {
-				return nil, err
-			}
-			return rep, nil
+		if c.conn.recorder == nil {
+			reply, _, err := c.conn.send(ctx1, subject, rpcReq, rep)
+			return reply, err
 		}
+		start := time.Now()
+		reply, replyPayload, err := c.conn.send(ctx1, subject, rpcReq, rep)
+		c.conn.recorder.Record(newRecord(RecordSideClient, subject, serviceName, methodName, isPublish, header, c.conn.enc.Enc, req1, reply, rpcReq.Payload, replyPayload, start, err))
+		return reply, err
 	}
 
 	// 中间件
@@ -202,6 +212,33 @@ func (c *Client) call(ctx context.Context, serviceName, methodName string, req i
 	return nil
 }
 
+// send 发送请求，rep为nil时publish，同时返回回复的原始编码
+func (c *ClientConn) send(ctx context.Context, subject string, rpcReq *Request, rep interface{}) (interface{}, []byte, error) {
+	if rep == nil { // publish
+		if js := JetStreamFromCtx(ctx); js != nil {
+			methodSub := ClientMetadataFromCtx(ctx).MethodSubject
+			if len(methodSub) == 0 {
+				methodSub = subject
+			}
+			return nil, nil, c.publishJetStream(ctx, methodSub, subject, js, rpcReq)
+		}
+		return nil, nil, c.enc.Publish(subject, rpcReq)
+	}
+	// request
+	rp := &Reply{}
+	if err := c.enc.RequestWithContext(ctx, subject, rpcReq, rp); err != nil {
+		return nil, nil, err
+	}
+	if err := replyError(rp); err != nil {
+		return nil, nil, err
+	}
+	// decode
+	if err := c.enc.Enc.Decode(subject, rp.Payload, rep); err != nil {
+		return nil, nil, err
+	}
+	return rep, rp.Payload, nil
+}
+
 // WaitForRsp 等待回复sub后反序列化rsp
 func WaitForRsp(ctx context.Context, conn *nats.Conn, replySub string, enc nats.Encoder, rsp interface{}) error {
 	// 等待reply
@@ -216,8 +253,8 @@ func WaitForRsp(ctx context.Context, conn *nats.Conn, replySub string, enc nats.
 	if err != nil {
 		return err
 	}
-	if len(rp.Error) > 0 {
-		return errors.New(rp.Error)
+	if err := replyError(rp); err != nil {
+		return err
 	}
 
 	// 反序列化rsp
@@ -242,8 +279,8 @@ func WaitForSubscribeRsp(ctx context.Context, sub *nats.Subscription, enc nats.E
 	if err != nil {
 		return err
 	}
-	if len(rp.Error) > 0 {
-		return errors.New(rp.Error)
+	if err := replyError(rp); err != nil {
+		return err
 	}
 
 	// 反序列化rsp
@@ -254,6 +291,31 @@ func WaitForSubscribeRsp(ctx context.Context, sub *nats.Subscription, enc nats.E
 	return nil
 }
 
+// newErrorReply 错误编码成Reply
+// Error保留完整字符串，只认字符串的老版本client也能拿到错误
+func newErrorReply(err error) *Reply {
+	se := errors.FromError(err)
+	return &Reply{
+		Error:    err.Error(),
+		Code:     se.Code,
+		Reason:   se.Reason,
+		Message:  se.Message,
+		Metadata: se.Metadata,
+	}
+}
+
+// replyError 还原Reply中的错误
+// 优先用code等字段，老版本server只有Error字符串
+func replyError(rp *Reply) error {
+	if rp.Code != 0 {
+		return errors.New(int(rp.Code), rp.Reason, rp.Message).WithMetadata(rp.Metadata)
+	}
+	if len(rp.Error) == 0 {
+		return nil
+	}
+	return errors.New(errors.UnknownCode, errors.UnknownReason, rp.Error)
+}
+
 func WaitForMsg(ctx context.Context, conn *nats.Conn, replySub string) (*nats.Msg, error) {
 	// 等待reply
 	sync, err := conn.SubscribeSync(replySub)
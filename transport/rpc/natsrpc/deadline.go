@@ -0,0 +1,97 @@
+package natsrpc
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/middleware/metrics"
+)
+
+// headerTimeout 调用方剩余的超时时间，格式同grpc-timeout，如"100m"
+// 传相对时间不依赖机器间时钟同步，server从收到消息开始计时
+// 排队的时间从收到消息算起，在nats订阅缓存中等待的时间不计入
+const headerTimeout = "Natsrpc-Timeout"
+
+// ServerShedRequests 调用方已超时而被丢弃的请求数
+var ServerShedRequests = metrics.NewCounterVec("natsrpc_server_shed_total", "The total number of requests dropped because the caller deadline exceeded", "operation")
+
+func init() {
+	metrics.DefaultRegistry.MustRegister(ServerShedRequests)
+}
+
+// timeoutUnits 从小到大的单位，取第一个能用8位数表示的
+var timeoutUnits = []struct {
+	unit byte
+	d    time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// maxTimeoutValue 最多8位数
+const maxTimeoutValue = 100000000 - 1
+
+// encodeTimeout 超时时间编码为grpc-timeout格式，按单位向上取整
+func encodeTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "0n"
+	}
+	for _, u := range timeoutUnits {
+		v := (d + u.d - 1) / u.d
+		if v <= maxTimeoutValue {
+			return strconv.FormatInt(int64(v), 10) + string(u.unit)
+		}
+	}
+	return strconv.Itoa(maxTimeoutValue) + "H"
+}
+
+// decodeTimeout 解析grpc-timeout格式的超时时间
+func decodeTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	for _, u := range timeoutUnits {
+		if u.unit == s[len(s)-1] {
+			return time.Duration(v) * u.d, true
+		}
+	}
+	return 0, false
+}
+
+// setDeadlineIntoHeader 截止时间按剩余时间写入header
+func setDeadlineIntoHeader(header map[string]string, deadline time.Time) {
+	header[headerTimeout] = encodeTimeout(time.Until(deadline))
+}
+
+// deadlineFromHeader 从header取剩余时间，按收到消息的时间换算成本地的截止时间
+func deadlineFromHeader(header map[string]string, received time.Time) (time.Time, bool) {
+	v, ok := header[headerTimeout]
+	if !ok {
+		return time.Time{}, false
+	}
+	d, ok := decodeTimeout(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	return received.Add(d), true
+}
+
+// shed 记录一次丢弃
+func (s *ServerConn) shed(operation string) {
+	atomic.AddUint64(&s.shedCount, 1)
+	ServerShedRequests.With(operation).Inc()
+}
+
+// ShedCount 调用方已超时而被丢弃的请求数
+func (s *ServerConn) ShedCount() uint64 {
+	return atomic.LoadUint64(&s.shedCount)
+}
@@ -0,0 +1,119 @@
+package natsrpc
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type Sleeper struct {
+	calls int32
+}
+
+// Sleep 睡眠req毫秒，返回ctx的截止时间
+func (s *Sleeper) Sleep(ctx context.Context, req *wrapperspb.Int64Value) (*wrapperspb.Int64Value, error) {
+	atomic.AddInt32(&s.calls, 1)
+	time.Sleep(time.Duration(req.Value) * time.Millisecond)
+	deadline, _ := ctx.Deadline()
+	return wrapperspb.Int64(deadline.UnixNano()), nil
+}
+
+func startSleeper(t *testing.T, url string, s *Sleeper) *ServerConn {
+	t.Helper()
+	conn, err := NewServerConn(WithAddr(url), WithTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有一个lane，后到的请求在前一个处理完之前排队
+	partition := map[string]*Partition{"Sleep": {Lanes: 1, Header: "x-md-sleeper"}}
+	if err := conn.Register("test.Sleeper", s, WithServiceMethodPartition(partition)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close(context.Background())
+	})
+	return conn
+}
+
+func TestDeadlinePropagation(t *testing.T) {
+	ns := runNatsServer(t)
+	startSleeper(t, ns.ClientURL(), &Sleeper{})
+	c := newStreamClient(t, ns.ClientURL())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	rep := new(wrapperspb.Int64Value)
+	if err := c.Request(ctx, "test.Sleeper", "Sleep", wrapperspb.Int64(0), rep); err != nil {
+		t.Fatal(err)
+	}
+	deadline, _ := ctx.Deadline()
+	if d := time.Unix(0, rep.Value).Sub(deadline); d > 10*time.Millisecond || d < -10*time.Millisecond {
+		t.Fatalf("expect server deadline near %v, got diff %v", deadline, d)
+	}
+}
+
+func TestDeadlineShed(t *testing.T) {
+	ns := runNatsServer(t)
+	s := &Sleeper{}
+	conn := startSleeper(t, ns.ClientURL(), s)
+	c := newStreamClient(t, ns.ClientURL())
+
+	go func() {
+		_ = c.Request(context.Background(), "test.Sleeper", "Sleep", wrapperspb.Int64(300), new(wrapperspb.Int64Value))
+	}()
+	for atomic.LoadInt32(&s.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// 排在慢请求后面，轮到时调用方已经超时
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Request(ctx, "test.Sleeper", "Sleep", wrapperspb.Int64(0), new(wrapperspb.Int64Value)); err == nil {
+		t.Fatal("expect deadline exceeded")
+	}
+	time.Sleep(400 * time.Millisecond)
+	if n := atomic.LoadInt32(&s.calls); n != 1 {
+		t.Fatalf("expect expired request not handled, got %d calls", n)
+	}
+	if n := conn.ShedCount(); n != 1 {
+		t.Fatalf("expect 1 shed, got %d", n)
+	}
+}
+
+func TestTimeoutCodec(t *testing.T) {
+	for _, c := range []struct {
+		d time.Duration
+		s string
+	}{
+		{0, "0n"},
+		{-time.Second, "0n"},
+		{time.Millisecond, "1000000n"},
+		{500 * time.Millisecond, "500000u"},
+		{time.Minute, "60000000u"},
+		{2 * time.Minute, "120000m"},
+		{48 * time.Hour, "172800S"},
+	} {
+		if s := encodeTimeout(c.d); s != c.s {
+			t.Fatalf("encode %v: %s != %s", c.d, s, c.s)
+		}
+		d, ok := decodeTimeout(c.s)
+		if want := c.d; !ok || (want > 0 && d != want) {
+			t.Fatalf("decode %s: %v %v", c.s, d, ok)
+		}
+	}
+	for _, s := range []string{"", "1", "10x", "-1m", "123456789m"} {
+		if _, ok := decodeTimeout(s); ok {
+			t.Fatalf("expect %q invalid", s)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// MetadataKey ServiceInstance.Metadata中ServerInfo的key
+	MetadataKey = "natsrpc"
+	// 探活和自省的subject，后面可以跟服务名
+	// 回复不是nats micro协议的格式，不使用$SRV，避免和micro服务混在一起
+	PingSubject = "$NATSRPC.PING"
+	InfoSubject = "$NATSRPC.INFO"
+)
+
+var _ rpc.Metadataer = (*ServerConn)(nil)
+
+// WithServiceVersion 服务版本，在注册中心和InfoSubject中展示
+func WithServiceVersion(version string) ServiceOption {
+	return func(s *service) {
+		s.version = version
+	}
+}
+
+// MethodInfo 方法信息
+type MethodInfo struct {
+	Name      string `json:"name"`
+	Subject   string `json:"subject"`
+	Queue     string `json:"queue,omitempty"`
+	IsPublish bool   `json:"isPublish,omitempty"`
+	Stream    bool   `json:"stream,omitempty"`
+	Gather    bool   `json:"gather,omitempty"`
+	JetStream bool   `json:"jetStream,omitempty"`
+}
+
+// ServiceInfo 服务信息
+type ServiceInfo struct {
+	Name    string        `json:"name"`
+	Version string        `json:"version,omitempty"`
+	Topic   string        `json:"topic,omitempty"`
+	Queue   string        `json:"queue"`
+	Methods []*MethodInfo `json:"methods"`
+}
+
+// ServerInfo InfoSubject的回复
+type ServerInfo struct {
+	ID        string         `json:"id"`
+	Namespace string         `json:"namespace,omitempty"`
+	Services  []*ServiceInfo `json:"services"`
+}
+
+// PingInfo PingSubject的回复
+type PingInfo struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace,omitempty"`
+	Services  []string  `json:"services"`
+	Started   time.Time `json:"started"`
+}
+
+// info 服务信息
+func (s *service) info(namespace string) *ServiceInfo {
+	si := &ServiceInfo{
+		Name:    s.serviceName,
+		Version: s.version,
+		Topic:   s.topic,
+		Queue:   s.queue,
+		Methods: make([]*MethodInfo, 0, len(s.methods)+len(s.methodStream)),
+	}
+	queueOf := func(name string) string {
+		if queue, ok := s.methodQueue[name]; ok {
+			return queue
+		}
+		return s.queue
+	}
+	for name, m := range s.methods {
+		mi := &MethodInfo{
+			Name:      name,
+			Subject:   CombineStr(s.methodSubject(namespace, name), s.topic),
+			Queue:     queueOf(name),
+			IsPublish: m.isPublish,
+			Gather:    s.methodGather[name],
+		}
+		if js := s.jetStreamOf(name); js != nil {
+			mi.JetStream = true
+		}
+		if mi.Gather {
+			mi.Queue = ""
+		}
+		si.Methods = append(si.Methods, mi)
+	}
+	for name := range s.methodStream {
+		si.Methods = append(si.Methods, &MethodInfo{
+			Name:    name,
+			Subject: CombineStr(namespace, s.serviceName, name, s.topic),
+			Queue:   queueOf(name),
+			Stream:  true,
+		})
+	}
+	sort.Slice(si.Methods, func(i, j int) bool {
+		return si.Methods[i].Name < si.Methods[j].Name
+	})
+	return si
+}
+
+// Info 注册的服务和方法
+func (s *ServerConn) Info() *ServerInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := &ServerInfo{
+		ID:        s.endpoint.String(),
+		Namespace: s.namespace,
+		Services:  make([]*ServiceInfo, 0, len(s.services)),
+	}
+	for serv := range s.services {
+		info.Services = append(info.Services, serv.info(s.namespace))
+	}
+	sort.Slice(info.Services, func(i, j int) bool {
+		return info.Services[i].Name < info.Services[j].Name
+	})
+	return info
+}
+
+// Metadata 注册中心的metadata，MetadataKey对应ServerInfo的json
+func (s *ServerConn) Metadata() map[string]string {
+	b, err := json.Marshal(s.Info())
+	if err != nil {
+		return nil
+	}
+	return map[string]string{MetadataKey: string(b)}
+}
+
+// ServerInfoFromMetadata 从注册中心的metadata还原ServerInfo
+func ServerInfoFromMetadata(md map[string]string) (*ServerInfo, error) {
+	v, ok := md[MetadataKey]
+	if !ok {
+		return nil, errors.NotFound("NO_NATSRPC_METADATA", "metadata has no "+MetadataKey)
+	}
+	info := &ServerInfo{}
+	if err := json.Unmarshal([]byte(v), info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ping 探活信息
+func (s *ServerConn) ping() *PingInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := &PingInfo{
+		ID:        s.endpoint.String(),
+		Namespace: s.namespace,
+		Services:  make([]string, 0, len(s.services)),
+		Started:   s.started,
+	}
+	for serv := range s.services {
+		p.Services = append(p.Services, serv.serviceName)
+	}
+	sort.Strings(p.Services)
+	return p
+}
+
+// subscribeIntrospection 订阅PingSubject和InfoSubject，不走queue，每个server都回复
+func (s *ServerConn) subscribeIntrospection() error {
+	pings := []string{PingSubject}
+	infos := []string{InfoSubject}
+	for serv := range s.services {
+		pings = append(pings, CombineStr(PingSubject, serv.serviceName))
+		infos = append(infos, CombineStr(InfoSubject, serv.serviceName))
+	}
+	for _, subject := range pings {
+		if err := s.subscribeSrv(subject, func() interface{} { return s.ping() }); err != nil {
+			return err
+		}
+	}
+	for _, subject := range infos {
+		if err := s.subscribeSrv(subject, func() interface{} { return s.Info() }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribeSrv 用json回复，任何nats客户端都可以查询
+func (s *ServerConn) subscribeSrv(subject string, get func() interface{}) error {
+	sub, err := s.conn.Subscribe(subject, func(msg *nats.Msg) {
+		if len(msg.Reply) == 0 {
+			return
+		}
+		b, err := json.Marshal(get())
+		if err != nil {
+			return
+		}
+		_ = msg.Respond(b)
+	})
+	if err != nil {
+		return err
+	}
+	s.srvSubs = append(s.srvSubs, sub)
+	return nil
+}
+
+// unsubscribeIntrospection 取消探活和自省的订阅，需持有锁
+func (s *ServerConn) unsubscribeIntrospection() {
+	for _, sub := range s.srvSubs {
+		_ = sub.Unsubscribe()
+	}
+	s.srvSubs = nil
+}
+
+// Ping 向所有server发PingSubject，service为空时不区分服务，在wait内收集回复
+func (c *ClientConn) Ping(ctx context.Context, service string, wait time.Duration) ([]*PingInfo, error) {
+	var ps []*PingInfo
+	err := c.introspect(ctx, CombineStr(PingSubject, service), wait, func(b []byte) error {
+		p := &PingInfo{}
+		if err := json.Unmarshal(b, p); err != nil {
+			return err
+		}
+		ps = append(ps, p)
+		return nil
+	})
+	return ps, err
+}
+
+// Info 向所有server发InfoSubject，service为空时不区分服务，在wait内收集回复
+func (c *ClientConn) Info(ctx context.Context, service string, wait time.Duration) ([]*ServerInfo, error) {
+	var infos []*ServerInfo
+	err := c.introspect(ctx, CombineStr(InfoSubject, service), wait, func(b []byte) error {
+		info := &ServerInfo{}
+		if err := json.Unmarshal(b, info); err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		return nil
+	})
+	return infos, err
+}
+
+func (c *ClientConn) introspect(ctx context.Context, subject string, wait time.Duration, decode func([]byte) error) error {
+	inbox := c.conn.NewRespInbox()
+	sub, err := c.conn.SubscribeSync(inbox)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	if err := c.conn.PublishRequest(subject, inbox, nil); err != nil {
+		return err
+	}
+	wctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	for {
+		msg, err := sub.NextMsgWithContext(wctx)
+		if err == nats.ErrNoResponders {
+			return errors.ServiceUnavailable("NO_RESPONDERS", "no responders on "+subject)
+		}
+		if err != nil {
+			// 父ctx结束返回错误，收集窗口结束是正常返回
+			return ctx.Err()
+		}
+		if err := decode(msg.Data); err != nil {
+			return err
+		}
+	}
+}
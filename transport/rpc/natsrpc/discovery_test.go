@@ -0,0 +1,75 @@
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+)
+
+func TestIntrospection(t *testing.T) {
+	ns := runNatsServer(t)
+	w := &Worker{gate: make(chan struct{})}
+	conn := startWorker(t, ns.ClientURL(), w,
+		WithServiceVersion("v1.0.0"),
+		WithServiceMethodQueue(map[string]string{"Other": "other"}),
+		WithServiceMethodReqRspIds(map[string][2]int32{"Work": {101, 102}}),
+	)
+	startPlayer(t, ns.ClientURL(), newPlayer(0))
+	c := newStreamClient(t, ns.ClientURL())
+
+	ps, err := c.conn.Ping(context.Background(), "", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 2 {
+		t.Fatalf("expect 2 pings, got %d", len(ps))
+	}
+	ps, err = c.conn.Ping(context.Background(), "test.Worker", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps[0].ID != conn.endpoint.String() || len(ps[0].Services) != 1 || ps[0].Started.IsZero() {
+		t.Fatalf("unexpected ping %+v", ps)
+	}
+
+	infos, err := c.conn.Info(context.Background(), "test.Worker", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || len(infos[0].Services) != 1 {
+		t.Fatalf("unexpected info %+v", infos)
+	}
+	si := infos[0].Services[0]
+	if si.Name != "test.Worker" || si.Version != "v1.0.0" || len(si.Methods) != 2 {
+		t.Fatalf("unexpected service info %+v", si)
+	}
+	if m := si.Methods[0]; m.Name != "Other" || m.Subject != "test.Worker.Other" || m.Queue != "other" {
+		t.Fatalf("unexpected method info %+v", m)
+	}
+	if m := si.Methods[1]; m.Name != "Work" || m.Subject != "test.Worker.101" || m.Queue != "default" || m.IsPublish {
+		t.Fatalf("unexpected method info %+v", m)
+	}
+
+	// 注册中心的metadata
+	info, err := ServerInfoFromMetadata(conn.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, _ := json.Marshal(info)
+	b2, _ := json.Marshal(infos[0])
+	if string(b1) != string(b2) {
+		t.Fatalf("metadata %s not equal to info %s", b1, b2)
+	}
+
+	// 关闭后不再回复
+	if err := conn.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.conn.Ping(context.Background(), "test.Worker", 100*time.Millisecond)
+	if errors.Code(err) != 503 {
+		t.Fatalf("expect no responders, got %v", err)
+	}
+}
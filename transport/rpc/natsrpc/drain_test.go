@@ -0,0 +1,140 @@
+package natsrpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCloseDrain(t *testing.T) {
+	ns := runNatsServer(t)
+	old := &Worker{gate: make(chan struct{})}
+	var release sync.Once
+	// 失败时也要放行in-flight请求，否则cleanup的Close会一直等待
+	defer release.Do(func() { close(old.gate) })
+	oldConn := startWorker(t, ns.ClientURL(), old)
+	c := newStreamClient(t, ns.ClientURL())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Request(context.Background(), "test.Worker", "Work", wrapperspb.Int64(0), new(wrapperspb.Int64Value))
+	}()
+	for atomic.LoadInt32(&old.running) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 新节点加入队列
+	peer := &Worker{gate: make(chan struct{})}
+	close(peer.gate)
+	startWorker(t, ns.ClientURL(), peer)
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		closed <- oldConn.Close(ctx)
+	}()
+	for oldConn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	// drain之后新请求都由peer处理
+	for i := 0; i < 10; i++ {
+		if err := c.Request(context.Background(), "test.Worker", "Work", wrapperspb.Int64(0), new(wrapperspb.Int64Value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release.Do(func() { close(old.gate) })
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&peer.max); n == 0 {
+		t.Fatal("expect peer handle new requests")
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	ns := runNatsServer(t)
+	w := &Worker{gate: make(chan struct{})}
+	defer close(w.gate)
+	conn := startWorker(t, ns.ClientURL(), w)
+	c := newStreamClient(t, ns.ClientURL())
+
+	go func() {
+		_ = c.Request(context.Background(), "test.Worker", "Work", wrapperspb.Int64(0), new(wrapperspb.Int64Value))
+	}()
+	for atomic.LoadInt32(&w.running) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := conn.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+}
+
+func TestStreamGracefulClose(t *testing.T) {
+	ns := runNatsServer(t)
+	conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	err = conn.Register("test.Streamer", &Streamer{}, WithServiceMethodStream(map[string]StreamHandler{
+		"Echo": func(ctx context.Context, stream IServerStream) error {
+			close(started)
+			req := new(wrapperspb.StringValue)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return stream.SendMsg(req)
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = conn.Start(context.Background())
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	c := newStreamClient(t, ns.ClientURL())
+	stream, err := c.NewStream(context.Background(), "test.Streamer", "Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		closed <- conn.Close(ctx)
+	}()
+	// Close等待期间流继续工作
+	time.Sleep(50 * time.Millisecond)
+	if err := stream.SendMsg(wrapperspb.String("hi")); err != nil {
+		t.Fatal(err)
+	}
+	rep := new(wrapperspb.StringValue)
+	if err := stream.RecvMsg(rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Value != "hi" {
+		t.Fatalf("expect hi, got %s", rep.Value)
+	}
+	if err := stream.RecvMsg(rep); err != io.EOF {
+		t.Fatalf("expect EOF, got %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+package natsrpc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/nats-io/nats.go"
+)
+
+// headerResponder 回复方标识，server在每个回复中带上
+const headerResponder = "Natsrpc-Responder"
+
+// WithServiceMethodGather 方法不走queue，广播给所有实例，配合Client.Gather收集多个回复
+func WithServiceMethodGather(methodGather map[string]bool) ServiceOption {
+	return func(s *service) {
+		s.methodGather = methodGather
+	}
+}
+
+// GatherOption gather选项
+type GatherOption func(o *gatherOptions)
+
+type gatherOptions struct {
+	timeout time.Duration                     // 收集窗口
+	expect  int                               // 收到expect个回复后返回，0表示等到超时
+	until   func(replies []*GatherReply) bool // 返回true时停止收集
+}
+
+// GatherTimeout 收集窗口，默认使用ClientConn的超时时间
+func GatherTimeout(timeout time.Duration) GatherOption {
+	return func(o *gatherOptions) {
+		o.timeout = timeout
+	}
+}
+
+// GatherExpect 收到n个回复后立即返回
+func GatherExpect(n int) GatherOption {
+	return func(o *gatherOptions) {
+		o.expect = n
+	}
+}
+
+// GatherUntil 每收到一个回复调用一次，返回true时立即返回
+func GatherUntil(until func(replies []*GatherReply) bool) GatherOption {
+	return func(o *gatherOptions) {
+		o.until = until
+	}
+}
+
+// GatherReply 单个回复方的结果
+type GatherReply struct {
+	Responder string      // 回复方标识
+	Reply     interface{} // Err为nil时有效
+	Err       error       // 回复方返回的错误
+}
+
+// Gather 发布一次请求，收集所有实例的回复
+// 收集窗口结束、达到期望数量或until满足时返回，回复方的错误放在GatherReply.Err中
+// newReply 为每个回复创建一个rsp
+func (c *Client) Gather(ctx context.Context, serviceName, methodName string, req interface{}, newReply func() interface{}, opts ...GatherOption) ([]*GatherReply, error) {
+	o := &gatherOptions{timeout: c.conn.timeout}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// subject
+	subject := CombineStr(c.conn.namespace, serviceName, methodName)
+
+	// metadata
+	splits := strings.Split(serviceName, ".")
+	clientConnMetadata := ClientConnMetadata{
+		Namespace:     c.conn.namespace,
+		ServiceName:   serviceName,
+		MethodName:    methodName,
+		MethodSubject: subject,
+		ReqType:       reflect.TypeOf(req),
+		RspType:       reflect.TypeOf(newReply()),
+		Encoder:       c.conn.enc.Enc,
+	}
+	if len(splits) > 0 {
+		clientConnMetadata.ServiceSimpleName = splits[len(splits)-1]
+	}
+	ctx = c.withClientMetadata(ctx, clientConnMetadata)
+
+	ctx = WithHeaderContext(ctx, map[string]string{})
+	ctx = rpc.NewClientContext(ctx, &Transport{
+		endpoint:  c.conn.conn.ConnectedUrl(),
+		operation: operation(serviceName, methodName),
+		subject:   subject,
+		reqHeader: headerCarrier(HeaderFromCtx(ctx)),
+	})
+
+	h := func(ctx1 context.Context, req1 interface{}) (interface{}, error) {
+		header := HeaderFromCtx(ctx1)
+		trace.SetTraceIdIntoHeader(header, trace.GetTraceIdFromCtx(ctx1))
+		// 截止时间取ctx和收集窗口中较早的
+		deadline := time.Now().Add(o.timeout)
+		if d, ok := ctx1.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		setDeadlineIntoHeader(header, deadline)
+		subject := CombineStr(subject, CallTopicFromCtx(ctx1))
+		rpcReq, err := NewRequestWithEncoder(subject, req1, header, c.conn.enc.Enc)
+		if err != nil {
+			return nil, err
+		}
+		return c.gather(ctx1, subject, rpcReq, newReply, o)
+	}
+
+	// 中间件
+	mw := append(c.conn.mw[:], c.mw...)
+	if len(mw) > 0 {
+		h = middleware.Chain(mw...)(h)
+	}
+
+	r, err := h(ctx, req)
+	replies, _ := r.([]*GatherReply)
+	return replies, err
+}
+
+func (c *Client) gather(ctx context.Context, subject string, rpcReq *Request, newReply func() interface{}, o *gatherOptions) ([]*GatherReply, error) {
+	inbox := c.conn.conn.NewRespInbox()
+	sub, err := c.conn.conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+	if err := c.conn.enc.PublishRequest(subject, inbox, rpcReq); err != nil {
+		return nil, err
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	var replies []*GatherReply
+	for {
+		msg, err := sub.NextMsgWithContext(wctx)
+		if err == nats.ErrNoResponders {
+			return nil, errors.ServiceUnavailable("NO_RESPONDERS", "no responders on "+subject)
+		}
+		if err != nil {
+			// 父ctx结束返回错误，收集窗口结束是正常返回
+			if ctx.Err() != nil {
+				return replies, ctx.Err()
+			}
+			return replies, nil
+		}
+		replies = append(replies, c.decodeGatherReply(subject, msg, newReply))
+		if o.expect > 0 && len(replies) >= o.expect {
+			return replies, nil
+		}
+		if o.until != nil && o.until(replies) {
+			return replies, nil
+		}
+	}
+}
+
+func (c *Client) decodeGatherReply(subject string, msg *nats.Msg, newReply func() interface{}) *GatherReply {
+	r := &GatherReply{Responder: msg.Header.Get(headerResponder)}
+	rp := &Reply{}
+	if err := c.conn.enc.Enc.Decode(subject, msg.Data, rp); err != nil {
+		r.Err = err
+		return r
+	}
+	if err := replyError(rp); err != nil {
+		r.Err = err
+		return r
+	}
+	rep := newReply()
+	if err := c.conn.enc.Enc.Decode(subject, rp.Payload, rep); err != nil {
+		r.Err = err
+		return r
+	}
+	r.Reply = rep
+	return r
+}
@@ -0,0 +1,141 @@
+package natsrpc
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type Shard struct {
+	id string
+}
+
+func (s *Shard) Count(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	if s.id == "bad" {
+		return nil, errors.NotFound("NO_SHARD", "bad shard")
+	}
+	return wrapperspb.String(s.id), nil
+}
+
+func startShard(t *testing.T, url string, id string) {
+	t.Helper()
+	conn, err := NewServerConn(WithAddr(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Shard", &Shard{id: id}, WithServiceMethodGather(map[string]bool{"Count": true})); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close(context.Background())
+	})
+}
+
+func newStringValue() interface{} {
+	return new(wrapperspb.StringValue)
+}
+
+func TestGather(t *testing.T) {
+	ns := runNatsServer(t)
+	for _, id := range []string{"a", "b", "bad"} {
+		startShard(t, ns.ClientURL(), id)
+	}
+	c := newStreamClient(t, ns.ClientURL())
+
+	// 等到收集窗口结束
+	replies, err := c.Gather(context.Background(), "test.Shard", "Count", wrapperspb.String(""), newStringValue, GatherTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	responders := map[string]struct{}{}
+	for _, r := range replies {
+		responders[r.Responder] = struct{}{}
+		if r.Err != nil {
+			if errors.Reason(r.Err) != "NO_SHARD" {
+				t.Fatalf("expect NO_SHARD, got %v", r.Err)
+			}
+			continue
+		}
+		ids = append(ids, r.Reply.(*wrapperspb.StringValue).Value)
+	}
+	sort.Strings(ids)
+	if len(replies) != 3 || len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Fatalf("unexpected replies %v", ids)
+	}
+	if len(responders) != 3 {
+		t.Fatalf("expect 3 responders, got %d", len(responders))
+	}
+
+	// 达到期望数量立即返回
+	start := time.Now()
+	replies, err = c.Gather(context.Background(), "test.Shard", "Count", wrapperspb.String(""), newStringValue, GatherTimeout(time.Second), GatherExpect(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 2 || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("expect 2 replies before timeout, got %d in %v", len(replies), time.Since(start))
+	}
+
+	// until满足立即返回
+	replies, err = c.Gather(context.Background(), "test.Shard", "Count", wrapperspb.String(""), newStringValue, GatherTimeout(time.Second), GatherUntil(func(replies []*GatherReply) bool {
+		return replies[len(replies)-1].Err != nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := replies[len(replies)-1]; last.Err == nil {
+		t.Fatal("expect stop at error reply")
+	}
+}
+
+func TestGatherCanceled(t *testing.T) {
+	ns := runNatsServer(t)
+	startShard(t, ns.ClientURL(), "a")
+	c := newStreamClient(t, ns.ClientURL())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	replies, err := c.Gather(ctx, "test.Shard", "Count", wrapperspb.String(""), newStringValue, GatherTimeout(time.Second))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	if len(replies) != 1 {
+		t.Fatalf("expect replies collected before cancel, got %d", len(replies))
+	}
+}
+
+func TestGatherNoResponders(t *testing.T) {
+	ns := runNatsServer(t)
+	c := newStreamClient(t, ns.ClientURL())
+	_, err := c.Gather(context.Background(), "test.Shard", "Count", wrapperspb.String(""), newStringValue)
+	if errors.Code(err) != 503 {
+		t.Fatalf("expect 503, got %v", err)
+	}
+}
+
+func TestGatherRequireReply(t *testing.T) {
+	ns := runNatsServer(t)
+	conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(context.Background())
+	if err := conn.Register("test.Notifier", newNotifier(0), WithServiceMethodGather(map[string]bool{"Notify": true})); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Start(context.Background()); err == nil {
+		t.Fatal("expect error for publish method with gather")
+	}
+}
@@ -0,0 +1,222 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// WithServiceMethodJetStream 方法走JetStream持久化投递 methodName -> 配置
+func WithServiceMethodJetStream(methodJetStream map[string]*JetStream) ServiceOption {
+	return func(s *service) {
+		s.methodJetStream = methodJetStream
+	}
+}
+
+// WithServiceJetStream service级别的JetStream，没有单独配置的无返回值方法都走JetStream
+// 没有配置stream名时每个方法一个stream；配置了stream名时方法共用，durable名后加方法名区分
+func WithServiceJetStream(js *JetStream) ServiceOption {
+	return func(s *service) {
+		s.jetStream = js
+	}
+}
+
+// jetStreamOf 方法的JetStream配置，优先用方法级别的
+func (s *service) jetStreamOf(methodName string) *JetStream {
+	if js, ok := s.methodJetStream[methodName]; ok {
+		return js
+	}
+	if m, ok := s.methods[methodName]; ok && m.isPublish {
+		return s.jetStream
+	}
+	return nil
+}
+
+type jetStreamKey struct{}
+
+// WithJetStreamContext 本次publish走JetStream，生成代码根据natsrpc.proto的jetstream选项填充
+func WithJetStreamContext(ctx context.Context, js *JetStream) context.Context {
+	return context.WithValue(ctx, jetStreamKey{}, js)
+}
+
+// JetStreamFromCtx 获得JetStream配置
+func JetStreamFromCtx(ctx context.Context) *JetStream {
+	if ctx == nil {
+		return nil
+	}
+	js, _ := ctx.Value(jetStreamKey{}).(*JetStream)
+	return js
+}
+
+var nameReplacer = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+// streamName stream名，未配置时由方法的subject生成
+func streamName(js *JetStream, methodSub string) string {
+	if len(js.GetStream()) > 0 {
+		return js.GetStream()
+	}
+	return nameReplacer.Replace(methodSub)
+}
+
+// streamSubjects 方法在stream中的subject，方法本身和带topic的通配
+// 每个方法固定两个，动态topic不会让stream的subject无限增长
+func streamSubjects(methodSub string) []string {
+	return []string{methodSub, methodSub + ".>"}
+}
+
+// durableName durable consumer名，未配置时使用queue
+func durableName(js *JetStream, queue string) string {
+	if len(js.GetDurable()) > 0 {
+		return js.GetDurable()
+	}
+	return nameReplacer.Replace(queue)
+}
+
+// consumerName 订阅subject的durable名
+// 同一stream中的每个subject要有自己的durable：带topic时加上topic，配置了stream名时方法可能共用stream，加上方法名
+func consumerName(js *JetStream, queue, methodName, methodSub, subject string) string {
+	name := durableName(js, queue)
+	if len(js.GetStream()) > 0 {
+		name += "_" + nameReplacer.Replace(methodName)
+	}
+	if topic := strings.TrimPrefix(subject, methodSub+"."); topic != subject {
+		name += "_" + nameReplacer.Replace(topic)
+	}
+	return name
+}
+
+// ensureStream 保证stream存在且包含方法的subject
+func ensureStream(jsm nats.JetStreamManager, stream, methodSub string) error {
+	subjects := streamSubjects(methodSub)
+	info, err := jsm.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = jsm.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: subjects,
+		})
+		if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+			// 并发创建
+			return ensureStream(jsm, stream, methodSub)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	cfg := info.Config
+	for _, subject := range subjects {
+		found := false
+		for _, v := range cfg.Subjects {
+			if v == subject {
+				found = true
+				break
+			}
+		}
+		if !found {
+			cfg.Subjects = append(cfg.Subjects, subject)
+		}
+	}
+	if len(cfg.Subjects) == len(info.Config.Subjects) {
+		return nil
+	}
+	_, err = jsm.UpdateStream(&cfg)
+	return err
+}
+
+// ensureConsumer 保证durable consumer存在
+// consumer由这里创建而不是由订阅创建，Unsubscribe时不会被删除，重启后从未ack的位置继续
+// 已存在的consumer订阅的subject或者queue不一致时报错，不能绑定到别的方法的consumer上
+func ensureConsumer(jsm nats.JetStreamManager, stream, durable, queue, subject string, js *JetStream) error {
+	info, err := jsm.ConsumerInfo(stream, durable)
+	if err == nil {
+		return checkConsumer(info, durable, queue, subject)
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return err
+	}
+	cfg := &nats.ConsumerConfig{
+		Durable:        durable,
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   queue,
+		DeliverPolicy:  nats.DeliverAllPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		FilterSubject:  subject,
+		MaxDeliver:     int(js.GetMaxDeliver()),
+		AckWait:        time.Duration(js.GetAckWaitMs()) * time.Millisecond,
+	}
+	if cfg.MaxDeliver == 0 {
+		cfg.MaxDeliver = -1
+	}
+	_, err = jsm.AddConsumer(stream, cfg)
+	if errors.Is(err, nats.ErrConsumerNameAlreadyInUse) {
+		// 并发创建
+		return ensureConsumer(jsm, stream, durable, queue, subject, js)
+	}
+	return err
+}
+
+// checkConsumer 检查已存在的consumer和订阅是否一致
+func checkConsumer(info *nats.ConsumerInfo, durable, queue, subject string) error {
+	if info.Config.FilterSubject != subject || info.Config.DeliverGroup != queue {
+		return fmt.Errorf("jetstream consumer %s already bound to subject %s queue %s, want subject %s queue %s",
+			durable, info.Config.FilterSubject, info.Config.DeliverGroup, subject, queue)
+	}
+	return nil
+}
+
+// subscribeJetStream 以durable consumer订阅，handler成功后ack，失败nak等待重投
+func (s *ServerConn) subscribeJetStream(methodSub, subject, queue, durable string, cfg *JetStream, cb nats.MsgHandler) (*nats.Subscription, error) {
+	js, err := s.conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	stream := streamName(cfg, methodSub)
+	if err := ensureStream(js, stream, methodSub); err != nil {
+		return nil, err
+	}
+	if err := ensureConsumer(js, stream, durable, queue, subject, cfg); err != nil {
+		return nil, err
+	}
+	return js.QueueSubscribe(subject, queue, cb, nats.Bind(stream, durable), nats.ManualAck())
+}
+
+// jetStreams 客户端已确认存在的stream
+type jetStreams struct {
+	mu      sync.Mutex
+	ensured map[string]struct{}
+}
+
+// publishJetStream 发布到JetStream并等待服务端确认持久化，methodSub为不带topic的方法subject
+func (c *ClientConn) publishJetStream(ctx context.Context, methodSub, subject string, cfg *JetStream, req *Request) error {
+	js, err := c.conn.JetStream()
+	if err != nil {
+		return err
+	}
+	stream := streamName(cfg, methodSub)
+	key := stream + " " + methodSub
+	c.streams.mu.Lock()
+	_, ok := c.streams.ensured[key]
+	c.streams.mu.Unlock()
+	if !ok {
+		if err := ensureStream(js, stream, methodSub); err != nil {
+			return err
+		}
+		c.streams.mu.Lock()
+		if c.streams.ensured == nil {
+			c.streams.ensured = map[string]struct{}{}
+		}
+		c.streams.ensured[key] = struct{}{}
+		c.streams.mu.Unlock()
+	}
+	b, err := c.enc.Enc.Encode(subject, req)
+	if err != nil {
+		return err
+	}
+	_, err = js.Publish(subject, b, nats.Context(ctx))
+	return err
+}
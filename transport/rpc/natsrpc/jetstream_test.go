@@ -0,0 +1,314 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// runNatsServer 启动开启JetStream的内嵌nats-server
+func runNatsServer(t *testing.T) *server.Server {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "natsrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  dir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats-server not ready")
+	}
+	t.Cleanup(func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+		os.RemoveAll(dir)
+	})
+	return ns
+}
+
+type Notifier struct {
+	mu     sync.Mutex
+	fails  int // 前fails次返回错误
+	counts map[string]int
+	ch     chan string
+}
+
+func (n *Notifier) Notify(ctx context.Context, req *wrapperspb.StringValue) error {
+	n.mu.Lock()
+	n.counts[req.Value]++
+	fail := n.counts[req.Value] <= n.fails
+	n.mu.Unlock()
+	if fail {
+		return errors.New("fail")
+	}
+	n.ch <- req.Value
+	return nil
+}
+
+func newNotifier(fails int) *Notifier {
+	return &Notifier{fails: fails, counts: map[string]int{}, ch: make(chan string, 10)}
+}
+
+func startJetStreamServer(t *testing.T, url string, n *Notifier, js *JetStream) *ServerConn {
+	t.Helper()
+	conn, err := NewServerConn(WithAddr(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Notifier", n, WithServiceMethodJetStream(map[string]*JetStream{"Notify": js})); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close(context.Background())
+	})
+	return conn
+}
+
+func publishJetStream(t *testing.T, c *Client, js *JetStream, value string) {
+	t.Helper()
+	ctx := WithJetStreamContext(context.Background(), js)
+	if err := c.Publish(ctx, "test.Notifier", "Notify", wrapperspb.String(value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectValue(t *testing.T, ch chan string, expect string) {
+	t.Helper()
+	select {
+	case v := <-ch:
+		if v != expect {
+			t.Fatalf("expect %s, got %s", expect, v)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("expect %s delivered", expect)
+	}
+}
+
+func TestJetStreamDurable(t *testing.T) {
+	ns := runNatsServer(t)
+	js := &JetStream{MaxDeliver: 3, AckWaitMs: 1000}
+	cc, err := NewClientConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(cc)
+
+	// 服务未上线时发布的消息不会丢
+	publishJetStream(t, c, js, "a")
+	n := newNotifier(0)
+	s := startJetStreamServer(t, ns.ClientURL(), n, js)
+	expectValue(t, n.ch, "a")
+
+	// 服务重启期间发布的消息由同一个durable consumer继续消费
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	publishJetStream(t, c, js, "b")
+	n = newNotifier(0)
+	startJetStreamServer(t, ns.ClientURL(), n, js)
+	expectValue(t, n.ch, "b")
+	select {
+	case v := <-n.ch:
+		t.Fatalf("acked message %s redelivered", v)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestJetStreamRedeliver(t *testing.T) {
+	ns := runNatsServer(t)
+	js := &JetStream{MaxDeliver: 3, AckWaitMs: 1000}
+	cc, err := NewClientConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(cc)
+
+	// 失败两次后第三次成功
+	n := newNotifier(2)
+	startJetStreamServer(t, ns.ClientURL(), n, js)
+	publishJetStream(t, c, js, "a")
+	expectValue(t, n.ch, "a")
+
+	// 超过最大投递次数后不再投递
+	n.mu.Lock()
+	n.fails = 10
+	n.mu.Unlock()
+	publishJetStream(t, c, js, "b")
+	time.Sleep(300 * time.Millisecond)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.counts["b"] != 3 {
+		t.Fatalf("expect 3 deliveries, got %d", n.counts["b"])
+	}
+}
+
+func TestJetStreamRequireNoReply(t *testing.T) {
+	ns := runNatsServer(t)
+	conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(context.Background())
+	if err := conn.Register("test.Echo", &Echo{}, WithServiceMethodJetStream(map[string]*JetStream{"Echo": {}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Start(context.Background()); err == nil {
+		t.Fatal("expect error for request method with jetstream")
+	}
+}
+
+type Echo struct{}
+
+func (e *Echo) Echo(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return req, nil
+}
+
+func TestServiceJetStream(t *testing.T) {
+	ns := runNatsServer(t)
+	js := &JetStream{Stream: "notify", MaxDeliver: 3}
+	notifiers := map[string]*Notifier{}
+	for _, topic := range []string{"t1", "t2"} {
+		n := newNotifier(0)
+		notifiers[topic] = n
+		conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Register("test.Notifier", n, WithServiceJetStream(js), WithServiceTopic(topic)); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			_ = conn.Start(ctx)
+		}()
+		for !conn.Ready() {
+			time.Sleep(time.Millisecond)
+		}
+		t.Cleanup(func() {
+			cancel()
+			_ = conn.Close(context.Background())
+		})
+	}
+	cc, err := NewClientConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(cc)
+	for _, topic := range []string{"t1", "t2"} {
+		ctx := WithCallTopicContext(WithJetStreamContext(context.Background(), js), topic)
+		if err := c.Publish(ctx, "test.Notifier", "Notify", wrapperspb.String(topic)); err != nil {
+			t.Fatal(err)
+		}
+		expectValue(t, notifiers[topic].ch, topic)
+	}
+
+	// 每个方法固定两个subject，不随topic增长
+	jsm, err := cc.GetConn().JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := jsm.StreamInfo("notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Config.Subjects) != 2 || info.Config.Subjects[1] != "test.Notifier.Notify.>" {
+		t.Fatalf("unexpected subjects %v", info.Config.Subjects)
+	}
+}
+
+type Events struct {
+	created chan string
+	deleted chan string
+}
+
+func (e *Events) Created(ctx context.Context, req *wrapperspb.StringValue) error {
+	e.created <- req.Value
+	return nil
+}
+
+func (e *Events) Deleted(ctx context.Context, req *wrapperspb.StringValue) error {
+	e.deleted <- req.Value
+	return nil
+}
+
+func TestJetStreamMethodsShareStream(t *testing.T) {
+	ns := runNatsServer(t)
+	js := &JetStream{Stream: "EVENTS"}
+	e := &Events{created: make(chan string, 10), deleted: make(chan string, 10)}
+	conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 方法级别的配置指定同一个stream，每个方法还是要有自己的consumer
+	if err := conn.Register("test.Events", e, WithServiceMethodJetStream(map[string]*JetStream{"Created": js, "Deleted": js})); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- conn.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close(context.Background())
+	})
+	for !conn.Ready() {
+		select {
+		case err := <-errCh:
+			t.Fatal(err)
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cc, err := NewClientConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(cc)
+	pctx := WithJetStreamContext(context.Background(), js)
+	if err := c.Publish(pctx, "test.Events", "Created", wrapperspb.String("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(pctx, "test.Events", "Deleted", wrapperspb.String("d")); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, e.created, "c")
+	expectValue(t, e.deleted, "d")
+
+	// 同名consumer订阅别的subject时报错，不静默复用
+	jsm, err := cc.GetConn().JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureConsumer(jsm, "EVENTS", "dup", "q", "test.Events.Created", js); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureConsumer(jsm, "EVENTS", "dup", "q", "test.Events.Deleted", js); err == nil {
+		t.Fatal("expect error for consumer bound to another subject")
+	}
+}
@@ -21,6 +21,256 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Overflow 并发满时的策略
+type Overflow int32
+
+const (
+	Overflow_BLOCK  Overflow = 0 // 阻塞等待，消息堆积在订阅缓存中，超过pending限制由nats丢弃
+	Overflow_DROP   Overflow = 1 // 丢弃，request回复ResourceExhausted，JetStream消息不再投递
+	Overflow_REJECT Overflow = 2 // 拒绝，request回复ServiceUnavailable，JetStream消息立即重投给其他实例
+)
+
+// Enum value maps for Overflow.
+var (
+	Overflow_name = map[int32]string{
+		0: "BLOCK",
+		1: "DROP",
+		2: "REJECT",
+	}
+	Overflow_value = map[string]int32{
+		"BLOCK":  0,
+		"DROP":   1,
+		"REJECT": 2,
+	}
+)
+
+func (x Overflow) Enum() *Overflow {
+	p := new(Overflow)
+	*p = x
+	return p
+}
+
+func (x Overflow) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Overflow) Descriptor() protoreflect.EnumDescriptor {
+	return file_natsrpc_proto_enumTypes[0].Descriptor()
+}
+
+func (Overflow) Type() protoreflect.EnumType {
+	return &file_natsrpc_proto_enumTypes[0]
+}
+
+func (x Overflow) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Overflow.Descriptor instead.
+func (Overflow) EnumDescriptor() ([]byte, []int) {
+	return file_natsrpc_proto_rawDescGZIP(), []int{0}
+}
+
+// JetStream 持久化投递配置
+type JetStream struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Stream     string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`          // stream名，为空时由subject生成
+	Durable    string `protobuf:"bytes,2,opt,name=durable,proto3" json:"durable,omitempty"`        // durable consumer名，为空时使用queue
+	MaxDeliver int32  `protobuf:"varint,3,opt,name=maxDeliver,proto3" json:"maxDeliver,omitempty"` // 最大投递次数，0表示不限制
+	AckWaitMs  int64  `protobuf:"varint,4,opt,name=ackWaitMs,proto3" json:"ackWaitMs,omitempty"`   // 未ack时的重投间隔，0使用服务端默认值
+}
+
+func (x *JetStream) Reset() {
+	*x = JetStream{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_natsrpc_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *JetStream) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JetStream) ProtoMessage() {}
+
+func (x *JetStream) ProtoReflect() protoreflect.Message {
+	mi := &file_natsrpc_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JetStream.ProtoReflect.Descriptor instead.
+func (*JetStream) Descriptor() ([]byte, []int) {
+	return file_natsrpc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *JetStream) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *JetStream) GetDurable() string {
+	if x != nil {
+		return x.Durable
+	}
+	return ""
+}
+
+func (x *JetStream) GetMaxDeliver() int32 {
+	if x != nil {
+		return x.MaxDeliver
+	}
+	return 0
+}
+
+func (x *JetStream) GetAckWaitMs() int64 {
+	if x != nil {
+		return x.AckWaitMs
+	}
+	return 0
+}
+
+// Concurrency 并发限制
+type Concurrency struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Max          int32    `protobuf:"varint,1,opt,name=max,proto3" json:"max,omitempty"`                                 // 同时处理的最大请求数，0表示不限制
+	PendingMsgs  int32    `protobuf:"varint,2,opt,name=pendingMsgs,proto3" json:"pendingMsgs,omitempty"`                 // 订阅缓存的最大消息数，0使用nats默认值，-1不限制
+	PendingBytes int64    `protobuf:"varint,3,opt,name=pendingBytes,proto3" json:"pendingBytes,omitempty"`               // 订阅缓存的最大字节数，0使用nats默认值，-1不限制
+	Overflow     Overflow `protobuf:"varint,4,opt,name=overflow,proto3,enum=natsrpc.Overflow" json:"overflow,omitempty"` // 达到max时的策略
+}
+
+func (x *Concurrency) Reset() {
+	*x = Concurrency{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_natsrpc_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Concurrency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Concurrency) ProtoMessage() {}
+
+func (x *Concurrency) ProtoReflect() protoreflect.Message {
+	mi := &file_natsrpc_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Concurrency.ProtoReflect.Descriptor instead.
+func (*Concurrency) Descriptor() ([]byte, []int) {
+	return file_natsrpc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Concurrency) GetMax() int32 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *Concurrency) GetPendingMsgs() int32 {
+	if x != nil {
+		return x.PendingMsgs
+	}
+	return 0
+}
+
+func (x *Concurrency) GetPendingBytes() int64 {
+	if x != nil {
+		return x.PendingBytes
+	}
+	return 0
+}
+
+func (x *Concurrency) GetOverflow() Overflow {
+	if x != nil {
+		return x.Overflow
+	}
+	return Overflow_BLOCK
+}
+
+// Partition 按key分配到多个lane，同key顺序处理，不同key并行
+type Partition struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lanes  int32  `protobuf:"varint,1,opt,name=lanes,proto3" json:"lanes,omitempty"`  // lane数
+	Header string `protobuf:"bytes,2,opt,name=header,proto3" json:"header,omitempty"` // 从header取key，为空时用请求中标注了partitionKey的字段
+}
+
+func (x *Partition) Reset() {
+	*x = Partition{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_natsrpc_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Partition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Partition) ProtoMessage() {}
+
+func (x *Partition) ProtoReflect() protoreflect.Message {
+	mi := &file_natsrpc_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Partition.ProtoReflect.Descriptor instead.
+func (*Partition) Descriptor() ([]byte, []int) {
+	return file_natsrpc_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Partition) GetLanes() int32 {
+	if x != nil {
+		return x.Lanes
+	}
+	return 0
+}
+
+func (x *Partition) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
 // Request 请求
 type Request struct {
 	state         protoimpl.MessageState
@@ -34,7 +284,7 @@ type Request struct {
 func (x *Request) Reset() {
 	*x = Request{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_natsrpc_proto_msgTypes[0]
+		mi := &file_natsrpc_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -47,7 +297,7 @@ func (x *Request) String() string {
 func (*Request) ProtoMessage() {}
 
 func (x *Request) ProtoReflect() protoreflect.Message {
-	mi := &file_natsrpc_proto_msgTypes[0]
+	mi := &file_natsrpc_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -60,7 +310,7 @@ func (x *Request) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Request.ProtoReflect.Descriptor instead.
 func (*Request) Descriptor() ([]byte, []int) {
-	return file_natsrpc_proto_rawDescGZIP(), []int{0}
+	return file_natsrpc_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Request) GetPayload() []byte {
@@ -83,14 +333,18 @@ type Reply struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Payload []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"` // 包体
-	Error   string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`     // 错误
+	Payload  []byte            `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`                                                                                           // 包体
+	Error    string            `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`                                                                                               // 错误，保留完整字符串兼容只认字符串的老版本
+	Code     int32             `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`                                                                                                // 错误码，不为0表示有错误
+	Reason   string            `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`                                                                                             // 错误原因
+	Message  string            `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`                                                                                           // 错误信息
+	Metadata map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // 错误元数据
 }
 
 func (x *Reply) Reset() {
 	*x = Reply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_natsrpc_proto_msgTypes[1]
+		mi := &file_natsrpc_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -103,7 +357,7 @@ func (x *Reply) String() string {
 func (*Reply) ProtoMessage() {}
 
 func (x *Reply) ProtoReflect() protoreflect.Message {
-	mi := &file_natsrpc_proto_msgTypes[1]
+	mi := &file_natsrpc_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -116,7 +370,7 @@ func (x *Reply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Reply.ProtoReflect.Descriptor instead.
 func (*Reply) Descriptor() ([]byte, []int) {
-	return file_natsrpc_proto_rawDescGZIP(), []int{1}
+	return file_natsrpc_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Reply) GetPayload() []byte {
@@ -133,6 +387,34 @@ func (x *Reply) GetError() string {
 	return ""
 }
 
+func (x *Reply) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Reply) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Reply) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Reply) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
 var file_natsrpc_proto_extTypes = []protoimpl.ExtensionInfo{
 	{
 		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
@@ -150,6 +432,22 @@ var file_natsrpc_proto_extTypes = []protoimpl.ExtensionInfo{
 		Tag:           "bytes,43232,opt,name=topic",
 		Filename:      "natsrpc.proto",
 	},
+	{
+		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
+		ExtensionType: (*Concurrency)(nil),
+		Field:         43233,
+		Name:          "natsrpc.serviceConcurrency",
+		Tag:           "bytes,43233,opt,name=serviceConcurrency",
+		Filename:      "natsrpc.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.ServiceOptions)(nil),
+		ExtensionType: (*JetStream)(nil),
+		Field:         43234,
+		Name:          "natsrpc.serviceJetStream",
+		Tag:           "bytes,43234,opt,name=serviceJetStream",
+		Filename:      "natsrpc.proto",
+	},
 	{
 		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
 		ExtensionType: (*string)(nil),
@@ -182,6 +480,46 @@ var file_natsrpc_proto_extTypes = []protoimpl.ExtensionInfo{
 		Tag:           "varint,2365,opt,name=sequence",
 		Filename:      "natsrpc.proto",
 	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*JetStream)(nil),
+		Field:         2366,
+		Name:          "natsrpc.jetstream",
+		Tag:           "bytes,2366,opt,name=jetstream",
+		Filename:      "natsrpc.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         2367,
+		Name:          "natsrpc.gather",
+		Tag:           "varint,2367,opt,name=gather",
+		Filename:      "natsrpc.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*Concurrency)(nil),
+		Field:         2368,
+		Name:          "natsrpc.methodConcurrency",
+		Tag:           "bytes,2368,opt,name=methodConcurrency",
+		Filename:      "natsrpc.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*Partition)(nil),
+		Field:         2369,
+		Name:          "natsrpc.partition",
+		Tag:           "bytes,2369,opt,name=partition",
+		Filename:      "natsrpc.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         2370,
+		Name:          "natsrpc.partitionKey",
+		Tag:           "varint,2370,opt,name=partitionKey",
+		Filename:      "natsrpc.proto",
+	},
 }
 
 // Extension fields to descriptorpb.ServiceOptions.
@@ -190,18 +528,36 @@ var (
 	E_ServiceQueue = &file_natsrpc_proto_extTypes[0] // service级别queue
 	// optional string topic = 43232;
 	E_Topic = &file_natsrpc_proto_extTypes[1] // topic
+	// optional natsrpc.Concurrency serviceConcurrency = 43233;
+	E_ServiceConcurrency = &file_natsrpc_proto_extTypes[2] // service级别的并发限制，没有单独配置的方法共享
+	// optional natsrpc.JetStream serviceJetStream = 43234;
+	E_ServiceJetStream = &file_natsrpc_proto_extTypes[3] // service级别的JetStream，没有单独配置的无返回值方法都走JetStream
 )
 
 // Extension fields to descriptorpb.MethodOptions.
 var (
 	// optional string methodQueue = 2362;
-	E_MethodQueue = &file_natsrpc_proto_extTypes[2] // 方法级别的queue
+	E_MethodQueue = &file_natsrpc_proto_extTypes[4] // 方法级别的queue
 	// optional int32 reqId = 2363;
-	E_ReqId = &file_natsrpc_proto_extTypes[3] // reqId
+	E_ReqId = &file_natsrpc_proto_extTypes[5] // reqId
 	// optional int32 respId = 2364;
-	E_RespId = &file_natsrpc_proto_extTypes[4] // respId
+	E_RespId = &file_natsrpc_proto_extTypes[6] // respId
 	// optional bool sequence = 2365;
-	E_Sequence = &file_natsrpc_proto_extTypes[5] // sequence
+	E_Sequence = &file_natsrpc_proto_extTypes[7] // sequence
+	// optional natsrpc.JetStream jetstream = 2366;
+	E_Jetstream = &file_natsrpc_proto_extTypes[8] // 走JetStream持久化投递，仅用于无返回值的方法
+	// optional bool gather = 2367;
+	E_Gather = &file_natsrpc_proto_extTypes[9] // 广播给所有实例不走queue，客户端生成XxxGather收集多个回复
+	// optional natsrpc.Concurrency methodConcurrency = 2368;
+	E_MethodConcurrency = &file_natsrpc_proto_extTypes[10] // 方法级别的并发限制
+	// optional natsrpc.Partition partition = 2369;
+	E_Partition = &file_natsrpc_proto_extTypes[11] // 按key分区顺序处理
+)
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// optional bool partitionKey = 2370;
+	E_PartitionKey = &file_natsrpc_proto_extTypes[12] // 分区key字段
 )
 
 var File_natsrpc_proto protoreflect.FileDescriptor
@@ -210,47 +566,120 @@ var file_natsrpc_proto_rawDesc = []byte{
 	0x0a, 0x0d, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
 	0x07, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
 	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69,
-	0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x94, 0x01, 0x0a, 0x07, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
-	0x12, 0x34, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x1c, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06,
-	0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x1a, 0x39, 0x0a, 0x0b, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
-	0x01, 0x22, 0x37, 0x0a, 0x05, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61,
+	0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x7b, 0x0a, 0x09, 0x4a, 0x65,
+	0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12,
+	0x18, 0x0a, 0x07, 0x64, 0x75, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x64, 0x75, 0x72, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x6d, 0x61, 0x78,
+	0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x6d,
+	0x61, 0x78, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x63, 0x6b,
+	0x57, 0x61, 0x69, 0x74, 0x4d, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x61, 0x63,
+	0x6b, 0x57, 0x61, 0x69, 0x74, 0x4d, 0x73, 0x22, 0x94, 0x01, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x63,
+	0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x6d, 0x61, 0x78, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x65, 0x6e,
+	0x64, 0x69, 0x6e, 0x67, 0x4d, 0x73, 0x67, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b,
+	0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x4d, 0x73, 0x67, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x70,
+	0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0c, 0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12,
+	0x2d, 0x0a, 0x08, 0x6f, 0x76, 0x65, 0x72, 0x66, 0x6c, 0x6f, 0x77, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x11, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x4f, 0x76, 0x65, 0x72,
+	0x66, 0x6c, 0x6f, 0x77, 0x52, 0x08, 0x6f, 0x76, 0x65, 0x72, 0x66, 0x6c, 0x6f, 0x77, 0x22, 0x39,
+	0x0a, 0x09, 0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x6c,
+	0x61, 0x6e, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x61, 0x6e, 0x65,
+	0x73, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x22, 0x94, 0x01, 0x0a, 0x07, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12,
+	0x34, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1c, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x68,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x1a, 0x39, 0x0a, 0x0b, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x22, 0xf4, 0x01, 0x0a, 0x05, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61,
 	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79,
 	0x6c, 0x6f, 0x61, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x3a, 0x45, 0x0a, 0x0c, 0x73, 0x65,
-	0x72, 0x76, 0x69, 0x63, 0x65, 0x51, 0x75, 0x65, 0x75, 0x65, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x16,
+	0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x38, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x52, 0x65, 0x70,
+	0x6c, 0x79, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x2a, 0x2b, 0x0a, 0x08, 0x4f, 0x76, 0x65, 0x72, 0x66,
+	0x6c, 0x6f, 0x77, 0x12, 0x09, 0x0a, 0x05, 0x42, 0x4c, 0x4f, 0x43, 0x4b, 0x10, 0x00, 0x12, 0x08,
+	0x0a, 0x04, 0x44, 0x52, 0x4f, 0x50, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x52, 0x45, 0x4a, 0x45,
+	0x43, 0x54, 0x10, 0x02, 0x3a, 0x45, 0x0a, 0x0c, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x51,
+	0x75, 0x65, 0x75, 0x65, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4f, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xdf, 0xd1, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x51, 0x75, 0x65, 0x75, 0x65, 0x3a, 0x37, 0x0a, 0x05, 0x74,
+	0x6f, 0x70, 0x69, 0x63, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4f, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xe0, 0xd1, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74,
+	0x6f, 0x70, 0x69, 0x63, 0x3a, 0x67, 0x0a, 0x12, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x43,
+	0x6f, 0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f,
 	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x65, 0x72,
-	0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xdf, 0xd1, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x51, 0x75, 0x65, 0x75,
-	0x65, 0x3a, 0x37, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f,
-	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x65, 0x72,
-	0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xe0, 0xd1, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x3a, 0x41, 0x0a, 0x0b, 0x6d, 0x65,
-	0x74, 0x68, 0x6f, 0x64, 0x51, 0x75, 0x65, 0x75, 0x65, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
-	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68,
-	0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xba, 0x12, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0b, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x51, 0x75, 0x65, 0x75, 0x65, 0x3a, 0x35, 0x0a,
-	0x05, 0x72, 0x65, 0x71, 0x49, 0x64, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f,
-	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbb, 0x12, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x72,
-	0x65, 0x71, 0x49, 0x64, 0x3a, 0x37, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x70, 0x49, 0x64, 0x12, 0x1e,
+	0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xe1, 0xd1, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x43, 0x6f,
+	0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x12, 0x73, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x43, 0x6f, 0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x3a, 0x61, 0x0a,
+	0x10, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4a, 0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x12, 0x1f, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x18, 0xe2, 0xd1, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6e, 0x61, 0x74,
+	0x73, 0x72, 0x70, 0x63, 0x2e, 0x4a, 0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x10,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4a, 0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x3a, 0x41, 0x0a, 0x0b, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x51, 0x75, 0x65, 0x75, 0x65, 0x12,
+	0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0xba, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x51, 0x75,
+	0x65, 0x75, 0x65, 0x3a, 0x35, 0x0a, 0x05, 0x72, 0x65, 0x71, 0x49, 0x64, 0x12, 0x1e, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d,
+	0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbb, 0x12, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x05, 0x72, 0x65, 0x71, 0x49, 0x64, 0x3a, 0x37, 0x0a, 0x06, 0x72, 0x65,
+	0x73, 0x70, 0x49, 0x64, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbc, 0x12, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x72, 0x65, 0x73,
+	0x70, 0x49, 0x64, 0x3a, 0x3b, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12,
+	0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0xbd, 0x12, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65,
+	0x3a, 0x51, 0x0a, 0x09, 0x6a, 0x65, 0x74, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x1e, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbe, 0x12,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x4a,
+	0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x09, 0x6a, 0x65, 0x74, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x3a, 0x37, 0x0a, 0x06, 0x67, 0x61, 0x74, 0x68, 0x65, 0x72, 0x12, 0x1e, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbf, 0x12,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x67, 0x61, 0x74, 0x68, 0x65, 0x72, 0x3a, 0x63, 0x0a, 0x11,
+	0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x43, 0x6f, 0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
+	0x79, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0xc0, 0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72,
+	0x70, 0x63, 0x2e, 0x43, 0x6f, 0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x52, 0x11,
+	0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x43, 0x6f, 0x6e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
+	0x79, 0x3a, 0x51, 0x0a, 0x09, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1e,
 	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
-	0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbc,
-	0x12, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x72, 0x65, 0x73, 0x70, 0x49, 0x64, 0x3a, 0x3b, 0x0a,
-	0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
-	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68,
-	0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xbd, 0x12, 0x20, 0x01, 0x28, 0x08,
-	0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69,
-	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x69, 0x75, 0x77, 0x61, 0x6e, 0x67,
-	0x63, 0x68, 0x65, 0x6e, 0x2f, 0x74, 0x6f, 0x79, 0x2f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f,
-	0x72, 0x74, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xc1,
+	0x12, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x2e,
+	0x50, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x70, 0x61, 0x72, 0x74, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x3a, 0x42, 0x0a, 0x0c, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x4b, 0x65, 0x79, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x18, 0xc2, 0x12, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x70, 0x61, 0x72, 0x74,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x69, 0x75, 0x77, 0x61, 0x6e, 0x67, 0x63, 0x68,
+	0x65, 0x6e, 0x2f, 0x74, 0x6f, 0x79, 0x2f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74,
+	0x2f, 0x72, 0x70, 0x63, 0x2f, 0x6e, 0x61, 0x74, 0x73, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -265,27 +694,48 @@ func file_natsrpc_proto_rawDescGZIP() []byte {
 	return file_natsrpc_proto_rawDescData
 }
 
-var file_natsrpc_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_natsrpc_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_natsrpc_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_natsrpc_proto_goTypes = []interface{}{
-	(*Request)(nil),                     // 0: natsrpc.Request
-	(*Reply)(nil),                       // 1: natsrpc.Reply
-	nil,                                 // 2: natsrpc.Request.HeaderEntry
-	(*descriptorpb.ServiceOptions)(nil), // 3: google.protobuf.ServiceOptions
-	(*descriptorpb.MethodOptions)(nil),  // 4: google.protobuf.MethodOptions
+	(Overflow)(0),                       // 0: natsrpc.Overflow
+	(*JetStream)(nil),                   // 1: natsrpc.JetStream
+	(*Concurrency)(nil),                 // 2: natsrpc.Concurrency
+	(*Partition)(nil),                   // 3: natsrpc.Partition
+	(*Request)(nil),                     // 4: natsrpc.Request
+	(*Reply)(nil),                       // 5: natsrpc.Reply
+	nil,                                 // 6: natsrpc.Request.HeaderEntry
+	nil,                                 // 7: natsrpc.Reply.MetadataEntry
+	(*descriptorpb.ServiceOptions)(nil), // 8: google.protobuf.ServiceOptions
+	(*descriptorpb.MethodOptions)(nil),  // 9: google.protobuf.MethodOptions
+	(*descriptorpb.FieldOptions)(nil),   // 10: google.protobuf.FieldOptions
 }
 var file_natsrpc_proto_depIdxs = []int32{
-	2, // 0: natsrpc.Request.header:type_name -> natsrpc.Request.HeaderEntry
-	3, // 1: natsrpc.serviceQueue:extendee -> google.protobuf.ServiceOptions
-	3, // 2: natsrpc.topic:extendee -> google.protobuf.ServiceOptions
-	4, // 3: natsrpc.methodQueue:extendee -> google.protobuf.MethodOptions
-	4, // 4: natsrpc.reqId:extendee -> google.protobuf.MethodOptions
-	4, // 5: natsrpc.respId:extendee -> google.protobuf.MethodOptions
-	4, // 6: natsrpc.sequence:extendee -> google.protobuf.MethodOptions
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	1, // [1:7] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: natsrpc.Concurrency.overflow:type_name -> natsrpc.Overflow
+	6,  // 1: natsrpc.Request.header:type_name -> natsrpc.Request.HeaderEntry
+	7,  // 2: natsrpc.Reply.metadata:type_name -> natsrpc.Reply.MetadataEntry
+	8,  // 3: natsrpc.serviceQueue:extendee -> google.protobuf.ServiceOptions
+	8,  // 4: natsrpc.topic:extendee -> google.protobuf.ServiceOptions
+	8,  // 5: natsrpc.serviceConcurrency:extendee -> google.protobuf.ServiceOptions
+	8,  // 6: natsrpc.serviceJetStream:extendee -> google.protobuf.ServiceOptions
+	9,  // 7: natsrpc.methodQueue:extendee -> google.protobuf.MethodOptions
+	9,  // 8: natsrpc.reqId:extendee -> google.protobuf.MethodOptions
+	9,  // 9: natsrpc.respId:extendee -> google.protobuf.MethodOptions
+	9,  // 10: natsrpc.sequence:extendee -> google.protobuf.MethodOptions
+	9,  // 11: natsrpc.jetstream:extendee -> google.protobuf.MethodOptions
+	9,  // 12: natsrpc.gather:extendee -> google.protobuf.MethodOptions
+	9,  // 13: natsrpc.methodConcurrency:extendee -> google.protobuf.MethodOptions
+	9,  // 14: natsrpc.partition:extendee -> google.protobuf.MethodOptions
+	10, // 15: natsrpc.partitionKey:extendee -> google.protobuf.FieldOptions
+	2,  // 16: natsrpc.serviceConcurrency:type_name -> natsrpc.Concurrency
+	1,  // 17: natsrpc.serviceJetStream:type_name -> natsrpc.JetStream
+	1,  // 18: natsrpc.jetstream:type_name -> natsrpc.JetStream
+	2,  // 19: natsrpc.methodConcurrency:type_name -> natsrpc.Concurrency
+	3,  // 20: natsrpc.partition:type_name -> natsrpc.Partition
+	21, // [21:21] is the sub-list for method output_type
+	21, // [21:21] is the sub-list for method input_type
+	16, // [16:21] is the sub-list for extension type_name
+	3,  // [3:16] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_natsrpc_proto_init() }
@@ -295,7 +745,7 @@ func file_natsrpc_proto_init() {
 	}
 	if !protoimpl.UnsafeEnabled {
 		file_natsrpc_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Request); i {
+			switch v := v.(*JetStream); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -307,6 +757,42 @@ func file_natsrpc_proto_init() {
 			}
 		}
 		file_natsrpc_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Concurrency); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_natsrpc_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Partition); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_natsrpc_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Request); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_natsrpc_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Reply); i {
 			case 0:
 				return &v.state
@@ -324,13 +810,14 @@ func file_natsrpc_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_natsrpc_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   3,
-			NumExtensions: 6,
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 13,
 			NumServices:   0,
 		},
 		GoTypes:           file_natsrpc_proto_goTypes,
 		DependencyIndexes: file_natsrpc_proto_depIdxs,
+		EnumInfos:         file_natsrpc_proto_enumTypes,
 		MessageInfos:      file_natsrpc_proto_msgTypes,
 		ExtensionInfos:    file_natsrpc_proto_extTypes,
 	}.Build()
@@ -0,0 +1,157 @@
+package natsrpc
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+)
+
+// laneBuffer 每个lane排队的请求数，满了之后阻塞订阅的投递
+const laneBuffer = 64
+
+// KeyFunc 从header或请求中取分区key
+type KeyFunc func(header map[string]string, req interface{}) string
+
+// HeaderKey 从header取分区key
+// client可以通过metadata中间件传递，如x-md-global-player
+func HeaderKey(name string) KeyFunc {
+	return func(header map[string]string, req interface{}) string {
+		return header[name]
+	}
+}
+
+// WithServiceMethodPartition 方法按key分配到多个lane，同key顺序处理，不同key并行
+func WithServiceMethodPartition(methodPartition map[string]*Partition) ServiceOption {
+	return func(s *service) {
+		s.methodPartition = methodPartition
+	}
+}
+
+// WithServiceMethodKeyFunc 方法的分区key，没有配置时用Partition.Header
+func WithServiceMethodKeyFunc(methodKeyFunc map[string]KeyFunc) ServiceOption {
+	return func(s *service) {
+		s.methodKeyFunc = methodKeyFunc
+	}
+}
+
+// WithServiceHashFunc key到lane的hash，默认crc32
+func WithServiceHashFunc(hash func(key string, n int) int) ServiceOption {
+	return func(s *service) {
+		s.hash = hash
+	}
+}
+
+func defaultHash(key string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	return int(crc32.ChecksumIEEE([]byte(key)) % uint32(n))
+}
+
+// lanesOf 方法的lanes和取key的方法，没有分区时返回nil
+func (s *service) lanesOf(methodName string) (*lanes, KeyFunc, error) {
+	p, ok := s.methodPartition[methodName]
+	if !ok {
+		return nil, nil, nil
+	}
+	if p.Lanes <= 0 {
+		return nil, nil, fmt.Errorf("method %s partition lanes must be positive", methodName)
+	}
+	if s.methodSequenceHandle[methodName] {
+		return nil, nil, fmt.Errorf("method %s can not be both sequence and partition", methodName)
+	}
+	keyFunc := s.methodKeyFunc[methodName]
+	if keyFunc == nil {
+		if len(p.Header) == 0 {
+			return nil, nil, fmt.Errorf("method %s partition has no key", methodName)
+		}
+		keyFunc = HeaderKey(p.Header)
+	}
+	hash := s.hash
+	if hash == nil {
+		hash = defaultHash
+	}
+	l := newLanes(int(p.Lanes), hash)
+	s.lanes = append(s.lanes, l)
+	return l, keyFunc, nil
+}
+
+// closeLanes 关闭所有lanes，已排队的请求会继续处理完
+func (s *service) closeLanes() {
+	for _, l := range s.lanes {
+		l.close()
+	}
+	s.lanes = nil
+}
+
+// lanes 每个lane一个goroutine顺序执行
+type lanes struct {
+	chs     []chan func()
+	hash    func(key string, n int) int
+	next    uint32 // 没有key的请求轮询分配
+	mu      sync.RWMutex
+	closed  bool
+	done    chan struct{}  // 关闭后阻塞的dispatch返回
+	senders sync.WaitGroup // 正在投递的dispatch
+}
+
+func newLanes(n int, hash func(key string, n int) int) *lanes {
+	l := &lanes{
+		chs:  make([]chan func(), n),
+		hash: hash,
+		done: make(chan struct{}),
+	}
+	for i := range l.chs {
+		l.chs[i] = make(chan func(), laneBuffer)
+		go l.run(l.chs[i])
+	}
+	return l
+}
+
+func (l *lanes) run(ch chan func()) {
+	for task := range ch {
+		task()
+	}
+}
+
+// dispatch 同key的请求总是落在同一个lane，lane满时阻塞，已关闭返回false
+// 阻塞时不持有锁，close不会被满的lane卡住
+func (l *lanes) dispatch(key string, task func()) bool {
+	l.mu.RLock()
+	if l.closed {
+		l.mu.RUnlock()
+		return false
+	}
+	l.senders.Add(1)
+	l.mu.RUnlock()
+	defer l.senders.Done()
+	var i int
+	if len(key) == 0 {
+		i = int(atomic.AddUint32(&l.next, 1) % uint32(len(l.chs)))
+	} else {
+		i = l.hash(key, len(l.chs))
+	}
+	select {
+	case l.chs[i] <- task:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// close 等正在投递的dispatch返回后关闭lane，已排队的请求继续处理完
+func (l *lanes) close() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	close(l.done)
+	l.mu.Unlock()
+	l.senders.Wait()
+	for _, ch := range l.chs {
+		close(ch)
+	}
+}
@@ -0,0 +1,209 @@
+package natsrpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Player 请求格式 key:seq
+type Player struct {
+	mu      sync.Mutex
+	seqs    map[string][]string
+	running int32
+	max     int32
+	done    chan struct{}
+	total   int32
+}
+
+func newPlayer(total int32) *Player {
+	return &Player{seqs: map[string][]string{}, done: make(chan struct{}), total: total}
+}
+
+func (p *Player) Move(ctx context.Context, req *wrapperspb.StringValue) error {
+	n := atomic.AddInt32(&p.running, 1)
+	for {
+		m := atomic.LoadInt32(&p.max)
+		if n <= m || atomic.CompareAndSwapInt32(&p.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	atomic.AddInt32(&p.running, -1)
+
+	parts := strings.SplitN(req.Value, ":", 2)
+	p.mu.Lock()
+	p.seqs[parts[0]] = append(p.seqs[parts[0]], parts[1])
+	p.total--
+	if p.total == 0 {
+		close(p.done)
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+func playerKey(header map[string]string, req interface{}) string {
+	return strings.SplitN(req.(*wrapperspb.StringValue).Value, ":", 2)[0]
+}
+
+func startPlayer(t *testing.T, url string, p *Player, opts ...ServiceOption) {
+	t.Helper()
+	conn, err := NewServerConn(WithAddr(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Player", p, opts...); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = conn.Close(context.Background())
+	})
+}
+
+func checkOrdered(t *testing.T, p *Player, keys []string, count int) {
+	t.Helper()
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect all handled")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range keys {
+		seq := p.seqs[key]
+		if len(seq) != count {
+			t.Fatalf("%s: expect %d, got %d", key, count, len(seq))
+		}
+		for i, v := range seq {
+			if v != fmt.Sprint(i) {
+				t.Fatalf("%s: out of order %v", key, seq)
+			}
+		}
+	}
+}
+
+func TestPartitionKeyFunc(t *testing.T) {
+	ns := runNatsServer(t)
+	keys := []string{"a", "b"}
+	const count = 50
+	p := newPlayer(int32(len(keys) * count))
+	startPlayer(t, ns.ClientURL(), p,
+		WithServiceMethodPartition(map[string]*Partition{"Move": {Lanes: 2}}),
+		WithServiceMethodKeyFunc(map[string]KeyFunc{"Move": playerKey}),
+		// a,b分到不同的lane
+		WithServiceHashFunc(func(key string, n int) int {
+			return int(key[0]-'a') % n
+		}),
+	)
+	c := newStreamClient(t, ns.ClientURL())
+	for i := 0; i < count; i++ {
+		for _, key := range keys {
+			if err := c.Publish(context.Background(), "test.Player", "Move", wrapperspb.String(fmt.Sprintf("%s:%d", key, i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	checkOrdered(t, p, keys, count)
+	// 不同key并行
+	if n := atomic.LoadInt32(&p.max); n != 2 {
+		t.Fatalf("expect 2 lanes in parallel, got %d", n)
+	}
+}
+
+func TestPartitionHeader(t *testing.T) {
+	ns := runNatsServer(t)
+	keys := []string{"a", "b", "c"}
+	const count = 20
+	p := newPlayer(int32(len(keys) * count))
+	startPlayer(t, ns.ClientURL(), p, WithServiceMethodPartition(map[string]*Partition{"Move": {Lanes: 4, Header: "x-player"}}))
+	c := newStreamClient(t, ns.ClientURL(), WithConnMiddleware(func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			if tr, ok := rpc.FromClientContext(ctx); ok {
+				tr.RequestHeader().Set("x-player", playerKey(nil, req))
+			}
+			return handler(ctx, req)
+		}
+	}))
+	for i := 0; i < count; i++ {
+		for _, key := range keys {
+			if err := c.Publish(context.Background(), "test.Player", "Move", wrapperspb.String(fmt.Sprintf("%s:%d", key, i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	checkOrdered(t, p, keys, count)
+}
+
+func TestPartitionInvalid(t *testing.T) {
+	ns := runNatsServer(t)
+	tests := []ServiceOption{
+		WithServiceMethodPartition(map[string]*Partition{"Move": {Lanes: 2}}),
+		WithServiceMethodPartition(map[string]*Partition{"Move": {Header: "x-player"}}),
+	}
+	for i, opt := range tests {
+		conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Register("test.Player", newPlayer(0), opt); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Start(context.Background()); err == nil {
+			t.Fatalf("case %d: expect invalid partition error", i)
+		}
+		_ = conn.Close(context.Background())
+	}
+}
+
+func TestLanesCloseWhileFull(t *testing.T) {
+	l := newLanes(1, defaultHash)
+	block := make(chan struct{})
+	l.dispatch("", func() { <-block })
+	for i := 0; i < laneBuffer; i++ {
+		l.dispatch("", func() {})
+	}
+	// lane已满，dispatch阻塞
+	blocked := make(chan bool)
+	go func() {
+		blocked <- l.dispatch("", func() {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		l.close()
+		close(closed)
+	}()
+	select {
+	case ok := <-blocked:
+		if ok {
+			t.Fatal("expect dispatch rejected after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dispatch still blocked after close")
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close blocked by full lane")
+	}
+	close(block)
+	if l.dispatch("", func() {}) {
+		t.Fatal("expect dispatch rejected after close")
+	}
+}
@@ -0,0 +1,107 @@
+package natsrpc
+
+import (
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/nats-io/nats.go"
+	"github.com/panjf2000/ants/v2"
+)
+
+// WithServiceConcurrency service级别的并发限制，没有单独配置的方法共享同一个pool
+func WithServiceConcurrency(concurrency *Concurrency) ServiceOption {
+	return func(s *service) {
+		s.concurrency = concurrency
+	}
+}
+
+// WithServiceMethodConcurrency 方法级别的并发限制，每个方法独立一个pool
+func WithServiceMethodConcurrency(methodConcurrency map[string]*Concurrency) ServiceOption {
+	return func(s *service) {
+		s.methodConcurrency = methodConcurrency
+	}
+}
+
+// concurrencyOf 方法的并发配置，优先用方法级别的
+func (s *service) concurrencyOf(methodName string) *Concurrency {
+	if c, ok := s.methodConcurrency[methodName]; ok {
+		return c
+	}
+	return s.concurrency
+}
+
+// poolOf 方法的pool，没有并发限制时返回nil
+func (s *service) poolOf(methodName string) (*ants.Pool, error) {
+	c := s.concurrencyOf(methodName)
+	if c == nil || c.Max <= 0 {
+		return nil, nil
+	}
+	if _, ok := s.methodConcurrency[methodName]; !ok {
+		// service级别共享
+		if s.pool == nil {
+			p, err := newPool(c)
+			if err != nil {
+				return nil, err
+			}
+			s.pool = p
+			s.pools = append(s.pools, p)
+		}
+		return s.pool, nil
+	}
+	p, err := newPool(c)
+	if err != nil {
+		return nil, err
+	}
+	s.pools = append(s.pools, p)
+	return p, nil
+}
+
+// releasePools 释放所有pool，已提交的任务会继续执行完
+func (s *service) releasePools() {
+	for _, p := range s.pools {
+		p.Release()
+	}
+	s.pools = nil
+	s.pool = nil
+}
+
+func newPool(c *Concurrency) (*ants.Pool, error) {
+	return ants.NewPool(int(c.Max), ants.WithNonblocking(c.Overflow != Overflow_BLOCK))
+}
+
+// setPendingLimits 设置订阅缓存上限
+func setPendingLimits(sub *nats.Subscription, c *Concurrency) error {
+	if c == nil || (c.PendingMsgs == 0 && c.PendingBytes == 0) {
+		return nil
+	}
+	msgs, bytes := int(c.PendingMsgs), int(c.PendingBytes)
+	if msgs == 0 {
+		msgs = nats.DefaultSubPendingMsgsLimit
+	}
+	if bytes == 0 {
+		bytes = nats.DefaultSubPendingBytesLimit
+	}
+	return sub.SetPendingLimits(msgs, bytes)
+}
+
+// overflow pool满时按策略处理msg，返回的错误交给errorHandler
+func (s *ServerConn) overflow(service *service, m *method, c *Concurrency, msg *nats.Msg) error {
+	var err error
+	if c.Overflow == Overflow_REJECT {
+		err = errors.ServiceUnavailable("OVERLOAD", "service "+service.serviceName+" overload")
+	} else {
+		err = errors.ResourceExhausted("OVERLOAD", "service "+service.serviceName+" overload")
+	}
+	if js := service.jetStreamOf(m.name); js != nil {
+		if c.Overflow == Overflow_REJECT {
+			_ = msg.Nak()
+		} else {
+			_ = msg.Term()
+		}
+		return err
+	}
+	if !m.isPublish && len(msg.Reply) > 0 {
+		if err1 := s.reply(service, m, msg, newErrorReply(err), nil); err1 != nil {
+			return err1
+		}
+	}
+	return err
+}
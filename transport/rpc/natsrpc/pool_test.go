@@ -0,0 +1,155 @@
+package natsrpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type Worker struct {
+	running int32
+	max     int32
+	gate    chan struct{}
+}
+
+func (w *Worker) work() {
+	n := atomic.AddInt32(&w.running, 1)
+	for {
+		m := atomic.LoadInt32(&w.max)
+		if n <= m || atomic.CompareAndSwapInt32(&w.max, m, n) {
+			break
+		}
+	}
+	<-w.gate
+	atomic.AddInt32(&w.running, -1)
+}
+
+func (w *Worker) Work(ctx context.Context, req *wrapperspb.Int64Value) (*wrapperspb.Int64Value, error) {
+	w.work()
+	return req, nil
+}
+
+func (w *Worker) Other(ctx context.Context, req *wrapperspb.Int64Value) (*wrapperspb.Int64Value, error) {
+	w.work()
+	return req, nil
+}
+
+func startWorker(t *testing.T, url string, w *Worker, opts ...ServiceOption) *ServerConn {
+	t.Helper()
+	conn, err := NewServerConn(WithAddr(url), WithServerErrorHandler(func(ServerConnMetadata, error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Worker", w, opts...); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() {
+		cancel()
+		closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer closeCancel()
+		_ = conn.Close(closeCtx)
+	})
+	return conn
+}
+
+func TestConcurrencyBlock(t *testing.T) {
+	ns := runNatsServer(t)
+	w := &Worker{gate: make(chan struct{})}
+	startWorker(t, ns.ClientURL(), w, WithServiceMethodConcurrency(map[string]*Concurrency{"Work": {Max: 2}}))
+	c := newStreamClient(t, ns.ClientURL())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Request(context.Background(), "test.Worker", "Work", wrapperspb.Int64(0), new(wrapperspb.Int64Value))
+		}()
+	}
+	// 超过max的请求排队等待
+	for atomic.LoadInt32(&w.running) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(w.gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&w.max); n != 2 {
+		t.Fatalf("expect max 2 running, got %d", n)
+	}
+}
+
+func TestConcurrencyOverflow(t *testing.T) {
+	tests := []struct {
+		overflow Overflow
+		code     int
+	}{
+		{Overflow_DROP, 429},
+		{Overflow_REJECT, 503},
+	}
+	for _, tt := range tests {
+		ns := runNatsServer(t)
+		w := &Worker{gate: make(chan struct{})}
+		// service级别的pool由两个方法共享
+		startWorker(t, ns.ClientURL(), w, WithServiceConcurrency(&Concurrency{Max: 1, Overflow: tt.overflow}))
+		c := newStreamClient(t, ns.ClientURL())
+
+		done := make(chan error, 1)
+		go func() {
+			done <- c.Request(context.Background(), "test.Worker", "Work", wrapperspb.Int64(0), new(wrapperspb.Int64Value))
+		}()
+		for atomic.LoadInt32(&w.running) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		err := c.Request(context.Background(), "test.Worker", "Other", wrapperspb.Int64(0), new(wrapperspb.Int64Value))
+		if errors.Code(err) != tt.code || errors.Reason(err) != "OVERLOAD" {
+			t.Fatalf("%s: expect %d OVERLOAD, got %v", tt.overflow, tt.code, err)
+		}
+		close(w.gate)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrencyPendingLimits(t *testing.T) {
+	ns := runNatsServer(t)
+	w := &Worker{gate: make(chan struct{})}
+	conn := startWorker(t, ns.ClientURL(), w, WithServiceMethodConcurrency(map[string]*Concurrency{"Work": {PendingMsgs: 10}}))
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	for _, subs := range conn.services {
+		for _, sub := range subs {
+			msgs, bytes, err := sub.PendingLimits()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := nats.DefaultSubPendingMsgsLimit
+			if sub.Subject == "test.Worker.Work" {
+				expect = 10
+			}
+			if msgs != expect || bytes != nats.DefaultSubPendingBytesLimit {
+				t.Fatalf("%s: unexpected pending limits %d %d", sub.Subject, msgs, bytes)
+			}
+		}
+	}
+}
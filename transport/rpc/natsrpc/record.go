@@ -0,0 +1,381 @@
+package natsrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/encoding"
+	jsonCodec "github.com/liuwangchen/toy/transport/encoding/json"
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	RecordSideServer = "server"
+	RecordSideClient = "client"
+)
+
+// Record 一次调用在线上的记录
+// Payload和ReplyPayload是原始编码，用于重放；Request和Reply是json，方便查看，调用Resolve后填充
+type Record struct {
+	Time         time.Time         `json:"time"`
+	Side         string            `json:"side"`
+	Subject      string            `json:"subject"`
+	Service      string            `json:"service"`
+	Method       string            `json:"method"`
+	IsPublish    bool              `json:"isPublish,omitempty"`
+	Header       map[string]string `json:"header,omitempty"`
+	Request      json.RawMessage   `json:"request,omitempty"`
+	Reply        json.RawMessage   `json:"reply,omitempty"`
+	Payload      []byte            `json:"payload,omitempty"`
+	ReplyPayload []byte            `json:"replyPayload,omitempty"`
+	Latency      time.Duration     `json:"latency"`
+	Code         int32             `json:"code,omitempty"`
+	Error        string            `json:"error,omitempty"`
+
+	enc         nats.Encoder
+	reqType     reflect.Type
+	replyType   reflect.Type
+	resolveOnce *sync.Once
+}
+
+// Recorder 记录的输出
+// Record在请求的goroutine中同步调用，实现不能阻塞
+type Recorder interface {
+	Record(r *Record)
+}
+
+// WithRecorder 记录经过conn的请求和回复
+func WithRecorder(recorder Recorder) Option {
+	return func(s ISetOption) {
+		switch c := s.(type) {
+		case *ServerConn:
+			c.recorder = recorder
+		case *ClientConn:
+			c.recorder = recorder
+		}
+	}
+}
+
+// newRecord 构造记录，payload使用调用过程中已经编码好的数据，json延迟到Resolve
+func newRecord(side, subject, service, method string, isPublish bool, header map[string]string, enc nats.Encoder, req, reply interface{}, payload, replyPayload []byte, start time.Time, err error) *Record {
+	r := &Record{
+		Time:      start,
+		Side:      side,
+		Subject:   subject,
+		Service:   service,
+		Method:    method,
+		IsPublish: isPublish,
+		Latency:   time.Since(start),
+		Payload:   payload,
+
+		enc:         enc,
+		resolveOnce: &sync.Once{},
+	}
+	if len(header) > 0 {
+		r.Header = make(map[string]string, len(header))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+	}
+	if req != nil {
+		r.reqType = reflect.TypeOf(req)
+	}
+	if reply != nil && err == nil {
+		r.ReplyPayload = replyPayload
+		r.replyType = reflect.TypeOf(reply)
+	}
+	if err != nil {
+		r.Code = errors.FromError(err).Code
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// Resolve 从原始编码解出Request和Reply的json，解码失败的字段留空
+func (r *Record) Resolve() {
+	if r.resolveOnce == nil {
+		return
+	}
+	r.resolveOnce.Do(func() {
+		if r.Request == nil {
+			r.Request = r.decodeJSON(r.reqType, r.Payload)
+		}
+		if r.Reply == nil {
+			r.Reply = r.decodeJSON(r.replyType, r.ReplyPayload)
+		}
+	})
+}
+
+func (r *Record) decodeJSON(typ reflect.Type, data []byte) json.RawMessage {
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return nil
+	}
+	v := reflect.New(typ.Elem()).Interface()
+	if err := r.enc.Decode(r.Subject, data, v); err != nil {
+		return nil
+	}
+	return jsonOf(v)
+}
+
+func jsonOf(v interface{}) json.RawMessage {
+	b, err := encoding.GetCodec(jsonCodec.Name).Marshal(v)
+	if err != nil || !json.Valid(b) {
+		return nil
+	}
+	return b
+}
+
+const (
+	// fileRecordQueue FileRecorder排队的记录数，满了之后丢弃
+	fileRecordQueue = 1024
+)
+
+// FileRecorder 按json行写入文件
+// 记录进入有界队列，由单独的goroutine编码写入，队列空闲时flush
+type FileRecorder struct {
+	f       *os.File
+	w       *bufio.Writer
+	queue   chan *Record
+	flush   chan chan error
+	stop    chan struct{}
+	exited  chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped int64
+	err     error
+}
+
+// NewFileRecorder 追加写入path
+func NewFileRecorder(path string) (*FileRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &FileRecorder{
+		f:      f,
+		w:      bufio.NewWriter(f),
+		queue:  make(chan *Record, fileRecordQueue),
+		flush:  make(chan chan error),
+		stop:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Record 入队，队列满或已关闭时丢弃
+func (r *FileRecorder) Record(record *Record) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- record:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Dropped 因为队列满丢弃的记录数
+func (r *FileRecorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+func (r *FileRecorder) run() {
+	defer close(r.exited)
+	for {
+		select {
+		case record := <-r.queue:
+			r.write(record)
+			if len(r.queue) == 0 {
+				r.setErr(r.w.Flush())
+			}
+		case ch := <-r.flush:
+			r.drain()
+			ch <- r.w.Flush()
+		case <-r.stop:
+			r.drain()
+			r.setErr(r.w.Flush())
+			return
+		}
+	}
+}
+
+// drain 写入已经排队的记录
+func (r *FileRecorder) drain() {
+	for {
+		select {
+		case record := <-r.queue:
+			r.write(record)
+		default:
+			return
+		}
+	}
+}
+
+func (r *FileRecorder) write(record *Record) {
+	record.Resolve()
+	b, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	_, err = r.w.Write(append(b, '\n'))
+	r.setErr(err)
+}
+
+// setErr 保留第一个写入错误，Close时返回
+func (r *FileRecorder) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Flush 写入已经排队的记录
+func (r *FileRecorder) Flush() error {
+	ch := make(chan error, 1)
+	select {
+	case r.flush <- ch:
+		return <-ch
+	case <-r.exited:
+		return nil
+	}
+}
+
+// Close 写入已经排队的记录并关闭文件
+func (r *FileRecorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+	close(r.stop)
+	<-r.exited
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// RingRecorder 内存中保留最近n条
+type RingRecorder struct {
+	mu      sync.Mutex
+	records []*Record
+	next    int
+	full    bool
+}
+
+func NewRingRecorder(n int) *RingRecorder {
+	return &RingRecorder{records: make([]*Record, n)}
+}
+
+func (r *RingRecorder) Record(record *Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		return
+	}
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Records 按时间顺序返回
+func (r *RingRecorder) Records() []*Record {
+	r.mu.Lock()
+	var records []*Record
+	if !r.full {
+		records = append(records, r.records[:r.next]...)
+	} else {
+		records = append(append(records, r.records[r.next:]...), r.records[:r.next]...)
+	}
+	r.mu.Unlock()
+	for _, record := range records {
+		record.Resolve()
+	}
+	return records
+}
+
+// ReadRecords 读取FileRecorder写入的记录
+func ReadRecords(reader io.Reader) ([]*Record, error) {
+	records := make([]*Record, 0)
+	dec := json.NewDecoder(reader)
+	for {
+		r := &Record{}
+		if err := dec.Decode(r); err != nil {
+			if err == io.EOF {
+				return records, nil
+			}
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
+
+// Replay 重新发送记录的请求，返回这次的记录
+// 记录中的超时时间按ctx重新设置
+func (c *ClientConn) Replay(ctx context.Context, record *Record) *Record {
+	header := make(map[string]string, len(record.Header))
+	for k, v := range record.Header {
+		header[k] = v
+	}
+	delete(header, headerTimeout)
+
+	r := &Record{
+		Time:      time.Now(),
+		Side:      RecordSideClient,
+		Subject:   record.Subject,
+		Service:   record.Service,
+		Method:    record.Method,
+		IsPublish: record.IsPublish,
+		Header:    header,
+		Request:   record.Request,
+		Payload:   record.Payload,
+	}
+	setErr := func(err error) *Record {
+		r.Latency = time.Since(r.Time)
+		r.Code = errors.FromError(err).Code
+		r.Error = err.Error()
+		return r
+	}
+	if record.IsPublish {
+		if err := c.enc.Publish(record.Subject, NewRequest(record.Payload, header)); err != nil {
+			return setErr(err)
+		}
+		r.Latency = time.Since(r.Time)
+		return r
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		setDeadlineIntoHeader(header, deadline)
+	}
+	rp := &Reply{}
+	if err := c.enc.RequestWithContext(ctx, record.Subject, NewRequest(record.Payload, header), rp); err != nil {
+		if err == nats.ErrNoResponders {
+			err = errors.ServiceUnavailable("NO_RESPONDERS", "no responders on "+record.Subject)
+		}
+		return setErr(err)
+	}
+	if err := replyError(rp); err != nil {
+		return setErr(err)
+	}
+	r.ReplyPayload = rp.Payload
+	r.Latency = time.Since(r.Time)
+	return r
+}
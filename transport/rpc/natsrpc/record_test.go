@@ -0,0 +1,128 @@
+package natsrpc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ns := runNatsServer(t)
+	w := &Worker{gate: make(chan struct{})}
+	close(w.gate)
+	ring := NewRingRecorder(1)
+	conn, err := NewServerConn(WithAddr(ns.ClientURL()), WithRecorder(ring))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Worker", w); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	defer conn.Close(context.Background())
+
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	fr, err := NewFileRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newStreamClient(t, ns.ClientURL(), WithRecorder(fr))
+	for i := int64(1); i <= 2; i++ {
+		if err := c.Request(context.Background(), "test.Worker", "Work", wrapperspb.Int64(i), new(wrapperspb.Int64Value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ring只保留最后一条
+	rs := ring.Records()
+	if len(rs) != 1 || rs[0].Side != RecordSideServer || string(rs[0].Request) != `"2"` || string(rs[0].Reply) != `"2"` {
+		t.Fatalf("unexpected server records %+v", rs)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := ReadRecords(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expect 2 records, got %d", len(records))
+	}
+	for _, record := range records {
+		if record.Side != RecordSideClient || record.Subject != "test.Worker.Work" || record.Method != "Work" || record.Error != "" {
+			t.Fatalf("unexpected client record %+v", record)
+		}
+		if _, ok := record.Header[headerTimeout]; !ok {
+			t.Fatalf("expect deadline in header %+v", record.Header)
+		}
+		result := c.conn.Replay(context.Background(), record)
+		if result.Error != "" || !bytes.Equal(result.ReplyPayload, record.ReplyPayload) {
+			t.Fatalf("replay mismatch %+v", result)
+		}
+	}
+	// 重放的请求也经过server记录
+	if rs := ring.Records(); string(rs[0].Request) != `"2"` || rs[0].Time.Before(records[1].Time) {
+		t.Fatalf("expect replayed request recorded, got %+v", rs)
+	}
+
+	// 没有responder
+	record := *records[0]
+	record.Subject = "test.Worker.None"
+	if result := c.conn.Replay(context.Background(), &record); result.Code != 503 {
+		t.Fatalf("expect 503, got %+v", result)
+	}
+}
+
+func TestFileRecorderFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	fr, err := NewFileRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Close()
+	enc := nats.EncoderForType(PROTOBUF_ENCODER)
+	payload, _ := enc.Encode("test", wrapperspb.Int64(1))
+	fr.Record(newRecord(RecordSideClient, "test", "test.Worker", "Work", false, nil, enc, new(wrapperspb.Int64Value), nil, payload, nil, time.Now(), nil))
+	// 不调用Flush，队列空闲后写入文件
+	deadline := time.Now().Add(time.Second)
+	for {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := ReadRecords(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) == 1 {
+			if string(records[0].Request) != `"1"` {
+				t.Fatalf("unexpected record %+v", records[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect record flushed without Flush")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,76 @@
+package natsrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type Failer struct{}
+
+func (f *Failer) Fail(ctx context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return nil, errors.BadRequest("BAD_NAME", "bad name").WithMetadata(map[string]string{"name": req.Value})
+}
+
+func TestReplyRichError(t *testing.T) {
+	ns := runNatsServer(t)
+	conn, err := NewServerConn(WithAddr(ns.ClientURL()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Failer", &Failer{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	defer conn.Close(context.Background())
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	c := newStreamClient(t, ns.ClientURL())
+	err = c.Request(context.Background(), "test.Failer", "Fail", wrapperspb.String("toy"), new(wrapperspb.StringValue))
+	se := errors.FromError(err)
+	if se.Code != 400 || se.Reason != "BAD_NAME" || se.Message != "bad name" || se.Metadata["name"] != "toy" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !errors.Is(err, errors.BadRequest("BAD_NAME", "")) {
+		t.Fatalf("expect errors.Is BAD_NAME, got %v", err)
+	}
+}
+
+func TestReplyErrorCompatible(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  *Reply
+		code   int32
+		reason string
+	}{
+		{"ok", &Reply{}, 0, ""},
+		{"fields", newErrorReply(errors.NotFound("MISSING", "missing")), 404, "MISSING"},
+		{"string only", &Reply{Error: "boom"}, errors.UnknownCode, errors.UnknownReason},
+	}
+	for _, tt := range tests {
+		err := replyError(tt.reply)
+		if tt.code == 0 {
+			if err != nil {
+				t.Fatalf("%s: expect nil, got %v", tt.name, err)
+			}
+			continue
+		}
+		se := errors.FromError(err)
+		if se.Code != tt.code || se.Reason != tt.reason {
+			t.Fatalf("%s: expect %d %s, got %v", tt.name, tt.code, tt.reason, err)
+		}
+	}
+	// 只认字符串的老版本client拿到的仍是完整错误
+	if rp := newErrorReply(errors.NotFound("MISSING", "missing")); len(rp.Error) == 0 || len(rp.Payload) != 0 {
+		t.Fatalf("expect error string kept, got %+v", rp)
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/liuwangchen/toy/pkg/endpoint"
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
 	"github.com/nats-io/nats.go"
+	"github.com/panjf2000/ants/v2"
 )
 
 // WithServerErrorHandler error handler
@@ -28,6 +30,7 @@ func WithServerErrorHandler(h func(metadata ServerConnMetadata, err error)) Opti
 
 // ServerConn RPC conn
 type ServerConn struct {
+	shedCount   uint64 // 丢弃的过期请求数，放在首位保证64位对齐
 	address     string
 	encType     string
 	conn        *nats.Conn
@@ -44,6 +47,13 @@ type ServerConn struct {
 	endpoint         *url.URL
 	ready            bool
 	isSelfCreateConn bool
+	streamWindow     int                  // 流的接收窗口
+	streamKeepalive  time.Duration        // 流的心跳间隔
+	streamCtx        context.Context      // 流的base context，Close超时后取消
+	streamCancel     context.CancelFunc   // 取消所有流
+	recorder         Recorder             // 记录请求和回复
+	srvSubs          []*nats.Subscription // 探活和自省的订阅
+	started          time.Time
 }
 
 var _ ISetOption = (*ServerConn)(nil)
@@ -56,8 +66,10 @@ func NewServerConn(option ...Option) (*ServerConn, error) {
 		errorHandler: func(metadata ServerConnMetadata, err error) {
 			logger.ErrorW("ServerConn.handle error", "serviceName", metadata.ServiceName, "methodName", metadata.MethodName, "subject", metadata.MethodSubject, "err", err.Error())
 		},
-		timeout: time.Duration(3) * time.Second,
+		timeout:         time.Duration(3) * time.Second,
+		streamKeepalive: defaultStreamKeepalive,
 	}
+	s.streamCtx, s.streamCancel = context.WithCancel(context.Background())
 	for _, v := range option {
 		v(s)
 	}
@@ -113,20 +125,38 @@ func (s *ServerConn) SetTimeout(timeout time.Duration) {
 	s.timeout = timeout
 }
 
-// Close 关闭
+// Close 关闭，drain订阅后等待处理中的请求完成，ctx超时则直接退出
 func (s *ServerConn) Close(ctx context.Context) (err error) {
-	s.ClearAllSubscription()
+	// 先drain订阅，不再接收新请求，队列里的其他节点接管，已投递的请求继续处理
+	s.mu.Lock()
+	s.ready = false
+	s.unsubscribeIntrospection()
+	subs := make([]*nats.Subscription, 0)
+	for _, v := range s.services {
+		subs = append(subs, v...)
+	}
+	s.mu.Unlock()
+	for _, sub := range subs {
+		_ = sub.Drain()
+	}
 
-	over := make(chan struct{})
-	go func() {
-		s.wg.Wait()
-		close(over)
-	}()
-	select {
-	case <-ctx.Done():
-		err = ctx.Err()
-	case <-over:
+	err = waitDrained(ctx, subs)
+	if err == nil {
+		over := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(over)
+		}()
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-over:
+		}
 	}
+	// 流在等待期间可以正常结束，超时后再取消
+	s.streamCancel()
+	// 超时后剩下的请求直接丢弃
+	s.ClearAllSubscription()
 	if err1 := s.enc.Flush(); err == nil && err1 != nil {
 		err = err1
 	}
@@ -136,6 +166,29 @@ func (s *ServerConn) Close(ctx context.Context) (err error) {
 	return
 }
 
+// waitDrained 等待所有订阅drain完成
+func waitDrained(ctx context.Context, subs []*nats.Subscription) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		drained := true
+		for _, sub := range subs {
+			if sub.IsValid() {
+				drained = false
+				break
+			}
+		}
+		if drained {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 // ClearAllSubscription 取消所有订阅
 func (s *ServerConn) ClearAllSubscription() {
 	s.mu.Lock()
@@ -160,6 +213,8 @@ func (s *ServerConn) remove(service *service) bool {
 			v.Unsubscribe()
 		}
 		delete(s.services, service)
+		service.releasePools()
+		service.closeLanes()
 	}
 	return ok
 }
@@ -193,19 +248,14 @@ func (s *ServerConn) subscribeMethod(service *service) error {
 	// 订阅
 	for methodName, v := range service.methods {
 		m := v
-		methodNameTemp := methodName
-		reqRspIds, ok := service.methodReqRspIds[methodName]
-		if ok {
-			methodNameTemp = fmt.Sprint(reqRspIds[0])
-		}
-		methodSub := CombineStr(s.namespace, service.serviceName, methodNameTemp)
+		reqRspIds := service.methodReqRspIds[methodName]
+		methodSub := service.methodSubject(s.namespace, methodName)
 
 		// 主题
 		subject := CombineStr(methodSub, service.topic)
 
 		// 重复sub判断
-		_, ok = dup[subject]
-		if ok {
+		if _, ok := dup[subject]; ok {
 			return fmt.Errorf("dup subject %s", subject)
 		}
 		dup[subject] = struct{}{}
@@ -219,83 +269,240 @@ func (s *ServerConn) subscribeMethod(service *service) error {
 		// 是否顺序处理msg
 		isSequenceHandleMsg := service.methodSequenceHandle[methodName]
 
-		// 订阅
-		natsSub, subErr := s.enc.QueueSubscribe(subject, queue, func(msg *nats.Msg) {
-			cb := func() {
-				serverConnMetadata := ServerConnMetadata{
-					Namespace:         s.namespace,
-					ServiceName:       service.serviceName,
-					ServiceSimpleName: service.serviceName,
-					MethodSubject:     methodSub,
-					IsPublish:         m.isPublish,
-					ReqType:           m.reqType,
-					RspType:           m.respType,
-					ReplySub:          msg.Reply,
-					Encoder:           s.enc.Enc,
-					MethodName:        m.name,
-					ReqRspIds:         reqRspIds,
-				}
+		// 按key分区
+		lns, keyFunc, err := service.lanesOf(methodName)
+		if err != nil {
+			return err
+		}
+
+		// 并发限制，顺序处理和分区的方法不需要
+		concurrency := service.concurrencyOf(methodName)
+		var pool *ants.Pool
+		if !isSequenceHandleMsg && lns == nil {
+			p, err := service.poolOf(methodName)
+			if err != nil {
+				return err
+			}
+			pool = p
+		}
+
+		cb := func(msg *nats.Msg) {
+			// 收到消息的时间，调用方的超时从这里开始算
+			received := time.Now()
+			serverConnMetadata := ServerConnMetadata{
+				Namespace:         s.namespace,
+				ServiceName:       service.serviceName,
+				ServiceSimpleName: service.serviceName,
+				MethodSubject:     methodSub,
+				IsPublish:         m.isPublish,
+				ReqType:           m.reqType,
+				RspType:           m.respType,
+				ReplySub:          msg.Reply,
+				Encoder:           s.enc.Enc,
+				MethodName:        m.name,
+				ReqRspIds:         reqRspIds,
+			}
+			handle := func() {
 				ctx := s.withServerMetadata(context.Background(), serverConnMetadata)
-				err := s.handle(ctx, service, m, msg)
+				err := s.handle(ctx, service, m, msg, received)
 				if err != nil {
 					s.errorHandler(serverConnMetadata, err)
 				}
 			}
 			if isSequenceHandleMsg {
-				cb()
+				handle()
+			} else if lns != nil {
+				// 先解出key，同key的请求在同一个lane顺序处理
+				rpcReq, req, err := s.decodeMsg(m, msg)
+				if err != nil {
+					s.errorHandler(serverConnMetadata, err)
+					return
+				}
+				s.wg.Add(1)
+				ok := lns.dispatch(keyFunc(rpcReq.GetHeader(), req), func() {
+					defer s.wg.Done()
+					ctx := s.withServerMetadata(context.Background(), serverConnMetadata)
+					if err := s.handleRequest(ctx, service, m, msg, rpcReq, req, received); err != nil {
+						s.errorHandler(serverConnMetadata, err)
+					}
+				})
+				if !ok {
+					s.wg.Done()
+				}
+			} else if pool != nil {
+				// BLOCK策略下Submit阻塞，消息堆积在订阅缓存中
+				s.wg.Add(1)
+				err := pool.Submit(func() {
+					defer s.wg.Done()
+					handle()
+				})
+				if err != nil {
+					s.wg.Done()
+					// pool释放后被唤醒的Submit也会返回overload，不算过载
+					if err == ants.ErrPoolOverload && !pool.IsClosed() {
+						err = s.overflow(service, m, concurrency, msg)
+					}
+					s.errorHandler(serverConnMetadata, err)
+				}
 			} else {
 				s.wg.Add(1)
 				go func() {
 					defer s.wg.Done()
-					cb()
+					handle()
 				}()
 			}
-		})
+		}
+
+		// 订阅
+		var (
+			natsSub *nats.Subscription
+			subErr  error
+		)
+		if js := service.jetStreamOf(methodName); js != nil {
+			if !m.isPublish {
+				return fmt.Errorf("method %s with jetstream must not have reply", m.name)
+			}
+			durable := consumerName(js, queue, methodName, methodSub, subject)
+			natsSub, subErr = s.subscribeJetStream(methodSub, subject, queue, durable, js, cb)
+		} else if service.methodGather[methodName] {
+			if m.isPublish {
+				return fmt.Errorf("method %s with gather must have reply", m.name)
+			}
+			natsSub, subErr = s.enc.Subscribe(subject, cb)
+		} else {
+			natsSub, subErr = s.enc.QueueSubscribe(subject, queue, cb)
+		}
 		if nil != subErr {
 			return subErr
 		}
 		s.services[service] = append(s.services[service], natsSub)
+		if err := setPendingLimits(natsSub, concurrency); err != nil {
+			return err
+		}
+	}
+
+	// 流方法
+	for methodName, handler := range service.methodStream {
+		name, h := methodName, handler
+		methodSub := CombineStr(s.namespace, service.serviceName, name)
+		subject := CombineStr(methodSub, service.topic)
+		if _, ok := dup[subject]; ok {
+			return fmt.Errorf("dup subject %s", subject)
+		}
+		dup[subject] = struct{}{}
+		queue, ok := service.methodQueue[name]
+		if !ok {
+			queue = service.queue
+		}
+		natsSub, err := s.conn.QueueSubscribe(subject, queue, func(msg *nats.Msg) {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				serverConnMetadata := ServerConnMetadata{
+					Namespace:         s.namespace,
+					ServiceName:       service.serviceName,
+					ServiceSimpleName: service.serviceName,
+					MethodSubject:     methodSub,
+					ReplySub:          msg.Reply,
+					Encoder:           s.enc.Enc,
+					MethodName:        name,
+				}
+				ctx := s.withServerMetadata(s.streamCtx, serverConnMetadata)
+				if err := s.handleStream(ctx, service, name, h, msg); err != nil {
+					s.errorHandler(serverConnMetadata, err)
+				}
+			}()
+		})
+		if err != nil {
+			return err
+		}
+		s.services[service] = append(s.services[service], natsSub)
 	}
 	return nil
 }
 
-func (s *ServerConn) handle(ctx context.Context, service *service, m *method, msg *nats.Msg) error {
-	req := m.newRequest()
+func (s *ServerConn) handle(ctx context.Context, service *service, m *method, msg *nats.Msg, received time.Time) error {
+	rpcReq, req, err := s.decodeMsg(m, msg)
+	if err != nil {
+		return err
+	}
+	return s.handleRequest(ctx, service, m, msg, rpcReq, req, received)
+}
 
-	if len(msg.Data) > 0 {
-		rpcReq := &Request{}
-		if err := s.enc.Enc.Decode(msg.Subject, msg.Data, rpcReq); nil != err {
-			return err
-		}
-		if len(rpcReq.Header) > 0 {
-			// 包traceId
-			ctx = trace.ContextWithTraceId(ctx, trace.GetTraceIdFromHeader(rpcReq.Header))
-			// 包header
-			ctx = WithHeaderContext(ctx, rpcReq.Header)
+// decodeMsg 解出外层Request和请求，没有数据时Request为nil
+func (s *ServerConn) decodeMsg(m *method, msg *nats.Msg) (*Request, interface{}, error) {
+	req := m.newRequest()
+	if len(msg.Data) == 0 {
+		return nil, req, nil
+	}
+	rpcReq := &Request{}
+	if err := s.enc.Enc.Decode(msg.Subject, msg.Data, rpcReq); nil != err {
+		return nil, nil, err
+	}
+	if len(rpcReq.Payload) > 0 {
+		if err := s.enc.Enc.Decode(msg.Subject, rpcReq.Payload, req); nil != err {
+			return nil, nil, err
 		}
-		if len(rpcReq.Payload) > 0 {
-			if err := s.enc.Enc.Decode(msg.Subject, rpcReq.Payload, req); nil != err {
-				return err
+	}
+	return rpcReq, req, nil
+}
+
+// handleRequest 处理解码后的请求
+func (s *ServerConn) handleRequest(ctx context.Context, service *service, m *method, msg *nats.Msg, rpcReq *Request, req interface{}, received time.Time) error {
+	reqHeader := rpcReq.GetHeader()
+	if len(reqHeader) > 0 {
+		// 包traceId
+		ctx = trace.ContextWithTraceId(ctx, trace.GetTraceIdFromHeader(reqHeader))
+		// 包header
+		ctx = WithHeaderContext(ctx, reqHeader)
+		// 调用方的截止时间，已过期的直接丢弃
+		if deadline, ok := deadlineFromHeader(reqHeader, received); ok {
+			if !time.Now().Before(deadline) {
+				s.shed(operation(service.serviceName, m.name))
+				return nil
 			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
 		}
 	}
 
+	header := HeaderFromCtx(ctx)
+	if header == nil {
+		header = map[string]string{}
+	}
+	tr := &Transport{
+		endpoint:    s.endpoint.String(),
+		operation:   operation(service.serviceName, m.name),
+		subject:     msg.Subject,
+		reqHeader:   headerCarrier(header),
+		replyHeader: headerCarrier{},
+	}
+	ctx = rpc.NewServerContext(ctx, tr)
+
 	var (
 		reply interface{}
 		err   error
 	)
 	// handle
+	start := time.Now()
 	reply, err = service.handle(ctx, m, req)
 	// publish的情况
 	if len(msg.Reply) == 0 || m.isPublish {
+		s.record(service, m, msg, rpcReq, req, nil, nil, start, err)
 		if len(msg.Reply) > 0 {
-			msg.Ack()
+			// JetStream失败时nak，等待重投
+			if js := service.jetStreamOf(m.name); js != nil && err != nil {
+				_ = msg.Nak()
+			} else {
+				msg.Ack()
+			}
 		}
 		return err
 	}
 	rp := &Reply{}
 	if err != nil {
-		rp.Error = err.Error()
+		rp = newErrorReply(err)
 	} else {
 		b, err := s.enc.Enc.Encode(msg.Subject, reply)
 		if err != nil {
@@ -303,6 +510,20 @@ func (s *ServerConn) handle(ctx context.Context, service *service, m *method, ms
 		}
 		rp.Payload = b
 	}
+	s.record(service, m, msg, rpcReq, req, reply, rp.Payload, start, err)
+	return s.reply(service, m, msg, rp, tr.replyHeader)
+}
+
+// record 记录请求和回复，使用已经编码好的数据
+func (s *ServerConn) record(service *service, m *method, msg *nats.Msg, rpcReq *Request, req, reply interface{}, replyPayload []byte, start time.Time, err error) {
+	if s.recorder == nil {
+		return
+	}
+	s.recorder.Record(newRecord(RecordSideServer, msg.Subject, service.serviceName, m.name, m.isPublish, rpcReq.GetHeader(), s.enc.Enc, req, reply, rpcReq.GetPayload(), replyPayload, start, err))
+}
+
+// reply 回复request
+func (s *ServerConn) reply(service *service, m *method, msg *nats.Msg, rp *Reply, header map[string]string) error {
 	b, err := s.enc.Enc.Encode(msg.Subject, rp)
 	if err != nil {
 		return err
@@ -313,7 +534,11 @@ func (s *ServerConn) handle(ctx context.Context, service *service, m *method, ms
 
 	// 注入replyHeader
 	replyHeader := map[string][]string{}
+	for k, v := range header {
+		replyHeader[k] = []string{v}
+	}
 	service.injectMethodReqRespIdsIntoHeader(m, replyHeader)
+	replyHeader[headerResponder] = []string{s.endpoint.String()}
 
 	// 构造恢复msg
 	respMsg := &nats.Msg{
@@ -326,12 +551,20 @@ func (s *ServerConn) handle(ctx context.Context, service *service, m *method, ms
 
 // Start 运行
 func (s *ServerConn) Start(ctx context.Context) error {
+	s.mu.Lock()
 	for serv := range s.services {
 		if err := s.subscribeMethod(serv); nil != err {
+			s.mu.Unlock()
 			return err
 		}
 	}
+	if err := s.subscribeIntrospection(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.started = time.Now()
 	s.ready = true
+	s.mu.Unlock()
 	addr := s.address
 	if len(addr) == 0 {
 		addr = s.conn.ConnectedAddr()
@@ -345,7 +578,7 @@ func (s *ServerConn) Endpoint() (*url.URL, error) {
 	return s.endpoint, nil
 }
 
-// Stop 停止
+// Stop 停止，ctx为app的stopTimeout
 func (s *ServerConn) Stop(ctx context.Context) error {
 	logger.Info("[Nats] server stopping")
 	return s.Close(ctx)
@@ -391,5 +624,7 @@ func (s *ServerConn) GetConn() *nats.Conn {
 }
 
 func (s *ServerConn) Ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.ready
 }
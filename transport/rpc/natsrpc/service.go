@@ -10,6 +10,7 @@ import (
 
 	"github.com/liuwangchen/toy/transport/middleware"
 	"github.com/nats-io/nats.go"
+	"github.com/panjf2000/ants/v2"
 )
 
 // ServiceOption service option
@@ -66,17 +67,30 @@ func WithServiceMethodSequence(methodSequenceHandle map[string]bool) ServiceOpti
 
 // service 服务
 type service struct {
-	serviceName          string                  // 名字
-	val                  interface{}             // 值
-	conn                 *ServerConn             // rpc
-	methods              map[string]*method      // 方法集合
-	timeout              time.Duration           // 请求/handle的超时
-	mw                   []middleware.Middleware // middleware
-	topic                string                  // 主题
-	queue                string                  // 服务级别queue
-	methodQueue          map[string]string       // 方法级queue
-	methodReqRspIds      map[string][2]int32     // 方法reqRspIds
-	methodSequenceHandle map[string]bool         // 方法是否顺序处理msg methodName -> bool
+	serviceName          string                   // 名字
+	val                  interface{}              // 值
+	conn                 *ServerConn              // rpc
+	methods              map[string]*method       // 方法集合
+	timeout              time.Duration            // 请求/handle的超时
+	mw                   []middleware.Middleware  // middleware
+	topic                string                   // 主题
+	queue                string                   // 服务级别queue
+	methodQueue          map[string]string        // 方法级queue
+	methodReqRspIds      map[string][2]int32      // 方法reqRspIds
+	methodSequenceHandle map[string]bool          // 方法是否顺序处理msg methodName -> bool
+	methodJetStream      map[string]*JetStream    // 方法走JetStream methodName -> 配置
+	jetStream            *JetStream               // service级别的JetStream，无返回值的方法使用
+	methodStream         map[string]StreamHandler // 流方法 methodName -> handler
+	methodGather         map[string]bool          // 方法广播不走queue methodName -> bool
+	concurrency          *Concurrency             // service级别的并发限制
+	methodConcurrency    map[string]*Concurrency  // 方法级别的并发限制
+	pool                 *ants.Pool               // service级别共享的pool
+	pools                []*ants.Pool             // 所有pool，反注册时释放
+	methodPartition      map[string]*Partition    // 方法按key分区 methodName -> 配置
+	methodKeyFunc        map[string]KeyFunc       // 方法的分区key methodName -> KeyFunc
+	hash                 func(key string, n int) int
+	lanes                []*lanes // 所有分区lanes，反注册时关闭
+	version              string   // 版本
 }
 
 // Name 名字
@@ -100,6 +114,8 @@ func newService(serviceName string, i interface{}, opts ...ServiceOption) (*serv
 		methodSequenceHandle: make(map[string]bool),
 		methodReqRspIds:      map[string][2]int32{},
 		methodQueue:          map[string]string{},
+		methodJetStream:      map[string]*JetStream{},
+		methodGather:         map[string]bool{},
 	}
 
 	val := reflect.ValueOf(i)
@@ -112,10 +128,6 @@ func newService(serviceName string, i interface{}, opts ...ServiceOption) (*serv
 	}
 
 	ms := parseMethod(i)
-	if len(ms) == 0 {
-		return nil, fmt.Errorf("service [%s] has no exported method", serviceName)
-	}
-
 	for _, v := range ms {
 		if _, ok := s.methods[v.name]; ok {
 			return nil, fmt.Errorf("service [%s] duplicate method [%s]", serviceName, v.name)
@@ -126,6 +138,9 @@ func newService(serviceName string, i interface{}, opts ...ServiceOption) (*serv
 	for _, v := range opts {
 		v(s)
 	}
+	if len(s.methods) == 0 && len(s.methodStream) == 0 {
+		return nil, fmt.Errorf("service [%s] has no exported method", serviceName)
+	}
 	return s, nil
 }
 
@@ -151,6 +166,14 @@ var (
 	headerKey_MethodRespId = "headerMethod_RespId"
 )
 
+// methodSubject 方法的subject，不含topic，配置了reqRspIds时用请求id代替方法名
+func (s *service) methodSubject(namespace, methodName string) string {
+	if reqRspIds, ok := s.methodReqRspIds[methodName]; ok {
+		return CombineStr(namespace, s.serviceName, fmt.Sprint(reqRspIds[0]))
+	}
+	return CombineStr(namespace, s.serviceName, methodName)
+}
+
 func (s *service) injectMethodReqRespIdsIntoHeader(m *method, header nats.Header) {
 	reqRspIds, ok := s.methodReqRspIds[m.name]
 	if !ok {
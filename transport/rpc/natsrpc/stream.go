@@ -0,0 +1,522 @@
+package natsrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/liuwangchen/toy/transport/middleware"
+	"github.com/liuwangchen/toy/transport/middleware/trace"
+	"github.com/liuwangchen/toy/transport/rpc"
+	"github.com/nats-io/nats.go"
+)
+
+// 流的每一帧通过nats header区分类型
+const (
+	streamFrameHeader  = "Natsrpc-Frame"
+	streamWindowHeader = "Natsrpc-Window"
+	streamCreditHeader = "Natsrpc-Credit"
+
+	frameOpen   = "open"   // server->client，Reply为server端inbox
+	frameData   = "data"   // 一条消息
+	frameAck    = "ack"    // 接收方消费后归还的发送额度
+	frameEOS    = "eos"    // 发送结束，server->client时携带错误
+	frameCancel = "cancel" // client取消
+	framePing   = "ping"   // 心跳
+
+	defaultStreamWindow    = 64
+	defaultStreamKeepalive = 10 * time.Second
+	streamIdleTimes        = 3 // 超过几个心跳间隔未收到对端的帧认为对端已经失联
+)
+
+// IStream 流
+type IStream interface {
+	// Context 流的context，client取消或流结束后Done
+	Context() context.Context
+	// SendMsg 发送一条消息，对端接收窗口用完时阻塞
+	// 对端已经结束时返回io.EOF，真实的状态由RecvMsg返回
+	SendMsg(m interface{}) error
+	// RecvMsg 接收一条消息，对端正常结束时返回io.EOF
+	RecvMsg(m interface{}) error
+}
+
+// IClientStream client端的流
+type IClientStream interface {
+	IStream
+	// CloseSend 结束发送，server端RecvMsg返回io.EOF
+	CloseSend() error
+}
+
+// IServerStream server端的流
+type IServerStream interface {
+	IStream
+}
+
+// StreamHandler 流方法的handler，由生成代码提供
+type StreamHandler func(ctx context.Context, stream IServerStream) error
+
+// WithServiceMethodStream 流方法 methodName -> handler
+func WithServiceMethodStream(methodStream map[string]StreamHandler) ServiceOption {
+	return func(s *service) {
+		s.methodStream = methodStream
+	}
+}
+
+// WithStreamWindow 流的接收窗口，即对端最多可以发送多少条未确认的消息
+func WithStreamWindow(window int) Option {
+	return func(s ISetOption) {
+		switch c := s.(type) {
+		case *ServerConn:
+			c.streamWindow = window
+		case *ClientConn:
+			c.streamWindow = window
+		}
+	}
+}
+
+// WithStreamKeepalive 流的心跳间隔，超过3个间隔未收到对端的帧时取消流，<=0不检测
+func WithStreamKeepalive(interval time.Duration) Option {
+	return func(s ISetOption) {
+		switch c := s.(type) {
+		case *ServerConn:
+			c.streamKeepalive = interval
+		case *ClientConn:
+			c.streamKeepalive = interval
+		}
+	}
+}
+
+// stream client和server共用的流实现
+type stream struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	conn    *nats.Conn
+	enc     nats.Encoder
+	subject string // 方法subject，用于编解码
+	inbox   string // 本端inbox
+	peer    string // 对端inbox
+	sub     *nats.Subscription
+	window  int  // 本端接收窗口
+	client  bool // 是否是client端
+
+	frames   chan *nats.Msg // data和eos帧
+	opened   chan struct{}  // 收到open帧
+	peerDone chan struct{}  // 对端不再接收
+	doneOnce sync.Once
+	openOnce sync.Once
+	openErr  error
+
+	lastSeen int64        // 最后一次收到对端帧的时间，UnixNano
+	idleErr  atomic.Value // 心跳超时取消流的原因
+
+	mu       sync.Mutex
+	credit   int           // 可以发送的data帧数
+	notify   chan struct{} // credit增加
+	sendDone bool
+
+	consumed int   // 已消费未归还的额度
+	recvErr  error // RecvMsg的结束状态
+}
+
+func newStream(ctx context.Context, conn *nats.Conn, enc nats.Encoder, subject string, window int) (*stream, error) {
+	if window <= 0 {
+		window = defaultStreamWindow
+	}
+	st := &stream{
+		conn:     conn,
+		enc:      enc,
+		subject:  subject,
+		inbox:    conn.NewRespInbox(),
+		window:   window,
+		frames:   make(chan *nats.Msg, window+1),
+		opened:   make(chan struct{}),
+		peerDone: make(chan struct{}),
+		notify:   make(chan struct{}, 1),
+	}
+	st.ctx, st.cancel = context.WithCancel(ctx)
+	sub, err := conn.Subscribe(st.inbox, st.dispatch)
+	if err != nil {
+		st.cancel()
+		return nil, err
+	}
+	st.sub = sub
+	return st, nil
+}
+
+// dispatch 处理对端发来的帧，ack和cancel直接处理，data和eos按序交给RecvMsg
+func (st *stream) dispatch(msg *nats.Msg) {
+	atomic.StoreInt64(&st.lastSeen, time.Now().UnixNano())
+	switch msg.Header.Get(streamFrameHeader) {
+	case frameOpen:
+		// 重复的open帧忽略
+		st.openOnce.Do(func() {
+			st.start(msg.Reply, msg.Header.Get(streamWindowHeader))
+			close(st.opened)
+		})
+	case framePing:
+	case frameAck:
+		n, _ := strconv.Atoi(msg.Header.Get(streamCreditHeader))
+		st.addCredit(n)
+	case frameCancel:
+		st.closePeer()
+		st.cancel()
+	case frameData:
+		st.push(msg)
+	case frameEOS:
+		st.push(msg)
+		if st.client {
+			// server结束后client不能再发送
+			st.closePeer()
+		}
+	default:
+		// 没有订阅者时nats server回复的503
+		// 已经打开之后的503忽略
+		if msg.Header.Get("Status") == "503" {
+			st.openOnce.Do(func() {
+				st.openErr = errors.ServiceUnavailable("NO_RESPONDERS", "no responders on "+st.subject)
+				close(st.opened)
+			})
+		}
+	}
+}
+
+// push 发送方遵守接收窗口时不会阻塞
+func (st *stream) push(msg *nats.Msg) {
+	select {
+	case st.frames <- msg:
+	case <-st.ctx.Done():
+	}
+}
+
+// start 记录对端inbox和发送额度
+func (st *stream) start(peer string, window string) {
+	st.peer = peer
+	n, _ := strconv.Atoi(window)
+	if n <= 0 {
+		n = defaultStreamWindow
+	}
+	st.addCredit(n)
+}
+
+// keepalive 定时发送心跳，对端失联时取消流，流结束后退出
+func (st *stream) keepalive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	atomic.StoreInt64(&st.lastSeen, time.Now().UnixNano())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-st.ctx.Done():
+			return
+		}
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&st.lastSeen)))
+		if idle > streamIdleTimes*interval {
+			st.idleErr.Store(errors.ServiceUnavailable("STREAM_IDLE_TIMEOUT", fmt.Sprintf("stream %s idle for %s", st.subject, idle)))
+			st.cancel()
+			return
+		}
+		_ = st.publish(framePing, nil, nil)
+	}
+}
+
+// err 流被取消的原因
+func (st *stream) err() error {
+	if err, ok := st.idleErr.Load().(error); ok {
+		return err
+	}
+	return st.ctx.Err()
+}
+
+func (st *stream) addCredit(n int) {
+	st.mu.Lock()
+	st.credit += n
+	st.mu.Unlock()
+	select {
+	case st.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (st *stream) closePeer() {
+	st.doneOnce.Do(func() {
+		close(st.peerDone)
+	})
+}
+
+func (st *stream) publish(frame string, data []byte, header nats.Header) error {
+	if header == nil {
+		header = nats.Header{}
+	}
+	header.Set(streamFrameHeader, frame)
+	return st.conn.PublishMsg(&nats.Msg{
+		Subject: st.peer,
+		Reply:   st.inbox,
+		Header:  header,
+		Data:    data,
+	})
+}
+
+func (st *stream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *stream) SendMsg(m interface{}) error {
+	for {
+		select {
+		case <-st.peerDone:
+			return io.EOF
+		case <-st.ctx.Done():
+			return st.err()
+		default:
+		}
+		st.mu.Lock()
+		if st.sendDone {
+			st.mu.Unlock()
+			return errors.BadRequest("STREAM_CLOSED", "send on closed stream")
+		}
+		if st.credit > 0 {
+			st.credit--
+			st.mu.Unlock()
+			break
+		}
+		st.mu.Unlock()
+		select {
+		case <-st.notify:
+		case <-st.peerDone:
+			return io.EOF
+		case <-st.ctx.Done():
+			return st.err()
+		}
+	}
+	b, err := st.enc.Encode(st.subject, m)
+	if err != nil {
+		return err
+	}
+	return st.publish(frameData, b, nil)
+}
+
+func (st *stream) RecvMsg(m interface{}) error {
+	if st.recvErr != nil {
+		return st.recvErr
+	}
+	var msg *nats.Msg
+	select {
+	case msg = <-st.frames:
+	case <-st.ctx.Done():
+		// 已经到达的帧优先
+		select {
+		case msg = <-st.frames:
+		default:
+			return st.err()
+		}
+	}
+	if msg.Header.Get(streamFrameHeader) == frameEOS {
+		st.recvErr = io.EOF
+		if len(msg.Data) > 0 {
+			rp := &Reply{}
+			if err := st.enc.Decode(st.subject, msg.Data, rp); err != nil {
+				st.recvErr = err
+			} else if err := replyError(rp); err != nil {
+				st.recvErr = err
+			}
+		}
+		return st.recvErr
+	}
+	st.consumed++
+	if st.consumed >= (st.window+1)/2 {
+		header := nats.Header{}
+		header.Set(streamCreditHeader, strconv.Itoa(st.consumed))
+		st.consumed = 0
+		if err := st.publish(frameAck, nil, header); err != nil {
+			return err
+		}
+	}
+	return st.enc.Decode(st.subject, msg.Data, m)
+}
+
+// closeSend 发送eos，err不为nil时编码成Reply
+func (st *stream) closeSend(err error) error {
+	st.mu.Lock()
+	if st.sendDone {
+		st.mu.Unlock()
+		return nil
+	}
+	st.sendDone = true
+	st.mu.Unlock()
+	var data []byte
+	if err != nil {
+		var err1 error
+		data, err1 = st.enc.Encode(st.subject, newErrorReply(err))
+		if err1 != nil {
+			return err1
+		}
+	}
+	return st.publish(frameEOS, data, nil)
+}
+
+func (st *stream) close() {
+	st.cancel()
+	_ = st.sub.Unsubscribe()
+}
+
+// clientStream client端的流
+type clientStream struct {
+	*stream
+}
+
+func (cs *clientStream) CloseSend() error {
+	return cs.closeSend(nil)
+}
+
+// watch client取消时通知server，server结束后释放订阅
+func (cs *clientStream) watch() {
+	select {
+	case <-cs.ctx.Done():
+		select {
+		case <-cs.peerDone:
+		default:
+			header := nats.Header{}
+			header.Set(streamFrameHeader, frameCancel)
+			_ = cs.conn.PublishMsg(&nats.Msg{Subject: cs.peer, Header: header})
+		}
+	case <-cs.peerDone:
+	}
+	// 已经到达的帧留给RecvMsg读取
+	cs.close()
+}
+
+// NewStream 打开一个流，生成代码使用
+// 流的生命周期由ctx控制，conn的timeout只作用于建立流的握手
+func (c *Client) NewStream(ctx context.Context, serviceName, methodName string) (IClientStream, error) {
+	subject := CombineStr(c.conn.namespace, serviceName, methodName, CallTopicFromCtx(ctx))
+	ctx = WithHeaderContext(ctx, map[string]string{})
+	ctx = rpc.NewClientContext(ctx, &Transport{
+		endpoint:  c.conn.conn.ConnectedUrl(),
+		operation: operation(serviceName, methodName),
+		subject:   subject,
+		reqHeader: headerCarrier(HeaderFromCtx(ctx)),
+	})
+
+	h := func(ctx1 context.Context, _ interface{}) (interface{}, error) {
+		header := HeaderFromCtx(ctx1)
+		trace.SetTraceIdIntoHeader(header, trace.GetTraceIdFromCtx(ctx1))
+		data, err := c.conn.enc.Enc.Encode(subject, NewRequest(nil, header))
+		if err != nil {
+			return nil, err
+		}
+		st, err := newStream(ctx1, c.conn.conn, c.conn.enc.Enc, subject, c.conn.streamWindow)
+		if err != nil {
+			return nil, err
+		}
+		st.client = true
+		natsHeader := nats.Header{}
+		natsHeader.Set(streamFrameHeader, frameOpen)
+		natsHeader.Set(streamWindowHeader, strconv.Itoa(st.window))
+		err = c.conn.conn.PublishMsg(&nats.Msg{
+			Subject: subject,
+			Reply:   st.inbox,
+			Header:  natsHeader,
+			Data:    data,
+		})
+		if err != nil {
+			st.close()
+			return nil, err
+		}
+		var timeout <-chan time.Time
+		if c.conn.timeout > 0 {
+			timer := time.NewTimer(c.conn.timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-st.opened:
+			if st.openErr != nil {
+				st.close()
+				return nil, st.openErr
+			}
+		case <-ctx1.Done():
+			st.close()
+			return nil, ctx1.Err()
+		case <-timeout:
+			st.close()
+			return nil, nats.ErrTimeout
+		}
+		cs := &clientStream{stream: st}
+		go cs.watch()
+		go st.keepalive(c.conn.streamKeepalive)
+		return cs, nil
+	}
+
+	// 中间件只作用于建立流
+	mw := append(c.conn.mw[:len(c.conn.mw):len(c.conn.mw)], c.mw...)
+	if len(mw) > 0 {
+		h = middleware.Chain(mw...)(h)
+	}
+	cs, err := h(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cs.(IClientStream), nil
+}
+
+// handleStream server端处理一个流，handler返回后发送eos
+func (s *ServerConn) handleStream(ctx context.Context, service *service, methodName string, handler StreamHandler, msg *nats.Msg) error {
+	if len(msg.Reply) == 0 {
+		return fmt.Errorf("stream %s without reply", msg.Subject)
+	}
+	if len(msg.Data) > 0 {
+		rpcReq := &Request{}
+		if err := s.enc.Enc.Decode(msg.Subject, msg.Data, rpcReq); nil != err {
+			return err
+		}
+		if len(rpcReq.Header) > 0 {
+			ctx = trace.ContextWithTraceId(ctx, trace.GetTraceIdFromHeader(rpcReq.Header))
+			ctx = WithHeaderContext(ctx, rpcReq.Header)
+		}
+	}
+	header := HeaderFromCtx(ctx)
+	if header == nil {
+		header = map[string]string{}
+	}
+	ctx = rpc.NewServerContext(ctx, &Transport{
+		endpoint:    s.endpoint.String(),
+		operation:   operation(service.serviceName, methodName),
+		subject:     msg.Subject,
+		reqHeader:   headerCarrier(header),
+		replyHeader: headerCarrier{},
+	})
+
+	st, err := newStream(ctx, s.conn, s.enc.Enc, msg.Subject, s.streamWindow)
+	if err != nil {
+		return err
+	}
+	defer st.close()
+	st.start(msg.Reply, msg.Header.Get(streamWindowHeader))
+	natsHeader := nats.Header{}
+	natsHeader.Set(streamWindowHeader, strconv.Itoa(st.window))
+	if err := st.publish(frameOpen, nil, natsHeader); err != nil {
+		return err
+	}
+	go st.keepalive(s.streamKeepalive)
+
+	h := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return nil, handler(ctx, st)
+	}
+	mw := append(s.mw[:len(s.mw):len(s.mw)], service.mw...)
+	if len(mw) > 0 {
+		h = middleware.Chain(mw...)(h)
+	}
+	_, err = h(st.ctx, nil)
+	if err1 := st.closeSend(err); err == nil {
+		err = err1
+	}
+	return err
+}
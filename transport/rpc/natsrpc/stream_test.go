@@ -0,0 +1,283 @@
+package natsrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liuwangchen/toy/transport/errors"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type Streamer struct{}
+
+func startStreamServer(t *testing.T, url string, streams map[string]StreamHandler, opts ...Option) {
+	t.Helper()
+	conn, err := NewServerConn(append(opts, WithAddr(url))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Register("test.Streamer", &Streamer{}, WithServiceMethodStream(streams)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = conn.Start(ctx)
+	}()
+	for !conn.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	t.Cleanup(func() {
+		cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = conn.Close(ctx)
+	})
+}
+
+func newStreamClient(t *testing.T, url string, opts ...Option) *Client {
+	t.Helper()
+	cc, err := NewClientConn(append(opts, WithAddr(url))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cc.conn.Close)
+	return NewClient(cc)
+}
+
+func TestServerStream(t *testing.T) {
+	ns := runNatsServer(t)
+	startStreamServer(t, ns.ClientURL(), map[string]StreamHandler{
+		"Count": func(ctx context.Context, stream IServerStream) error {
+			req := new(wrapperspb.Int32Value)
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			for i := int32(0); i < req.Value; i++ {
+				if err := stream.SendMsg(wrapperspb.Int32(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	c := newStreamClient(t, ns.ClientURL())
+	stream, err := c.NewStream(context.Background(), "test.Streamer", "Count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过默认窗口，依赖ack归还额度
+	const total = 200
+	if err := stream.SendMsg(wrapperspb.Int32(total)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	for i := int32(0); i < total; i++ {
+		rep := new(wrapperspb.Int32Value)
+		if err := stream.RecvMsg(rep); err != nil {
+			t.Fatal(err)
+		}
+		if rep.Value != i {
+			t.Fatalf("expect %d, got %d", i, rep.Value)
+		}
+	}
+	if err := stream.RecvMsg(new(wrapperspb.Int32Value)); err != io.EOF {
+		t.Fatalf("expect EOF, got %v", err)
+	}
+}
+
+func TestBidiStream(t *testing.T) {
+	ns := runNatsServer(t)
+	startStreamServer(t, ns.ClientURL(), map[string]StreamHandler{
+		"Echo": func(ctx context.Context, stream IServerStream) error {
+			for {
+				req := new(wrapperspb.StringValue)
+				err := stream.RecvMsg(req)
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := stream.SendMsg(req); err != nil {
+					return err
+				}
+			}
+		},
+	})
+	c := newStreamClient(t, ns.ClientURL())
+	stream, err := c.NewStream(context.Background(), "test.Streamer", "Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := stream.SendMsg(wrapperspb.String(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+		rep := new(wrapperspb.StringValue)
+		if err := stream.RecvMsg(rep); err != nil {
+			t.Fatal(err)
+		}
+		if rep.Value != fmt.Sprint(i) {
+			t.Fatalf("expect %d, got %s", i, rep.Value)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(new(wrapperspb.StringValue)); err != io.EOF {
+		t.Fatalf("expect EOF, got %v", err)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	ns := runNatsServer(t)
+	startStreamServer(t, ns.ClientURL(), map[string]StreamHandler{
+		"Fail": func(ctx context.Context, stream IServerStream) error {
+			if err := stream.SendMsg(wrapperspb.String("first")); err != nil {
+				return err
+			}
+			return errors.BadRequest("BAD", "bad stream")
+		},
+	})
+	c := newStreamClient(t, ns.ClientURL())
+	stream, err := c.NewStream(context.Background(), "test.Streamer", "Fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := new(wrapperspb.StringValue)
+	if err := stream.RecvMsg(rep); err != nil || rep.Value != "first" {
+		t.Fatalf("expect first, got %v %v", rep.Value, err)
+	}
+	err = stream.RecvMsg(rep)
+	if errors.Code(err) != 400 || errors.Reason(err) != "BAD" {
+		t.Fatalf("expect BAD error, got %v", err)
+	}
+	// server结束后不能再发送
+	if err := stream.SendMsg(rep); err != io.EOF {
+		t.Fatalf("expect EOF, got %v", err)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	ns := runNatsServer(t)
+	canceled := make(chan struct{})
+	startStreamServer(t, ns.ClientURL(), map[string]StreamHandler{
+		"Wait": func(ctx context.Context, stream IServerStream) error {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		},
+	})
+	c := newStreamClient(t, ns.ClientURL())
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.NewStream(ctx, "test.Streamer", "Wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expect server stream canceled")
+	}
+	if err := stream.RecvMsg(new(wrapperspb.StringValue)); err != context.Canceled {
+		t.Fatalf("expect canceled, got %v", err)
+	}
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	ns := runNatsServer(t)
+	var sent int32
+	startStreamServer(t, ns.ClientURL(), map[string]StreamHandler{
+		"Flood": func(ctx context.Context, stream IServerStream) error {
+			for i := 0; i < 20; i++ {
+				if err := stream.SendMsg(wrapperspb.Int32(int32(i))); err != nil {
+					return err
+				}
+				atomic.AddInt32(&sent, 1)
+			}
+			return nil
+		},
+	})
+	c := newStreamClient(t, ns.ClientURL(), WithStreamWindow(4))
+	stream, err := c.NewStream(context.Background(), "test.Streamer", "Flood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// client不读时server最多发送一个窗口
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&sent); n != 4 {
+		t.Fatalf("expect 4 sent before client reads, got %d", n)
+	}
+	for i := 0; i < 20; i++ {
+		if err := stream.RecvMsg(new(wrapperspb.Int32Value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.RecvMsg(new(wrapperspb.Int32Value)); err != io.EOF {
+		t.Fatalf("expect EOF, got %v", err)
+	}
+}
+
+func TestStreamNoResponders(t *testing.T) {
+	ns := runNatsServer(t)
+	c := newStreamClient(t, ns.ClientURL())
+	_, err := c.NewStream(context.Background(), "test.Streamer", "Missing")
+	if errors.Code(err) != 503 {
+		t.Fatalf("expect 503, got %v", err)
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	ns := runNatsServer(t)
+	done := make(chan error, 1)
+	startStreamServer(t, ns.ClientURL(), map[string]StreamHandler{
+		"Flood": func(ctx context.Context, stream IServerStream) error {
+			for {
+				if err := stream.SendMsg(wrapperspb.Int32(0)); err != nil {
+					done <- err
+					return err
+				}
+			}
+		},
+	}, WithStreamKeepalive(20*time.Millisecond))
+	// client不发心跳也不读，server阻塞在SendMsg
+	c := newStreamClient(t, ns.ClientURL(), WithStreamWindow(1), WithStreamKeepalive(0))
+	if _, err := c.NewStream(context.Background(), "test.Streamer", "Flood"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if errors.Reason(err) != "STREAM_IDLE_TIMEOUT" {
+			t.Fatalf("expect idle timeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expect server stream canceled")
+	}
+}
+
+func TestStreamLateOpen(t *testing.T) {
+	ns := runNatsServer(t)
+	c := newStreamClient(t, ns.ClientURL())
+	st, err := newStream(context.Background(), c.conn.conn, c.conn.enc.Enc, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.close()
+	open := &nats.Msg{Header: nats.Header{}}
+	open.Header.Set(streamFrameHeader, frameOpen)
+	st.dispatch(open)
+	// 重复的open帧和之后的503不能panic
+	st.dispatch(open)
+	st.dispatch(&nats.Msg{Header: nats.Header{"Status": []string{"503"}}})
+	if st.openErr != nil {
+		t.Fatalf("expect opened, got %v", st.openErr)
+	}
+}
@@ -0,0 +1,73 @@
+package natsrpc
+
+import (
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+var _ rpc.Transporter = &Transport{}
+
+// Transport is a nats transport.
+type Transport struct {
+	endpoint    string
+	operation   string
+	subject     string
+	reqHeader   headerCarrier
+	replyHeader headerCarrier
+}
+
+// Kind returns the transport kind.
+func (tr *Transport) Kind() rpc.Kind {
+	return rpc.KindNats
+}
+
+// Endpoint returns the transport endpoint.
+func (tr *Transport) Endpoint() string {
+	return tr.endpoint
+}
+
+// Operation returns the transport operation.
+// 格式与grpc/http一致 /service/method
+func (tr *Transport) Operation() string {
+	return tr.operation
+}
+
+// Subject returns the nats subject.
+func (tr *Transport) Subject() string {
+	return tr.subject
+}
+
+// RequestHeader returns the request header.
+func (tr *Transport) RequestHeader() rpc.Header {
+	return tr.reqHeader
+}
+
+// ReplyHeader returns the reply header.
+func (tr *Transport) ReplyHeader() rpc.Header {
+	return tr.replyHeader
+}
+
+// operation 组装operation
+func operation(serviceName, methodName string) string {
+	return "/" + serviceName + "/" + methodName
+}
+
+type headerCarrier map[string]string
+
+// Get returns the value associated with the passed key.
+func (hc headerCarrier) Get(key string) string {
+	return hc[key]
+}
+
+// Set stores the key-value pair.
+func (hc headerCarrier) Set(key string, value string) {
+	hc[key] = value
+}
+
+// Keys lists the keys stored in this carrier.
+func (hc headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(hc))
+	for k := range hc {
+		keys = append(keys, k)
+	}
+	return keys
+}
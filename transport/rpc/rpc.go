@@ -17,6 +17,11 @@ type Endpointer interface {
 	Endpoint() (*url.URL, error)
 }
 
+// Metadataer 注册到注册中心的metadata
+type Metadataer interface {
+	Metadata() map[string]string
+}
+
 // Header is the storage medium used by a Header.
 type Header interface {
 	Get(key string) string
@@ -0,0 +1,54 @@
+// Package rpctest 测试中间件用的Transporter
+package rpctest
+
+import (
+	"github.com/liuwangchen/toy/transport/rpc"
+)
+
+var _ rpc.Transporter = (*Transport)(nil)
+
+// Header map实现的rpc.Header
+type Header map[string]string
+
+func (h Header) Get(key string) string { return h[key] }
+func (h Header) Set(key, value string) { h[key] = value }
+func (h Header) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Transport 固定operation和header的Transporter
+type Transport struct {
+	kind        rpc.Kind
+	operation   string
+	reqHeader   Header
+	replyHeader Header
+}
+
+// NewTransport new a nats transport, header为nil时用空header
+// 同一个header传给client和server的Transport可以模拟header的传递
+func NewTransport(operation string, header Header) *Transport {
+	return NewKindTransport(rpc.KindNats, operation, header)
+}
+
+// NewKindTransport new a transport of kind
+func NewKindTransport(kind rpc.Kind, operation string, header Header) *Transport {
+	if header == nil {
+		header = Header{}
+	}
+	return &Transport{
+		kind:        kind,
+		operation:   operation,
+		reqHeader:   header,
+		replyHeader: Header{},
+	}
+}
+
+func (tr *Transport) Kind() rpc.Kind            { return tr.kind }
+func (tr *Transport) Endpoint() string          { return string(tr.kind) + "://127.0.0.1" }
+func (tr *Transport) Operation() string         { return tr.operation }
+func (tr *Transport) RequestHeader() rpc.Header { return tr.reqHeader }
+func (tr *Transport) ReplyHeader() rpc.Header   { return tr.replyHeader }